@@ -0,0 +1,31 @@
+package common
+
+import (
+	"math"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+// singularTol is the size of a pivot relative to the largest element of the
+// matrix below which the matrix is treated as singular
+const singularTol = 1e-14
+
+// LU computes the LU factorization of the square matrix a, which is
+// overwritten. singular is true if a pivot is small relative to the largest
+// element of a, in which case the factors must not be used to solve systems.
+func LU(a *mat64.Dense) (lu mat64.LUFactors, singular bool) {
+	n, _ := a.Dims()
+	var maxAbs float64
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			maxAbs = math.Max(maxAbs, math.Abs(a.At(i, j)))
+		}
+	}
+	lu = mat64.LU(a)
+	for i := 0; i < n; i++ {
+		if math.Abs(lu.LU.At(i, i)) <= singularTol*maxAbs {
+			return lu, true
+		}
+	}
+	return lu, false
+}
@@ -0,0 +1,163 @@
+package constrained
+
+import (
+	"errors"
+	"math"
+
+	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/multivariate"
+	"github.com/btracey/opt/write"
+)
+
+// AugmentedLagrangian solves constrained problems by minimizing a sequence of
+// unconstrained augmented Lagrangian subproblems
+//
+//	L_A(x) = f(x) - λ^T c_E(x) + ρ/2 ||c_E(x)||^2 + 1/(2ρ) Σ_j (max(0, μ_j - ρ c_I,j(x))^2 - μ_j^2)
+//
+// with the Inner optimizer. After every subproblem the multipliers λ and μ are
+// updated, and the penalty ρ is increased if the constraint violation did not
+// decrease enough. Every iteration is one subproblem.
+type AugmentedLagrangian struct {
+	Inner         multivariate.GradOptimizer // Optimizer for the subproblems
+	InnerSettings *multivariate.Settings     // Settings for the subproblems
+
+	InitialPenalty    float64 // Penalty of the first subproblem
+	PenaltyFactor     float64 // Factor by which the penalty is increased
+	MaxPenalty        float64 // Largest penalty
+	ViolationDecrease float64 // The penalty is increased if the violation decreases by less than this factor
+
+	fun  multivariate.ObjGrader
+	c    Constraints
+	nDim int
+
+	loc           []float64
+	obj           *augLagObjective
+	sub           *subproblem
+	eval          *evaluation
+	penalty       float64
+	prevViolation float64
+}
+
+// NewAugmentedLagrangian returns an AugmentedLagrangian with the subproblems
+// solved by Bfgs
+func NewAugmentedLagrangian() *AugmentedLagrangian {
+	innerSettings := multivariate.DefaultSettings()
+	innerSettings.DisplayWriters = nil
+	innerSettings.GradAbsTol = 1e-8
+	innerSettings.MaximumIterations = 1000
+	return &AugmentedLagrangian{
+		Inner:             multivariate.NewBfgs(),
+		InnerSettings:     innerSettings,
+		InitialPenalty:    10,
+		PenaltyFactor:     10,
+		MaxPenalty:        1e10,
+		ViolationDecrease: 0.25,
+	}
+}
+
+func (a *AugmentedLagrangian) Init(f multivariate.ObjGrader, c Constraints, initLoc []float64, initObj float64, initGrad []float64) error {
+	if a.Inner == nil {
+		return errors.New("auglag: nil inner optimizer")
+	}
+	if a.InitialPenalty <= 0 || a.MaxPenalty < a.InitialPenalty {
+		return errors.New("auglag: bad penalty")
+	}
+	if a.PenaltyFactor <= 1 {
+		return errors.New("auglag: penalty factor must be greater than one")
+	}
+	a.fun = f
+	a.c = c
+	a.nDim = len(initLoc)
+
+	a.loc = append(a.loc[:0], initLoc...)
+	a.eval = newEvaluation(a.nDim, c.NumEquality(), c.NumInequality())
+	a.obj = &augLagObjective{
+		eval:     newEvaluation(a.nDim, c.NumEquality(), c.NumInequality()),
+		fun:      f,
+		c:        c,
+		eqMult:   make([]float64, c.NumEquality()),
+		ineqMult: make([]float64, c.NumInequality()),
+	}
+	a.sub = &subproblem{ObjGrader: a.obj}
+	a.penalty = a.InitialPenalty
+	a.prevViolation = math.Inf(1)
+	return nil
+}
+
+func (a *AugmentedLagrangian) Status() common.Status {
+	return common.Continue
+}
+
+func (a *AugmentedLagrangian) Iterate(loc, grad, eqMult, ineqMult []float64) (obj, violation float64, nFunEvals int, err error) {
+	if len(loc) != a.nDim {
+		panic("dimension mismatch")
+	}
+	sub := a.obj
+	sub.penalty = a.penalty
+	err = a.sub.minimize(a.loc, a.InnerSettings, a.Inner)
+	if err != nil {
+		return math.NaN(), math.NaN(), a.sub.nFunEvals, errors.New("auglag: subproblem failed: " + err.Error())
+	}
+
+	a.eval.evaluate(a.fun, a.c, a.loc)
+	violation = a.eval.violation()
+
+	// First-order multiplier updates
+	for i, c := range a.eval.eq {
+		sub.eqMult[i] -= a.penalty * c
+	}
+	for i, c := range a.eval.ineq {
+		sub.ineqMult[i] = math.Max(0, sub.ineqMult[i]-a.penalty*c)
+	}
+	if violation > a.ViolationDecrease*a.prevViolation {
+		a.penalty = math.Min(a.penalty*a.PenaltyFactor, a.MaxPenalty)
+	}
+	a.prevViolation = violation
+
+	copy(loc, a.loc)
+	copy(eqMult, sub.eqMult)
+	copy(ineqMult, sub.ineqMult)
+	lagrangianGrad(grad, a.eval.grad, a.eval.eqJac, eqMult, a.eval.ineqJac, ineqMult)
+	return a.eval.obj, violation, a.sub.nFunEvals + 1, nil
+}
+
+func (a *AugmentedLagrangian) AppendWriteData(v []*write.Value) []*write.Value {
+	v = append(v, &write.Value{Heading: "Penalty", Value: a.penalty})
+	return v
+}
+
+func (a *AugmentedLagrangian) Result() {}
+
+// augLagObjective is the augmented Lagrangian subproblem
+type augLagObjective struct {
+	fun  multivariate.ObjGrader
+	c    Constraints
+	eval *evaluation
+
+	eqMult   []float64
+	ineqMult []float64
+	penalty  float64
+}
+
+func (a *augLagObjective) ObjGrad(x []float64, grad []float64) float64 {
+	e := a.eval
+	e.evaluate(a.fun, a.c, x)
+	rho := a.penalty
+
+	obj := e.obj
+	copy(grad, e.grad)
+	for i, c := range e.eq {
+		// The gradient of -λ c + ρ/2 c^2 is -(λ - ρ c) ∇c
+		obj += -a.eqMult[i]*c + 0.5*rho*c*c
+		addScaledRow(grad, -(a.eqMult[i] - rho*c), e.eqJac, i)
+	}
+	for i, c := range e.ineq {
+		mu := a.ineqMult[i]
+		s := math.Max(0, mu-rho*c)
+		obj += (s*s - mu*mu) / (2 * rho)
+		if s > 0 {
+			addScaledRow(grad, -s, e.ineqJac, i)
+		}
+	}
+	return obj
+}
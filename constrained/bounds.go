@@ -0,0 +1,106 @@
+package constrained
+
+import (
+	"math"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+// Bounded adds bounds on the variables to a set of constraints. The bounds
+// are inequality constraints that follow the inequality constraints of
+// Constraints, first x_i - Lower_i >= 0 for every finite lower bound and then
+// Upper_i - x_i >= 0 for every finite upper bound. Constraints may be nil if
+// the problem only has bounds.
+type Bounded struct {
+	Constraints
+	Lower []float64
+	Upper []float64
+
+	jac *mat64.Dense
+}
+
+// NewBounded returns the constraints c with the given bounds. Either bound
+// may be nil, and infinite bounds are ignored.
+func NewBounded(c Constraints, lower, upper []float64) *Bounded {
+	return &Bounded{
+		Constraints: c,
+		Lower:       lower,
+		Upper:       upper,
+	}
+}
+
+func (b *Bounded) NumEquality() int {
+	if b.Constraints == nil {
+		return 0
+	}
+	return b.Constraints.NumEquality()
+}
+
+func (b *Bounded) NumInequality() int {
+	return b.numInner() + numFinite(b.Lower) + numFinite(b.Upper)
+}
+
+func (b *Bounded) numInner() int {
+	if b.Constraints == nil {
+		return 0
+	}
+	return b.Constraints.NumInequality()
+}
+
+func (b *Bounded) Equality(dst []float64, jac *mat64.Dense, x []float64) {
+	if b.Constraints != nil {
+		b.Constraints.Equality(dst, jac, x)
+	}
+}
+
+func (b *Bounded) Inequality(dst []float64, jac *mat64.Dense, x []float64) {
+	n := b.numInner()
+	if n > 0 {
+		if jac == nil {
+			b.Constraints.Inequality(dst[:n], nil, x)
+		} else {
+			// The inner constraints need a Jacobian of their own size
+			if b.jac == nil {
+				b.jac = mat64.NewDense(n, len(x), nil)
+			} else if r, c := b.jac.Dims(); r != n || c != len(x) {
+				b.jac = mat64.NewDense(n, len(x), nil)
+			}
+			b.Constraints.Inequality(dst[:n], b.jac, x)
+			for i := 0; i < n; i++ {
+				for j := range x {
+					jac.Set(i, j, b.jac.At(i, j))
+				}
+			}
+		}
+	}
+
+	row := n
+	for _, bound := range []struct {
+		b    []float64
+		sign float64
+	}{{b.Lower, 1}, {b.Upper, -1}} {
+		for i, v := range bound.b {
+			if math.IsInf(v, 0) {
+				continue
+			}
+			dst[row] = bound.sign * (x[i] - v)
+			if jac != nil {
+				for j := range x {
+					jac.Set(row, j, 0)
+				}
+				jac.Set(row, i, bound.sign)
+			}
+			row++
+		}
+	}
+}
+
+func numFinite(s []float64) int {
+	var n int
+	for _, v := range s {
+		if !math.IsInf(v, 0) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,251 @@
+package constrained
+
+import (
+	"math"
+
+	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/write"
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+// Constraints represents the constraints of an optimization problem. The
+// equality constraints are c_E(x) = 0 and the inequality constraints are
+// c_I(x) >= 0.
+type Constraints interface {
+	NumEquality() int
+	NumInequality() int
+
+	// Equality puts the values of the equality constraints at x in dst. If
+	// jac is not nil, the Jacobian of the constraints is put in jac, which is
+	// NumEquality × len(x)
+	Equality(dst []float64, jac *mat64.Dense, x []float64)
+
+	// Inequality puts the values of the inequality constraints at x in dst.
+	// If jac is not nil, the Jacobian of the constraints is put in jac, which
+	// is NumInequality × len(x)
+	Inequality(dst []float64, jac *mat64.Dense, x []float64)
+}
+
+// Settings is a structure containing settings for constrained optimizers.
+// The gradient tolerances apply to the gradient of the Lagrangian, and only
+// once the constraint violation is less than ConstraintTol and the
+// complementarity is less than ComplementarityTol.
+type Settings struct {
+	*common.CommonSettings
+	*common.SingleOutputSettings
+	ConstraintTol      float64 // Maximum constraint violation of a feasible location
+	ComplementarityTol float64 // Maximum product of an inequality multiplier and its constraint at a solution
+}
+
+// DefaultSettings returns the default settings for constrained optimizers
+func DefaultSettings() *Settings {
+	return &Settings{
+		CommonSettings:       common.DefaultCommonSettings(),
+		SingleOutputSettings: common.DefaultSingleOutputSettings(),
+		ConstraintTol:        1e-6,
+		ComplementarityTol:   1e-6,
+	}
+}
+
+// Helper is a helper struct for constrained optimizers. Not intended for use
+// by callers of optimization functions, but exported to aid others who are
+// building optimization algorithms. It is used in the same way as
+// multivariate.Helper.
+//
+// If the optimization converged, the result is the final location. Otherwise
+// it is the feasible location with the lowest objective value, or if no
+// feasible location has been found, the location with the lowest constraint
+// violation.
+type Helper struct {
+	*common.Common
+	*common.SingleOutput
+
+	constraintTol      float64
+	complementarityTol float64
+
+	curr iterate
+	best iterate
+}
+
+// iterate is the state of the optimization at one location
+type iterate struct {
+	obj       float64
+	loc       []float64
+	grad      []float64
+	eqMult    []float64
+	ineqMult  []float64
+	gradNrm   float64
+	violation float64
+	compl     float64
+}
+
+func (it *iterate) set(loc []float64, obj float64, grad, eqMult, ineqMult []float64, gradNrm, violation, compl float64) {
+	// Copy the values, as the optimizers are free to reuse the slices
+	it.obj = obj
+	it.loc = append(it.loc[:0], loc...)
+	it.grad = append(it.grad[:0], grad...)
+	it.eqMult = append(it.eqMult[:0], eqMult...)
+	it.ineqMult = append(it.ineqMult[:0], ineqMult...)
+	it.gradNrm = gradNrm
+	it.violation = violation
+	it.compl = compl
+}
+
+// NewHelper creates a new Helper and adds itself to the data adders
+func NewHelper() *Helper {
+	h := &Helper{
+		Common:       common.NewCommon(),
+		SingleOutput: common.NewSingleOutput(),
+	}
+	h.AddDataAdder(h)
+	return h
+}
+
+func (h *Helper) AppendWriteData(v []*write.Value) []*write.Value {
+	v = append(v, &write.Value{Heading: "Obj", Value: h.best.obj})
+	v = append(v, &write.Value{Heading: "Grad", Value: h.best.gradNrm})
+	v = append(v, &write.Value{Heading: "Violation", Value: h.best.violation})
+	return v
+}
+
+// Init initializes the helper at the start of an optimization. initGrad is
+// the gradient of the Lagrangian at the initial location, which with zero
+// multipliers is the gradient of the objective.
+func (h *Helper) Init(s *Settings, objectiveFunction interface{}, initLoc []float64, initObj float64, initGrad []float64, initViolation float64) {
+	h.Common.Init(s.CommonSettings, objectiveFunction)
+
+	gradNrm := floats.Norm(initGrad, 2)
+	h.SingleOutput.Init(s.SingleOutputSettings, initObj, gradNrm)
+
+	h.constraintTol = s.ConstraintTol
+	h.complementarityTol = s.ComplementarityTol
+	h.curr.set(initLoc, initObj, initGrad, nil, nil, gradNrm, initViolation, 0)
+	h.best.set(initLoc, initObj, initGrad, nil, nil, gradNrm, initViolation, 0)
+}
+
+// Iterate updates the helper after an iteration. grad is the gradient of the
+// Lagrangian with the multipliers eqMult and ineqMult, and compl is the
+// largest product of an inequality multiplier and its constraint.
+func (h *Helper) Iterate(loc []float64, obj float64, grad, eqMult, ineqMult []float64, violation, compl float64, nFunEvals int) {
+	h.Common.Iterate(nFunEvals)
+	gradNrm := floats.Norm(grad, 2)
+	h.SingleOutput.Iterate(gradNrm, obj)
+
+	h.curr.set(loc, obj, grad, eqMult, ineqMult, gradNrm, violation, compl)
+	if h.better(obj, violation) {
+		h.best.set(loc, obj, grad, eqMult, ineqMult, gradNrm, violation, compl)
+	}
+}
+
+// better returns whether a location with the given objective value and
+// constraint violation is better than the best location so far
+func (h *Helper) better(obj, violation float64) bool {
+	feasible := violation <= h.constraintTol
+	bestFeasible := h.best.violation <= h.constraintTol
+	switch {
+	case feasible && bestFeasible:
+		return obj <= h.best.obj
+	case feasible:
+		return true
+	case bestFeasible:
+		return false
+	default:
+		return violation <= h.best.violation
+	}
+}
+
+// Status returns the status of the optimization. The tolerances on the
+// objective and gradient are only checked when the current location is
+// feasible and complementarity holds.
+func (h *Helper) Status() common.Status {
+	if h.curr.violation <= h.constraintTol && h.curr.compl <= h.complementarityTol {
+		status := h.SingleOutput.Status()
+		if status != common.Continue {
+			return status
+		}
+	}
+	return h.Common.Status()
+}
+
+func (h *Helper) Result(status common.Status) *Result {
+	it := h.best
+	if status > 0 {
+		it = h.curr
+	}
+	return &Result{
+		CommonResult:          h.Common.Result(status),
+		Obj:                   it.obj,
+		Loc:                   it.loc,
+		Grad:                  it.grad,
+		EqualityMultipliers:   it.eqMult,
+		InequalityMultipliers: it.ineqMult,
+		Violation:             it.violation,
+		Stationarity:          it.gradNrm,
+		Complementarity:       it.compl,
+	}
+}
+
+type Result struct {
+	*common.CommonResult
+	Obj                   float64   // Objective value at Loc
+	Loc                   []float64 // Best location found
+	Grad                  []float64 // Gradient of the Lagrangian at Loc
+	EqualityMultipliers   []float64 // Lagrange multipliers of the equality constraints at Loc
+	InequalityMultipliers []float64 // Lagrange multipliers of the inequality constraints at Loc
+
+	// Residuals of the KKT conditions at Loc
+	Violation       float64 // Largest constraint violation
+	Stationarity    float64 // Norm of the gradient of the Lagrangian
+	Complementarity float64 // Largest product of an inequality multiplier and its constraint
+}
+
+// Violation returns the largest violation of the constraints given their
+// values
+func Violation(eq, ineq []float64) float64 {
+	var v float64
+	for _, c := range eq {
+		v = math.Max(v, math.Abs(c))
+	}
+	for _, c := range ineq {
+		v = math.Max(v, -c)
+	}
+	return v
+}
+
+// complementarity returns the largest product of an inequality multiplier and
+// its constraint
+func complementarity(ineqMult, ineq []float64) float64 {
+	var v float64
+	for i, m := range ineqMult {
+		v = math.Max(v, math.Abs(m*ineq[i]))
+	}
+	return v
+}
+
+// lagrangianGrad puts the gradient of the Lagrangian
+//
+//	L(x, λ, μ) = f(x) - λ^T c_E(x) - μ^T c_I(x)
+//
+// in dst given the gradient of the objective and the constraint Jacobians
+func lagrangianGrad(dst, grad []float64, eqJac *mat64.Dense, eqMult []float64, ineqJac *mat64.Dense, ineqMult []float64) {
+	copy(dst, grad)
+	subJacTMul(dst, eqJac, eqMult)
+	subJacTMul(dst, ineqJac, ineqMult)
+}
+
+// subJacTMul subtracts jac^T * mult from dst
+func subJacTMul(dst []float64, jac *mat64.Dense, mult []float64) {
+	for i, m := range mult {
+		if m != 0 {
+			addScaledRow(dst, -m, jac, i)
+		}
+	}
+}
+
+// addScaledRow adds alpha times row i of m to dst
+func addScaledRow(dst []float64, alpha float64, m *mat64.Dense, i int) {
+	for j := range dst {
+		dst[j] += alpha * m.At(i, j)
+	}
+}
@@ -0,0 +1,397 @@
+package constrained
+
+import (
+	"math"
+	"testing"
+
+	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/multivariate"
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+// circleLinear minimizes x_0 + x_1 on the circle x_0^2 + x_1^2 = 2. The
+// minimum is at (-1, -1) with multiplier -0.5.
+type circleLinear struct{}
+
+func (circleLinear) ObjGrad(x, grad []float64) float64 {
+	grad[0] = 1
+	grad[1] = 1
+	return x[0] + x[1]
+}
+
+func (circleLinear) NumEquality() int   { return 1 }
+func (circleLinear) NumInequality() int { return 0 }
+
+func (circleLinear) Equality(dst []float64, jac *mat64.Dense, x []float64) {
+	dst[0] = x[0]*x[0] + x[1]*x[1] - 2
+	if jac != nil {
+		jac.Set(0, 0, 2*x[0])
+		jac.Set(0, 1, 2*x[1])
+	}
+}
+
+func (circleLinear) Inequality(dst []float64, jac *mat64.Dense, x []float64) {}
+
+// parabolaHalfplane minimizes (x_0-2)^2 + (x_1-1)^2 subject to x_1 >= x_0^2
+// and x_0 + x_1 <= 2. The minimum is at (1, 1) with both constraints active
+// and multipliers 2/3.
+type parabolaHalfplane struct{}
+
+func (parabolaHalfplane) ObjGrad(x, grad []float64) float64 {
+	grad[0] = 2 * (x[0] - 2)
+	grad[1] = 2 * (x[1] - 1)
+	return (x[0]-2)*(x[0]-2) + (x[1]-1)*(x[1]-1)
+}
+
+func (parabolaHalfplane) NumEquality() int   { return 0 }
+func (parabolaHalfplane) NumInequality() int { return 2 }
+
+func (parabolaHalfplane) Equality(dst []float64, jac *mat64.Dense, x []float64) {}
+
+func (parabolaHalfplane) Inequality(dst []float64, jac *mat64.Dense, x []float64) {
+	dst[0] = x[1] - x[0]*x[0]
+	dst[1] = 2 - x[0] - x[1]
+	if jac != nil {
+		jac.Set(0, 0, -2*x[0])
+		jac.Set(0, 1, 1)
+		jac.Set(1, 0, -1)
+		jac.Set(1, 1, -1)
+	}
+}
+
+// hs71 is problem 71 of Hock and Schittkowski, with an equality constraint,
+// an inequality constraint and the bounds 1 <= x_i <= 5. The minimum is
+// 17.0140173 at (1, 4.7429994, 3.8211503, 1.3794082).
+type hs71 struct{}
+
+func (hs71) ObjGrad(x, grad []float64) float64 {
+	grad[0] = x[3] * (2*x[0] + x[1] + x[2])
+	grad[1] = x[0] * x[3]
+	grad[2] = x[0]*x[3] + 1
+	grad[3] = x[0] * (x[0] + x[1] + x[2])
+	return x[0]*x[3]*(x[0]+x[1]+x[2]) + x[2]
+}
+
+func (hs71) NumEquality() int   { return 1 }
+func (hs71) NumInequality() int { return 1 }
+
+func (hs71) Equality(dst []float64, jac *mat64.Dense, x []float64) {
+	dst[0] = floats.Dot(x, x) - 40
+	if jac != nil {
+		for j, v := range x {
+			jac.Set(0, j, 2*v)
+		}
+	}
+}
+
+func (hs71) Inequality(dst []float64, jac *mat64.Dense, x []float64) {
+	dst[0] = x[0]*x[1]*x[2]*x[3] - 25
+	if jac != nil {
+		jac.Set(0, 0, x[1]*x[2]*x[3])
+		jac.Set(0, 1, x[0]*x[2]*x[3])
+		jac.Set(0, 2, x[0]*x[1]*x[3])
+		jac.Set(0, 3, x[0]*x[1]*x[2])
+	}
+}
+
+var constrainedTests = []struct {
+	name     string
+	f        multivariate.ObjGrader
+	c        Constraints
+	initLoc  []float64
+	optLoc   []float64
+	optObj   float64
+	eqMult   []float64
+	ineqMult []float64
+}{
+	{"circleLinear", circleLinear{}, circleLinear{}, []float64{2, 1}, []float64{-1, -1}, -2, []float64{-0.5}, nil},
+	{"parabolaHalfplane", parabolaHalfplane{}, parabolaHalfplane{}, []float64{0, 0}, []float64{1, 1}, 1, nil, []float64{2.0 / 3, 2.0 / 3}},
+	{
+		"hs71", hs71{}, NewBounded(hs71{}, []float64{1, 1, 1, 1}, []float64{5, 5, 5, 5}),
+		[]float64{1, 5, 5, 1}, []float64{1, 4.7429994, 3.8211503, 1.3794082}, 17.0140173, nil, nil,
+	},
+}
+
+// testConstrained runs the optimizer on the test problems except the ones
+// named in skip
+func testConstrained(t *testing.T, newOptimizer func() Optimizer, skip ...string) {
+tests:
+	for _, test := range constrainedTests {
+		for _, name := range skip {
+			if test.name == name {
+				continue tests
+			}
+		}
+		settings := DefaultSettings()
+		settings.DisplayWriters = nil
+		settings.MaximumIterations = 200
+		result, err := Optimize(test.f, test.c, test.initLoc, settings, newOptimizer())
+		if err != nil {
+			t.Errorf("%v: error optimizing: %v", test.name, err)
+			continue
+		}
+		if result.Status <= 0 {
+			t.Errorf("%v: optimization did not converge. Status %v", test.name, result.Status)
+		}
+		if result.Violation > settings.ConstraintTol {
+			t.Errorf("%v: infeasible result. Violation %v", test.name, result.Violation)
+		}
+		if !floats.EqualApprox(result.Loc, test.optLoc, 1e-4) {
+			t.Errorf("%v: location mismatch. Want %v, found %v", test.name, test.optLoc, result.Loc)
+		}
+		if math.Abs(result.Obj-test.optObj) > 1e-5*math.Max(1, math.Abs(test.optObj)) {
+			t.Errorf("%v: objective mismatch. Want %v, found %v", test.name, test.optObj, result.Obj)
+		}
+		if result.Complementarity > 1e-6 {
+			t.Errorf("%v: complementarity not satisfied: %v", test.name, result.Complementarity)
+		}
+		if test.eqMult != nil && !floats.EqualApprox(result.EqualityMultipliers, test.eqMult, 1e-4) {
+			t.Errorf("%v: equality multiplier mismatch. Want %v, found %v", test.name, test.eqMult, result.EqualityMultipliers)
+		}
+		if test.ineqMult != nil && !floats.EqualApprox(result.InequalityMultipliers, test.ineqMult, 1e-4) {
+			t.Errorf("%v: inequality multiplier mismatch. Want %v, found %v", test.name, test.ineqMult, result.InequalityMultipliers)
+		}
+	}
+}
+
+func TestAugmentedLagrangian(t *testing.T) {
+	testConstrained(t, func() Optimizer { return NewAugmentedLagrangian() })
+}
+
+func TestSQP(t *testing.T) {
+	testConstrained(t, func() Optimizer { return NewSQP() })
+}
+
+func TestInteriorPoint(t *testing.T) {
+	testConstrained(t, func() Optimizer { return NewInteriorPoint() })
+}
+
+// diskHalfplane has the constraints x_0^2 + x_1^2 <= 1 and x_0 >= 2, which
+// cannot both hold
+type diskHalfplane struct{}
+
+func (diskHalfplane) ObjGrad(x, grad []float64) float64 {
+	grad[0] = 1
+	grad[1] = 1
+	return x[0] + x[1]
+}
+
+func (diskHalfplane) NumEquality() int   { return 0 }
+func (diskHalfplane) NumInequality() int { return 2 }
+
+func (diskHalfplane) Equality(dst []float64, jac *mat64.Dense, x []float64) {}
+
+func (diskHalfplane) Inequality(dst []float64, jac *mat64.Dense, x []float64) {
+	dst[0] = 1 - x[0]*x[0] - x[1]*x[1]
+	dst[1] = x[0] - 2
+	if jac != nil {
+		jac.Set(0, 0, -2*x[0])
+		jac.Set(0, 1, -2*x[1])
+		jac.Set(1, 0, 1)
+		jac.Set(1, 1, 0)
+	}
+}
+
+func TestInteriorPointInfeasible(t *testing.T) {
+	settings := DefaultSettings()
+	settings.DisplayWriters = nil
+	settings.MaximumIterations = 200
+	result, err := Optimize(diskHalfplane{}, diskHalfplane{}, []float64{0, 0}, settings, NewInteriorPoint())
+	if err != nil {
+		t.Fatalf("error optimizing: %v", err)
+	}
+	if result.Status != common.Infeasible {
+		t.Errorf("status mismatch. Want %v, found %v", common.Infeasible, result.Status)
+	}
+}
+
+// dependentLines has the constraints 0.3 (x_0 + x_1 - 1) = 0 and
+// 0.1 (x_0 + x_1 - 1) = 0, whose Jacobian is rank deficient
+type dependentLines struct{}
+
+func (dependentLines) ObjGrad(x, grad []float64) float64 {
+	grad[0] = 2 * x[0]
+	grad[1] = 2 * x[1]
+	return x[0]*x[0] + x[1]*x[1]
+}
+
+func (dependentLines) NumEquality() int   { return 2 }
+func (dependentLines) NumInequality() int { return 0 }
+
+func (dependentLines) Equality(dst []float64, jac *mat64.Dense, x []float64) {
+	for i, a := range []float64{0.3, 0.1} {
+		dst[i] = a * (x[0] + x[1] - 1)
+		if jac != nil {
+			jac.Set(i, 0, a)
+			jac.Set(i, 1, a)
+		}
+	}
+}
+
+func (dependentLines) Inequality(dst []float64, jac *mat64.Dense, x []float64) {}
+
+func TestInteriorPointSingular(t *testing.T) {
+	settings := DefaultSettings()
+	settings.DisplayWriters = nil
+	_, err := Optimize(dependentLines{}, dependentLines{}, []float64{0, 0}, settings, NewInteriorPoint())
+	if err == nil {
+		t.Errorf("no error for linearly dependent equality constraints")
+	}
+}
+
+func TestPenaltyMethod(t *testing.T) {
+	testConstrained(t, func() Optimizer { return NewPenaltyMethod(NewL1Penalty()) })
+	testConstrained(t, func() Optimizer { return NewPenaltyMethod(&LogBarrier{}) })
+
+	// The quadratic penalty finds the solution of hs71, but the subproblems
+	// are too ill-conditioned to meet the gradient tolerance by then
+	testConstrained(t, func() Optimizer { return NewPenaltyMethod(&QuadraticPenalty{}) }, "hs71")
+	test := constrainedTests[2]
+	settings := DefaultSettings()
+	settings.DisplayWriters = nil
+	result, err := Optimize(test.f, test.c, test.initLoc, settings, NewPenaltyMethod(&QuadraticPenalty{}))
+	if err != nil {
+		t.Fatalf("error optimizing: %v", err)
+	}
+	if !floats.EqualApprox(result.Loc, test.optLoc, 1e-4) {
+		t.Errorf("quadratic penalty location mismatch. Want %v, found %v", test.optLoc, result.Loc)
+	}
+	if result.Violation > settings.ConstraintTol {
+		t.Errorf("quadratic penalty infeasible result. Violation %v", result.Violation)
+	}
+}
+
+// axisConstraints has the objective x_0^2 + x_0 x_1 + 2 x_1^2 with the
+// equality constraint x_0 - 1 = 0 and the inequality constraint x_1 >= 0
+type axisConstraints struct{}
+
+func (axisConstraints) ObjGrad(x, grad []float64) float64 {
+	grad[0] = 2*x[0] + x[1]
+	grad[1] = x[0] + 4*x[1]
+	return x[0]*x[0] + x[0]*x[1] + 2*x[1]*x[1]
+}
+
+func (axisConstraints) NumEquality() int   { return 1 }
+func (axisConstraints) NumInequality() int { return 1 }
+
+func (axisConstraints) Equality(dst []float64, jac *mat64.Dense, x []float64) {
+	dst[0] = x[0] - 1
+	if jac != nil {
+		jac.Set(0, 0, 1)
+		jac.Set(0, 1, 0)
+	}
+}
+
+func (axisConstraints) Inequality(dst []float64, jac *mat64.Dense, x []float64) {
+	dst[0] = x[1]
+	if jac != nil {
+		jac.Set(0, 0, 0)
+		jac.Set(0, 1, 1)
+	}
+}
+
+func TestPenaltyFunctions(t *testing.T) {
+	// With a weight of 10 the barrier threshold is 0.1, the same as the
+	// smoothing of the l1 penalty, so the constraint values are on both
+	// sides of the breakpoints
+	const weight = 10
+	l1 := NewL1Penalty()
+	l1.Smoothing = 0.1
+	cs := []float64{-0.3, -0.05, 0.05, 0.3}
+	for _, p := range []PenaltyFunction{&QuadraticPenalty{}, l1, &LogBarrier{}} {
+		p.Init(axisConstraints{}, axisConstraints{})
+		p.SetWeight(weight)
+		for _, c0 := range cs {
+			for _, c1 := range cs {
+				x := []float64{1 + c0, c1}
+
+				// Compare the gradient with central differences
+				const h = 1e-6
+				grad := make([]float64, 2)
+				fdGrad := make([]float64, 2)
+				xh := make([]float64, 2)
+				for i := range x {
+					copy(xh, x)
+					xh[i] = x[i] + h
+					fp := p.ObjGrad(xh, grad)
+					xh[i] = x[i] - h
+					fm := p.ObjGrad(xh, grad)
+					fdGrad[i] = (fp - fm) / (2 * h)
+				}
+				p.ObjGrad(x, grad)
+				if !floats.EqualApprox(grad, fdGrad, 1e-6) {
+					t.Errorf("%T at %v: gradient mismatch. Want %v, found %v", p, x, fdGrad, grad)
+				}
+
+				// The gradient of the penalty is the gradient of the
+				// Lagrangian with the multiplier estimates
+				eqMult := make([]float64, 1)
+				ineqMult := make([]float64, 1)
+				p.Multipliers(eqMult, ineqMult)
+				if eqMult[0]*c0 >= 0 {
+					t.Errorf("%T at %v: equality multiplier %v has the sign of the constraint %v", p, x, eqMult[0], c0)
+				}
+				if ineqMult[0] < 0 || (c1 < 0 && ineqMult[0] == 0) {
+					t.Errorf("%T at %v: bad inequality multiplier %v for constraint %v", p, x, ineqMult[0], c1)
+				}
+				lagGrad := make([]float64, 2)
+				axisConstraints{}.ObjGrad(x, lagGrad)
+				lagGrad[0] -= eqMult[0]
+				lagGrad[1] -= ineqMult[0]
+				if !floats.EqualApprox(grad, lagGrad, 1e-12) {
+					t.Errorf("%T at %v: gradient %v is not the gradient of the Lagrangian %v", p, x, grad, lagGrad)
+				}
+			}
+		}
+	}
+}
+
+// sumBelow is the constraint 1 - sum_i x_i >= 0 in any dimension
+type sumBelow struct{}
+
+func (sumBelow) NumEquality() int   { return 0 }
+func (sumBelow) NumInequality() int { return 1 }
+
+func (sumBelow) Equality(dst []float64, jac *mat64.Dense, x []float64) {}
+
+func (sumBelow) Inequality(dst []float64, jac *mat64.Dense, x []float64) {
+	dst[0] = 1 - floats.Sum(x)
+	if jac != nil {
+		for j := range x {
+			jac.Set(0, j, -1)
+		}
+	}
+}
+
+func TestBounded(t *testing.T) {
+	inf := math.Inf(1)
+	b := NewBounded(sumBelow{}, nil, nil)
+	// The same constraints are used in several dimensions
+	for _, test := range []struct {
+		lower, upper []float64
+		x            []float64
+		want         []float64
+	}{
+		{[]float64{0, -inf}, []float64{inf, 2}, []float64{0.5, 1}, []float64{-0.5, 0.5, 1}},
+		{[]float64{0, 0, 0}, nil, []float64{0.1, 0.2, 0.3}, []float64{0.4, 0.1, 0.2, 0.3}},
+	} {
+		b.Lower = test.lower
+		b.Upper = test.upper
+		n := b.NumInequality()
+		if n != len(test.want) {
+			t.Fatalf("Number of inequalities mismatch. Want %v, found %v", len(test.want), n)
+		}
+		dst := make([]float64, n)
+		jac := mat64.NewDense(n, len(test.x), nil)
+		b.Inequality(dst, jac, test.x)
+		if !floats.EqualApprox(dst, test.want, 1e-14) {
+			t.Errorf("Inequality mismatch. Want %v, found %v", test.want, dst)
+		}
+		for j := range test.x {
+			if jac.At(0, j) != -1 {
+				t.Errorf("Jacobian mismatch of the inner constraint in dimension %v", len(test.x))
+			}
+		}
+	}
+}
@@ -0,0 +1,445 @@
+package constrained
+
+import (
+	"errors"
+	"math"
+
+	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/multivariate"
+	"github.com/btracey/opt/write"
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+// InteriorPoint is a primal-dual interior-point method. The inequality
+// constraints are written as c_I(x) - s = 0 with slacks s > 0, and the method
+// solves a sequence of barrier problems
+//
+//	minimize    f(x) - μ Σ_j log s_j
+//	subject to  c_E(x) = 0, c_I(x) - s = 0
+//
+// for a decreasing barrier parameter μ. Every iteration takes one Newton step
+// on the primal-dual equations of the barrier problem, using a damped BFGS
+// approximation of the Hessian of the Lagrangian. The step is shortened by
+// the fraction-to-the-boundary rule so that s and the inequality multipliers
+// stay positive, and then backtracks until the merit function
+//
+//	φ(x, s) = f(x) - μ Σ_j log s_j + ν (||c_E(x)||_1 + ||c_I(x) - s||_1)
+//
+// decreases sufficiently. μ is decreased once the barrier problem has been
+// solved to within BarrierTol times μ. Bounds on the variables can be
+// included with Bounded.
+//
+// Status returns common.Infeasible when the iterates reach an infeasible point
+// at which the gradient of the constraint violation
+// 1/2 (||c_E||^2 + ||min(0, c_I)||^2) is less than InfeasibilityTol times the
+// norm of the violated constraints, or when the merit penalty exceeds
+// MaxPenalty. Like other local methods, InteriorPoint can also fail from
+// starting locations far outside the feasible region.
+type InteriorPoint struct {
+	InitialBarrier  float64 // Initial value of the barrier parameter μ
+	MinBarrier      float64 // Smallest value of the barrier parameter
+	BarrierTol      float64 // μ is decreased once the error of the barrier problem is less than BarrierTol*μ
+	BarrierDecrease float64 // Linear decrease factor of the barrier parameter
+	BarrierPower    float64 // Superlinear decrease power of the barrier parameter
+	MinBoundary     float64 // Smallest fraction-to-the-boundary parameter
+
+	Decrease float64 // Sufficient decrease parameter of the merit function linesearch
+	Contract float64 // Factor by which the step is decreased during the linesearch
+	MinStep  float64 // Smallest step of the linesearch before failing
+
+	MaxPenalty       float64 // Largest merit function penalty before the problem is declared infeasible
+	InfeasibilityTol float64 // Relative tolerance on the gradient of the violation for detecting infeasibility
+
+	fun   multivariate.ObjGrader
+	c     Constraints
+	nDim  int
+	nEq   int
+	nIneq int
+
+	loc      []float64
+	slack    []float64
+	eqMult   []float64
+	ineqMult []float64
+	curr     *evaluation
+	trial    *evaluation
+	hess     *mat64.Dense // Approximation of the Hessian of the Lagrangian
+	barrier  float64      // Barrier parameter μ
+	penalty  float64      // Merit function penalty ν
+	step     float64
+
+	infeasible bool
+
+	px         []float64
+	ps         []float64
+	py         []float64
+	pz         []float64
+	trialLoc   []float64
+	trialSlack []float64
+	s          []float64
+	y          []float64
+	bs         []float64
+	lagGrad    []float64
+}
+
+const (
+	minSlackStep = 0.1  // Smallest fraction to the boundary step before the Newton step is relaxed
+	minRelax     = 1e-3 // Smallest relaxation of the constraint residuals in the Newton step
+)
+
+// NewInteriorPoint returns a new InteriorPoint with the default parameters
+func NewInteriorPoint() *InteriorPoint {
+	return &InteriorPoint{
+		InitialBarrier:  0.1,
+		MinBarrier:      1e-11,
+		BarrierTol:      10,
+		BarrierDecrease: 0.2,
+		BarrierPower:    1.5,
+		MinBoundary:     0.99,
+
+		Decrease: 1e-4,
+		Contract: 0.5,
+		MinStep:  1e-12,
+
+		MaxPenalty:       1e10,
+		InfeasibilityTol: 1e-6,
+	}
+}
+
+func (ip *InteriorPoint) Init(f multivariate.ObjGrader, c Constraints, initLoc []float64, initObj float64, initGrad []float64) error {
+	if ip.InitialBarrier <= 0 || ip.MinBarrier <= 0 || ip.MinBarrier > ip.InitialBarrier {
+		return errors.New("interiorpoint: bad barrier parameter")
+	}
+	if ip.BarrierDecrease <= 0 || ip.BarrierDecrease >= 1 || ip.BarrierPower <= 1 || ip.BarrierPower >= 2 {
+		return errors.New("interiorpoint: bad barrier decrease")
+	}
+	if ip.MinBoundary <= 0 || ip.MinBoundary >= 1 {
+		return errors.New("interiorpoint: fraction to the boundary must be between zero and one")
+	}
+	if ip.Contract <= 0 || ip.Contract >= 1 {
+		return errors.New("interiorpoint: contraction factor must be between zero and one")
+	}
+	ip.fun = f
+	ip.c = c
+	ip.nDim = len(initLoc)
+	ip.nEq = c.NumEquality()
+	ip.nIneq = c.NumInequality()
+	n := ip.nDim
+
+	ip.loc = append(ip.loc[:0], initLoc...)
+	ip.curr = newEvaluation(n, ip.nEq, ip.nIneq)
+	ip.trial = newEvaluation(n, ip.nEq, ip.nIneq)
+	ip.curr.evaluate(f, c, ip.loc)
+
+	ip.hess = mat64.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		ip.hess.Set(i, i, 1)
+	}
+	ip.barrier = ip.InitialBarrier
+	ip.penalty = 0
+	ip.step = 0
+	ip.infeasible = false
+
+	// Start the slacks at the constraint values but away from the boundary,
+	// and the multipliers on the central path
+	ip.slack = make([]float64, ip.nIneq)
+	ip.ineqMult = make([]float64, ip.nIneq)
+	for i, v := range ip.curr.ineq {
+		ip.slack[i] = math.Max(v, 1)
+		ip.ineqMult[i] = ip.barrier / ip.slack[i]
+	}
+	ip.eqMult = make([]float64, ip.nEq)
+
+	ip.px = make([]float64, n)
+	ip.ps = make([]float64, ip.nIneq)
+	ip.py = make([]float64, ip.nEq)
+	ip.pz = make([]float64, ip.nIneq)
+	ip.trialLoc = make([]float64, n)
+	ip.trialSlack = make([]float64, ip.nIneq)
+	ip.s = make([]float64, n)
+	ip.y = make([]float64, n)
+	ip.bs = make([]float64, n)
+	ip.lagGrad = make([]float64, n)
+	return nil
+}
+
+func (ip *InteriorPoint) Status() common.Status {
+	if ip.infeasible {
+		return common.Infeasible
+	}
+	return common.Continue
+}
+
+func (ip *InteriorPoint) Iterate(loc, grad, eqMult, ineqMult []float64) (obj, violation float64, nFunEvals int, err error) {
+	if len(loc) != ip.nDim {
+		panic("dimension mismatch")
+	}
+	curr := ip.curr
+
+	// Decrease the barrier parameter while the barrier problem is solved
+	for ip.barrier > ip.MinBarrier && ip.barrierError() <= ip.BarrierTol*ip.barrier {
+		ip.barrier = math.Max(ip.MinBarrier, math.Min(ip.BarrierDecrease*ip.barrier, math.Pow(ip.barrier, ip.BarrierPower)))
+	}
+	mu := ip.barrier
+
+	// Compute the Newton step with the fraction to the boundary rule. Far
+	// from feasibility the linearized constraints can force the slacks to
+	// the boundary, in which case the constraint residuals are relaxed by
+	// theta until a reasonable step can be taken.
+	tau := math.Max(ip.MinBoundary, 1-mu)
+	var alphaS float64
+	theta := 1.0
+	for {
+		if err := ip.newtonStep(theta); err != nil {
+			return math.NaN(), math.NaN(), 0, err
+		}
+		alphaS = maxStep(ip.slack, ip.ps, tau)
+		if alphaS >= minSlackStep || theta <= minRelax {
+			break
+		}
+		theta *= 0.1
+	}
+	alphaZ := maxStep(ip.ineqMult, ip.pz, tau)
+
+	// Choose the penalty so that the step is a descent direction of the
+	// merit function
+	linViolation := ip.meritViolation(curr, ip.slack)
+	deriv := floats.Dot(curr.grad, ip.px)
+	curv := 0.0
+	for i := 0; i < ip.nDim; i++ {
+		for j := 0; j < ip.nDim; j++ {
+			curv += ip.px[i] * ip.hess.At(i, j) * ip.px[j]
+		}
+	}
+	for i, p := range ip.ps {
+		deriv -= mu * p / ip.slack[i]
+		curv += ip.ineqMult[i] / ip.slack[i] * p * p
+	}
+	if linViolation > 0 {
+		if need := (deriv + 0.5*math.Max(curv, 0)) / (0.9 * theta * linViolation); ip.penalty < need {
+			ip.penalty = 2 * need
+		}
+	}
+	if ip.penalty > ip.MaxPenalty {
+		ip.infeasible = true
+	}
+	deriv -= ip.penalty * theta * linViolation
+
+	// Backtracking linesearch on the merit function
+	merit := ip.merit(curr, ip.slack)
+	alpha := alphaS
+	for {
+		for i := range ip.trialLoc {
+			ip.trialLoc[i] = ip.loc[i] + alpha*ip.px[i]
+		}
+		for i := range ip.trialSlack {
+			ip.trialSlack[i] = ip.slack[i] + alpha*ip.ps[i]
+		}
+		ip.trial.evaluate(ip.fun, ip.c, ip.trialLoc)
+		nFunEvals++
+		if ip.merit(ip.trial, ip.trialSlack) <= merit+ip.Decrease*alpha*math.Min(deriv, 0) {
+			break
+		}
+		alpha *= ip.Contract
+		if alpha < ip.MinStep {
+			if curr.violation() > 0 && ip.violationStationary(curr) {
+				// No progress can be made on the constraint violation
+				ip.infeasible = true
+				return ip.output(loc, grad, eqMult, ineqMult, nFunEvals)
+			}
+			return math.NaN(), math.NaN(), nFunEvals, errors.New("interiorpoint: merit function linesearch failed")
+		}
+	}
+	ip.step = alpha
+
+	// Update the multipliers and keep the inequality multipliers close to
+	// the central path
+	floats.AddScaled(ip.eqMult, alphaZ, ip.py)
+	for i, p := range ip.pz {
+		z := ip.ineqMult[i] + alphaZ*p
+		s := ip.trialSlack[i]
+		ip.ineqMult[i] = math.Max(mu/(1e10*s), math.Min(z, 1e10*mu/s))
+	}
+
+	// Damped BFGS update of the Hessian of the Lagrangian with the new
+	// multipliers
+	for i := range ip.s {
+		ip.s[i] = alpha * ip.px[i]
+	}
+	lagrangianGrad(ip.lagGrad, curr.grad, curr.eqJac, ip.eqMult, curr.ineqJac, ip.ineqMult)
+	lagrangianGrad(ip.y, ip.trial.grad, ip.trial.eqJac, ip.eqMult, ip.trial.ineqJac, ip.ineqMult)
+	floats.Sub(ip.y, ip.lagGrad)
+	dampedBFGSUpdate(ip.hess, ip.s, ip.y, ip.bs)
+
+	ip.curr, ip.trial = ip.trial, ip.curr
+	copy(ip.loc, ip.trialLoc)
+	// Resetting the slacks to at least the constraint values can only
+	// decrease the merit function
+	for i, v := range ip.curr.ineq {
+		ip.slack[i] = math.Max(ip.trialSlack[i], v)
+	}
+
+	if ip.curr.violation() > 0 && ip.violationStationary(ip.curr) {
+		ip.infeasible = true
+	}
+	return ip.output(loc, grad, eqMult, ineqMult, nFunEvals)
+}
+
+// output puts the current state of the optimizer into the arguments of Iterate
+func (ip *InteriorPoint) output(loc, grad, eqMult, ineqMult []float64, nFunEvals int) (obj, violation float64, nEvals int, err error) {
+	copy(loc, ip.loc)
+	copy(eqMult, ip.eqMult)
+	copy(ineqMult, ip.ineqMult)
+	lagrangianGrad(grad, ip.curr.grad, ip.curr.eqJac, eqMult, ip.curr.ineqJac, ineqMult)
+	return ip.curr.obj, ip.curr.violation(), nFunEvals, nil
+}
+
+// newtonStep computes the primal-dual step of the barrier problem with the
+// constraint residuals scaled by theta. With Σ = S^-1 Z, the step in the
+// slacks and inequality multipliers is eliminated and the condensed system
+//
+//	[W + A_I^T Σ A_I  -A_E^T] [p_x]   [-∇f + A_E^T y + A_I^T (μ S^-1 e - θ Σ (c_I - s))]
+//	[A_E                  0 ] [p_y] = [-θ c_E                                          ]
+//
+// is solved, after which p_s = A_I p_x + θ (c_I - s) and
+// p_z = μ S^-1 e - z - Σ p_s.
+func (ip *InteriorPoint) newtonStep(theta float64) error {
+	n := ip.nDim
+	curr := ip.curr
+	mu := ip.barrier
+
+	kkt := mat64.NewDense(n+ip.nEq, n+ip.nEq, nil)
+	rhs := make([]float64, n+ip.nEq)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			kkt.Set(i, j, ip.hess.At(i, j))
+		}
+		rhs[i] = -curr.grad[i]
+	}
+	for k := 0; k < ip.nEq; k++ {
+		addScaledRow(rhs[:n], ip.eqMult[k], curr.eqJac, k)
+		for j := 0; j < n; j++ {
+			a := curr.eqJac.At(k, j)
+			kkt.Set(j, n+k, -a)
+			kkt.Set(n+k, j, a)
+		}
+		rhs[n+k] = -theta * curr.eq[k]
+	}
+	for k := 0; k < ip.nIneq; k++ {
+		s := ip.slack[k]
+		sigma := ip.ineqMult[k] / s
+		addScaledRow(rhs[:n], mu/s-theta*sigma*(curr.ineq[k]-s), curr.ineqJac, k)
+		for i := 0; i < n; i++ {
+			ai := sigma * curr.ineqJac.At(k, i)
+			if ai == 0 {
+				continue
+			}
+			for j := 0; j < n; j++ {
+				kkt.Set(i, j, kkt.At(i, j)+ai*curr.ineqJac.At(k, j))
+			}
+		}
+	}
+	lu, singular := common.LU(kkt)
+	if singular {
+		return errors.New("interiorpoint: singular Newton system")
+	}
+	sol := lu.Solve(mat64.NewDense(n+ip.nEq, 1, rhs))
+	for i := range ip.px {
+		ip.px[i] = sol.At(i, 0)
+	}
+	for k := range ip.py {
+		ip.py[k] = sol.At(n+k, 0)
+	}
+
+	for k := 0; k < ip.nIneq; k++ {
+		s := ip.slack[k]
+		var ap float64
+		for j, p := range ip.px {
+			ap += curr.ineqJac.At(k, j) * p
+		}
+		ip.ps[k] = ap + theta*(curr.ineq[k]-s)
+		ip.pz[k] = mu/s - ip.ineqMult[k] - ip.ineqMult[k]/s*ip.ps[k]
+	}
+	return nil
+}
+
+// barrierError returns the largest residual of the primal-dual equations of
+// the barrier problem at the current iterate
+func (ip *InteriorPoint) barrierError() float64 {
+	curr := ip.curr
+	lagrangianGrad(ip.lagGrad, curr.grad, curr.eqJac, ip.eqMult, curr.ineqJac, ip.ineqMult)
+	e := floats.Norm(ip.lagGrad, math.Inf(1))
+	for _, c := range curr.eq {
+		e = math.Max(e, math.Abs(c))
+	}
+	for i, c := range curr.ineq {
+		e = math.Max(e, math.Abs(c-ip.slack[i]))
+		e = math.Max(e, math.Abs(ip.slack[i]*ip.ineqMult[i]-ip.barrier))
+	}
+	return e
+}
+
+// merit returns the merit function at the evaluation e with slacks s
+func (ip *InteriorPoint) merit(e *evaluation, s []float64) float64 {
+	phi := e.obj + ip.penalty*ip.meritViolation(e, s)
+	for _, v := range s {
+		phi -= ip.barrier * math.Log(v)
+	}
+	return phi
+}
+
+// meritViolation returns the l1 norm of the residuals of the constraints of
+// the barrier problem
+func (ip *InteriorPoint) meritViolation(e *evaluation, s []float64) float64 {
+	var v float64
+	for _, c := range e.eq {
+		v += math.Abs(c)
+	}
+	for i, c := range e.ineq {
+		v += math.Abs(c - s[i])
+	}
+	return v
+}
+
+// violationStationary returns whether the gradient of the squared constraint
+// violation at e is small relative to the violation, which happens at local
+// minimizers of the violation
+func (ip *InteriorPoint) violationStationary(e *evaluation) bool {
+	g := ip.s
+	for i := range g {
+		g[i] = 0
+	}
+	var nrm float64
+	for k, c := range e.eq {
+		addScaledRow(g, c, e.eqJac, k)
+		nrm += c * c
+	}
+	for k, c := range e.ineq {
+		if c < 0 {
+			addScaledRow(g, c, e.ineqJac, k)
+			nrm += c * c
+		}
+	}
+	return floats.Norm(g, 2) <= ip.InfeasibilityTol*math.Sqrt(nrm)
+}
+
+// maxStep returns the largest step in (0, 1] such that v + alpha*p >=
+// (1-tau)*v
+func maxStep(v, p []float64, tau float64) float64 {
+	alpha := 1.0
+	for i, pi := range p {
+		if pi < 0 {
+			alpha = math.Min(alpha, -tau*v[i]/pi)
+		}
+	}
+	return alpha
+}
+
+func (ip *InteriorPoint) AppendWriteData(v []*write.Value) []*write.Value {
+	v = append(v, &write.Value{Heading: "Step", Value: ip.step})
+	v = append(v, &write.Value{Heading: "Barrier", Value: ip.barrier})
+	v = append(v, &write.Value{Heading: "Penalty", Value: ip.penalty})
+	return v
+}
+
+func (ip *InteriorPoint) Result() {}
@@ -0,0 +1,207 @@
+package constrained
+
+import (
+	"errors"
+	"math"
+
+	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/multivariate"
+	"github.com/btracey/opt/write"
+	"github.com/gonum/matrix/mat64"
+)
+
+// Optimizer represents an optimizer for constrained problems
+type Optimizer interface {
+	Init(f multivariate.ObjGrader, c Constraints, initLoc []float64, initObj float64, initGrad []float64) error
+	Status() common.Status
+	// Iterate puts the new location in loc, the gradient of the Lagrangian at
+	// loc in grad and the current estimates of the multipliers in eqMult and
+	// ineqMult. It returns the objective value and constraint violation at loc
+	Iterate(loc, grad, eqMult, ineqMult []float64) (obj, violation float64, nFunEvals int, err error)
+	Result()
+}
+
+// Wrapper is a convenience wrapper around a constrained optimizer that allows
+// more fine-grained control over optimization progress. See Optimize for
+// example usage
+type Wrapper struct {
+	optimizer Optimizer
+	helper    *Helper
+	c         Constraints
+	ineq      []float64
+}
+
+// NewWrapper creates a new wrapper around the optimizer. If the optimizer is
+// a write.DataAdder, its data is added to the display
+func NewWrapper(optimizer Optimizer) *Wrapper {
+	w := &Wrapper{
+		optimizer: optimizer,
+		helper:    NewHelper(),
+	}
+	if dataAdder, ok := optimizer.(write.DataAdder); ok {
+		w.helper.AddDataAdder(dataAdder)
+	}
+	return w
+}
+
+func (w *Wrapper) Init(settings *Settings, fun multivariate.ObjGrader, c Constraints, initLoc []float64) error {
+	init := newEvaluation(len(initLoc), c.NumEquality(), c.NumInequality())
+	init.evaluate(fun, c, initLoc)
+	w.c = c
+	w.ineq = make([]float64, c.NumInequality())
+
+	w.helper.Init(settings, fun, initLoc, init.obj, init.grad, init.violation())
+	return w.optimizer.Init(fun, c, initLoc, init.obj, init.grad)
+}
+
+func (w *Wrapper) Status() common.Status {
+	return common.CheckStatus(w.helper, w.optimizer)
+}
+
+func (w *Wrapper) Iterate(loc, grad, eqMult, ineqMult []float64) (obj float64, err error) {
+	var violation float64
+	var nFunEvals int
+	obj, violation, nFunEvals, err = w.optimizer.Iterate(loc, grad, eqMult, ineqMult)
+	if err != nil {
+		return obj, errors.New("error iterating optimizer: " + err.Error())
+	}
+	var compl float64
+	if len(w.ineq) > 0 {
+		w.c.Inequality(w.ineq, nil, loc)
+		compl = complementarity(ineqMult, w.ineq)
+	}
+	w.helper.Iterate(loc, obj, grad, eqMult, ineqMult, violation, compl, nFunEvals)
+	return obj, nil
+}
+
+func (w *Wrapper) Result(status common.Status) *Result {
+	w.optimizer.Result()
+	return w.helper.Result(status)
+}
+
+// Optimize minimizes f subject to the constraints c starting from initLoc.
+// The initial location need not be feasible.
+func Optimize(f multivariate.ObjGrader, c Constraints, initLoc []float64, settings *Settings, optimizer Optimizer) (*Result, error) {
+	if optimizer == nil {
+		optimizer = NewAugmentedLagrangian()
+	}
+	if settings == nil {
+		settings = DefaultSettings()
+	}
+	if initLoc == nil {
+		return nil, errors.New("nil init loc")
+	}
+	if f == nil {
+		return nil, errors.New("objective function is nil")
+	}
+	if c == nil {
+		return nil, errors.New("constraints are nil")
+	}
+
+	wrapper := NewWrapper(optimizer)
+	err := wrapper.Init(settings, f, c, initLoc)
+	if err != nil {
+		return nil, errors.New("error initializing: " + err.Error())
+	}
+	loc := make([]float64, len(initLoc))
+	grad := make([]float64, len(initLoc))
+	eqMult := make([]float64, c.NumEquality())
+	ineqMult := make([]float64, c.NumInequality())
+
+	var status common.Status
+	for {
+		status = wrapper.Status()
+		if status != common.Continue {
+			break
+		}
+		_, err := wrapper.Iterate(loc, grad, eqMult, ineqMult)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return wrapper.Result(status), nil
+}
+
+// evaluation holds the objective, constraints and their derivatives at a
+// location
+type evaluation struct {
+	obj     float64
+	grad    []float64
+	eq      []float64
+	ineq    []float64
+	eqJac   *mat64.Dense
+	ineqJac *mat64.Dense
+}
+
+func newEvaluation(nDim, nEq, nIneq int) *evaluation {
+	e := &evaluation{
+		grad: make([]float64, nDim),
+		eq:   make([]float64, nEq),
+		ineq: make([]float64, nIneq),
+	}
+	// mat64 does not allow matrices with zero rows
+	if nEq > 0 {
+		e.eqJac = mat64.NewDense(nEq, nDim, nil)
+	}
+	if nIneq > 0 {
+		e.ineqJac = mat64.NewDense(nIneq, nDim, nil)
+	}
+	return e
+}
+
+// evaluate evaluates the objective, constraints and derivatives at x
+func (e *evaluation) evaluate(f multivariate.ObjGrader, c Constraints, x []float64) {
+	e.obj = f.ObjGrad(x, e.grad)
+	if len(e.eq) > 0 {
+		c.Equality(e.eq, e.eqJac, x)
+	}
+	if len(e.ineq) > 0 {
+		c.Inequality(e.ineq, e.ineqJac, x)
+	}
+}
+
+// violation returns the constraint violation at the evaluated location
+func (e *evaluation) violation() float64 {
+	return Violation(e.eq, e.ineq)
+}
+
+// subproblem is an unconstrained subproblem of a constrained optimizer. It
+// records the best location seen in case the inner optimization fails.
+type subproblem struct {
+	multivariate.ObjGrader
+
+	nFunEvals int
+	bestLoc   []float64
+	bestObj   float64
+}
+
+func (s *subproblem) ObjGrad(x, grad []float64) float64 {
+	s.nFunEvals++
+	obj := s.ObjGrader.ObjGrad(x, grad)
+	if obj < s.bestObj {
+		s.bestObj = obj
+		s.bestLoc = append(s.bestLoc[:0], x...)
+	}
+	return obj
+}
+
+// minimize minimizes the subproblem with the inner optimizer starting from loc
+// and puts the solution in loc
+func (s *subproblem) minimize(loc []float64, settings *multivariate.Settings, inner multivariate.GradOptimizer) error {
+	s.nFunEvals = 0
+	s.bestObj = math.Inf(1)
+	result, err := multivariate.OptimizeGrad(s, loc, settings, inner)
+	switch {
+	case err == nil && result.Loc != nil:
+		copy(loc, result.Loc)
+	case !math.IsInf(s.bestObj, 1):
+		// The inner optimization can fail when the subproblem becomes
+		// ill-conditioned, in which case the best location seen is used
+		copy(loc, s.bestLoc)
+	case err != nil:
+		return err
+	default:
+		return errors.New("no location found")
+	}
+	return nil
+}
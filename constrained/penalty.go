@@ -0,0 +1,344 @@
+package constrained
+
+import (
+	"errors"
+	"math"
+
+	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/multivariate"
+	"github.com/btracey/opt/write"
+)
+
+// PenaltyFunction turns a constrained problem into an unconstrained one by
+// adding a term to the objective that grows with the constraint violation.
+// The term is scaled by a weight, and the minimizers of the penalized
+// objective approach the solution of the constrained problem as the weight
+// increases.
+type PenaltyFunction interface {
+	multivariate.ObjGrader
+
+	// Init sets the problem to penalize
+	Init(f multivariate.ObjGrader, c Constraints)
+
+	// SetWeight sets the weight of the penalty
+	SetWeight(w float64)
+
+	// Multipliers puts the estimates of the Lagrange multipliers at the
+	// location of the last call to ObjGrad in eqMult and ineqMult
+	Multipliers(eqMult, ineqMult []float64)
+}
+
+// penaltyBase holds the problem and the constraint values at the last
+// evaluated location
+type penaltyBase struct {
+	fun    multivariate.ObjGrader
+	c      Constraints
+	eval   *evaluation
+	weight float64
+}
+
+func (p *penaltyBase) Init(f multivariate.ObjGrader, c Constraints) {
+	p.fun = f
+	p.c = c
+	p.eval = nil
+}
+
+func (p *penaltyBase) SetWeight(w float64) {
+	p.weight = w
+}
+
+// evaluate evaluates the objective and constraints at x and puts the gradient
+// of the objective in grad
+func (p *penaltyBase) evaluate(x, grad []float64) *evaluation {
+	if p.eval == nil || len(p.eval.grad) != len(x) {
+		p.eval = newEvaluation(len(x), p.c.NumEquality(), p.c.NumInequality())
+	}
+	e := p.eval
+	e.evaluate(p.fun, p.c, x)
+	copy(grad, e.grad)
+	return e
+}
+
+// QuadraticPenalty is the quadratic penalty function
+//
+//	f(x) + w/2 (||c_E(x)||^2 + ||min(0, c_I(x))||^2)
+//
+// The constraint violation at the minimizer decreases like 1/w, and the
+// subproblems become ill-conditioned as w grows. Once the violation is small,
+// the inner optimizer may not be able to meet tight gradient tolerances, in
+// which case PenaltyMethod ends with MaximumIterations near the solution.
+type QuadraticPenalty struct {
+	penaltyBase
+}
+
+func (q *QuadraticPenalty) ObjGrad(x, grad []float64) float64 {
+	e := q.evaluate(x, grad)
+	w := q.weight
+	obj := e.obj
+	for i, c := range e.eq {
+		obj += 0.5 * w * c * c
+		addScaledRow(grad, w*c, e.eqJac, i)
+	}
+	for i, c := range e.ineq {
+		if c < 0 {
+			obj += 0.5 * w * c * c
+			addScaledRow(grad, w*c, e.ineqJac, i)
+		}
+	}
+	return obj
+}
+
+func (q *QuadraticPenalty) Multipliers(eqMult, ineqMult []float64) {
+	for i, c := range q.eval.eq {
+		eqMult[i] = -q.weight * c
+	}
+	for i, c := range q.eval.ineq {
+		ineqMult[i] = q.weight * math.Max(0, -c)
+	}
+}
+
+// L1Penalty is the exact l1 penalty function
+//
+//	f(x) + w (Σ_i h(c_E,i(x)) + Σ_j h(min(0, c_I,j(x))))
+//
+// where h is the Huber function, equal to |c| - Smoothing/2 for |c| larger than
+// Smoothing and c^2/(2*Smoothing) otherwise. Without smoothing the
+// minimizers are exact once w is larger than the multipliers, but the
+// penalty is not differentiable at the constraints. With smoothing the
+// constraint violation at the minimizer decreases like Smoothing/w.
+type L1Penalty struct {
+	penaltyBase
+	Smoothing float64
+}
+
+// NewL1Penalty returns a new L1Penalty with the default smoothing
+func NewL1Penalty() *L1Penalty {
+	return &L1Penalty{Smoothing: 1e-3}
+}
+
+// huber returns the Huber function of c with the given smoothing and its
+// derivative
+func huber(c, smooth float64) (h, dh float64) {
+	if math.Abs(c) >= smooth {
+		if c < 0 {
+			return -c - smooth/2, -1
+		}
+		return c - smooth/2, 1
+	}
+	return c * c / (2 * smooth), c / smooth
+}
+
+func (l *L1Penalty) ObjGrad(x, grad []float64) float64 {
+	e := l.evaluate(x, grad)
+	w := l.weight
+	obj := e.obj
+	for i, c := range e.eq {
+		h, dh := huber(c, l.Smoothing)
+		obj += w * h
+		addScaledRow(grad, w*dh, e.eqJac, i)
+	}
+	for i, c := range e.ineq {
+		if c < 0 {
+			h, dh := huber(c, l.Smoothing)
+			obj += w * h
+			addScaledRow(grad, w*dh, e.ineqJac, i)
+		}
+	}
+	return obj
+}
+
+func (l *L1Penalty) Multipliers(eqMult, ineqMult []float64) {
+	for i, c := range l.eval.eq {
+		_, dh := huber(c, l.Smoothing)
+		eqMult[i] = -l.weight * dh
+	}
+	for i, c := range l.eval.ineq {
+		_, dh := huber(math.Min(0, c), l.Smoothing)
+		ineqMult[i] = -l.weight * dh
+	}
+}
+
+// LogBarrier is the logarithmic barrier function
+//
+//	f(x) - μ Σ_j φ(c_I,j(x)) + 1/(2μ) ||c_E(x)||^2
+//
+// with μ = 1/w. φ is the logarithm for constraint values larger than μ, and
+// below that is extended by its second order Taylor expansion so that the
+// function is defined at infeasible locations, where it acts as a quadratic
+// penalty. The equality constraints, which cannot be handled by a barrier,
+// have a quadratic penalty.
+type LogBarrier struct {
+	penaltyBase
+}
+
+// logBarrier returns the extended logarithm φ of c with threshold delta and
+// its derivative
+func logBarrier(c, delta float64) (phi, dphi float64) {
+	if c >= delta {
+		return math.Log(c), 1 / c
+	}
+	d := (c - delta) / delta
+	return math.Log(delta) + d - d*d/2, (1 - d) / delta
+}
+
+func (l *LogBarrier) ObjGrad(x, grad []float64) float64 {
+	e := l.evaluate(x, grad)
+	mu := 1 / l.weight
+	obj := e.obj
+	for i, c := range e.eq {
+		obj += 0.5 * c * c / mu
+		addScaledRow(grad, c/mu, e.eqJac, i)
+	}
+	for i, c := range e.ineq {
+		phi, dphi := logBarrier(c, mu)
+		obj -= mu * phi
+		addScaledRow(grad, -mu*dphi, e.ineqJac, i)
+	}
+	return obj
+}
+
+func (l *LogBarrier) Multipliers(eqMult, ineqMult []float64) {
+	mu := 1 / l.weight
+	for i, c := range l.eval.eq {
+		eqMult[i] = -c / mu
+	}
+	for i, c := range l.eval.ineq {
+		_, dphi := logBarrier(c, mu)
+		ineqMult[i] = mu * dphi
+	}
+}
+
+// PenaltyMethod solves constrained problems by minimizing a sequence of
+// penalized objectives with the Inner optimizer, increasing the weight of the
+// penalty after every subproblem. Every iteration is one subproblem, started
+// from an extrapolation of the solutions of the previous two. The
+// optimization stops once the solution of a subproblem satisfies the
+// constraint, complementarity and gradient tolerances of the settings, or
+// fails with MaximumIterations if the weight reaches MaxWeight first.
+type PenaltyMethod struct {
+	Penalty       PenaltyFunction            // Penalized objective
+	Inner         multivariate.GradOptimizer // Optimizer for the subproblems
+	InnerSettings *multivariate.Settings     // Settings for the subproblems
+
+	InitialWeight float64 // Weight of the first subproblem
+	WeightFactor  float64 // Factor by which the weight is increased
+	MaxWeight     float64 // Largest weight
+
+	fun  multivariate.ObjGrader
+	c    Constraints
+	nDim int
+
+	loc    []float64
+	sub    *subproblem
+	eval   *evaluation
+	weight float64
+	done   bool
+
+	// The last two solutions and the weights of their subproblems
+	prevLoc    []float64
+	prevWeight float64
+	locWeight  float64
+}
+
+// NewPenaltyMethod returns a PenaltyMethod with the penalty p and the
+// subproblems solved by Bfgs
+func NewPenaltyMethod(p PenaltyFunction) *PenaltyMethod {
+	innerSettings := multivariate.DefaultSettings()
+	innerSettings.DisplayWriters = nil
+	innerSettings.GradAbsTol = 1e-8
+	innerSettings.MaximumIterations = 1000
+	return &PenaltyMethod{
+		Penalty:       p,
+		Inner:         multivariate.NewBfgs(),
+		InnerSettings: innerSettings,
+		InitialWeight: 10,
+		WeightFactor:  10,
+		MaxWeight:     1e12,
+	}
+}
+
+func (p *PenaltyMethod) Init(f multivariate.ObjGrader, c Constraints, initLoc []float64, initObj float64, initGrad []float64) error {
+	if p.Penalty == nil {
+		return errors.New("penalty: nil penalty function")
+	}
+	if p.Inner == nil {
+		return errors.New("penalty: nil inner optimizer")
+	}
+	if p.InitialWeight <= 0 || p.MaxWeight < p.InitialWeight {
+		return errors.New("penalty: bad weight")
+	}
+	if p.WeightFactor <= 1 {
+		return errors.New("penalty: weight factor must be greater than one")
+	}
+	p.fun = f
+	p.c = c
+	p.nDim = len(initLoc)
+	p.loc = append(p.loc[:0], initLoc...)
+	p.eval = newEvaluation(p.nDim, c.NumEquality(), c.NumInequality())
+	p.weight = p.InitialWeight
+	p.done = false
+	p.prevLoc = append(p.prevLoc[:0], initLoc...)
+	p.prevWeight = 0
+	p.locWeight = 0
+	p.Penalty.Init(f, c)
+	p.sub = &subproblem{ObjGrader: p.Penalty}
+	return nil
+}
+
+func (p *PenaltyMethod) Status() common.Status {
+	if p.done {
+		return common.MaximumIterations
+	}
+	return common.Continue
+}
+
+func (p *PenaltyMethod) Iterate(loc, grad, eqMult, ineqMult []float64) (obj, violation float64, nFunEvals int, err error) {
+	if len(loc) != p.nDim {
+		panic("dimension mismatch")
+	}
+	// The solutions of the subproblems approach the solution of the
+	// constrained problem like 1/w, so the subproblem starts from the
+	// extrapolation of the last two solutions. Starting from the last
+	// solution gives a poor start for the ill-conditioned subproblems with
+	// large weights.
+	if p.prevWeight > 0 && p.weight > p.locWeight {
+		s := (1/p.locWeight - 1/p.weight) / (1/p.prevWeight - 1/p.locWeight)
+		for i, v := range p.loc {
+			p.loc[i], p.prevLoc[i] = v+s*(v-p.prevLoc[i]), v
+		}
+	} else {
+		copy(p.prevLoc, p.loc)
+	}
+	p.prevWeight = p.locWeight
+	p.locWeight = p.weight
+
+	p.Penalty.SetWeight(p.weight)
+	err = p.sub.minimize(p.loc, p.InnerSettings, p.Inner)
+	if err != nil {
+		return math.NaN(), math.NaN(), p.sub.nFunEvals, errors.New("penalty: subproblem failed: " + err.Error())
+	}
+	nFunEvals = p.sub.nFunEvals
+
+	// Evaluate the penalty at the solution so the multiplier estimates
+	// are for that location
+	p.Penalty.ObjGrad(p.loc, grad)
+	p.Penalty.Multipliers(eqMult, ineqMult)
+	p.eval.evaluate(p.fun, p.c, p.loc)
+	nFunEvals += 2
+
+	if p.weight >= p.MaxWeight {
+		p.done = true
+	}
+	p.weight = math.Min(p.weight*p.WeightFactor, p.MaxWeight)
+
+	copy(loc, p.loc)
+	lagrangianGrad(grad, p.eval.grad, p.eval.eqJac, eqMult, p.eval.ineqJac, ineqMult)
+	return p.eval.obj, p.eval.violation(), nFunEvals, nil
+}
+
+func (p *PenaltyMethod) AppendWriteData(v []*write.Value) []*write.Value {
+	v = append(v, &write.Value{Heading: "Weight", Value: p.weight})
+	return v
+}
+
+func (p *PenaltyMethod) Result() {}
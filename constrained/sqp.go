@@ -0,0 +1,252 @@
+package constrained
+
+import (
+	"errors"
+	"math"
+
+	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/multivariate"
+	"github.com/btracey/opt/qp"
+	"github.com/btracey/opt/write"
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+// SQP is a sequential quadratic programming method for smooth constrained
+// problems. Each iteration solves a quadratic program with the constraints
+// linearized at the current location and a BFGS approximation of the Hessian
+// of the Lagrangian, and then backtracks along the step until the l1 merit
+// function
+//
+//	φ(x) = f(x) + ν (||c_E(x)||_1 + Σ_j max(0, -c_I,j(x)))
+//
+// decreases sufficiently. The BFGS update uses Powell's damping so that the
+// approximation stays positive definite.
+//
+// The linearized constraints must be consistent at every iterate, otherwise
+// Iterate returns an error.
+type SQP struct {
+	QP *qp.Dual // Solver for the quadratic subproblems
+
+	Decrease float64 // Sufficient decrease parameter of the merit function linesearch
+	Contract float64 // Factor by which the step is decreased during the linesearch
+	MinStep  float64 // Smallest step of the linesearch before failing
+
+	fun  multivariate.ObjGrader
+	c    Constraints
+	nDim int
+
+	loc     []float64
+	curr    *evaluation
+	trial   *evaluation
+	hess    *mat64.Dense // Approximation of the Hessian of the Lagrangian
+	penalty float64      // Merit function penalty ν
+	step    float64
+
+	sub      *qp.Problem
+	p        []float64
+	trialLoc []float64
+	eqMult   []float64
+	ineqMult []float64
+	s        []float64
+	y        []float64
+	bs       []float64
+	lagGrad  []float64
+}
+
+// NewSQP returns a new SQP with the default linesearch parameters
+func NewSQP() *SQP {
+	return &SQP{
+		QP:       qp.NewDual(),
+		Decrease: 1e-4,
+		Contract: 0.5,
+		MinStep:  1e-12,
+	}
+}
+
+func (s *SQP) Init(f multivariate.ObjGrader, c Constraints, initLoc []float64, initObj float64, initGrad []float64) error {
+	if s.QP == nil {
+		return errors.New("sqp: nil QP solver")
+	}
+	if s.Contract <= 0 || s.Contract >= 1 {
+		return errors.New("sqp: contraction factor must be between zero and one")
+	}
+	s.fun = f
+	s.c = c
+	s.nDim = len(initLoc)
+	nEq := c.NumEquality()
+	nIneq := c.NumInequality()
+
+	s.loc = append(s.loc[:0], initLoc...)
+	s.curr = newEvaluation(s.nDim, nEq, nIneq)
+	s.trial = newEvaluation(s.nDim, nEq, nIneq)
+	s.curr.evaluate(f, c, s.loc)
+
+	s.hess = mat64.NewDense(s.nDim, s.nDim, nil)
+	for i := 0; i < s.nDim; i++ {
+		s.hess.Set(i, i, 1)
+	}
+	s.penalty = 0
+	s.step = 0
+
+	// The subproblem is
+	//	minimize    1/2 p^T B p + ∇f^T p
+	//	subject to  A_E p = -c_E
+	//	            -A_I p <= c_I
+	s.sub = &qp.Problem{
+		Q: s.hess,
+		B: make([]float64, nEq),
+		H: make([]float64, nIneq),
+	}
+	if nIneq > 0 {
+		s.sub.G = mat64.NewDense(nIneq, s.nDim, nil)
+	}
+	s.p = make([]float64, s.nDim)
+	s.trialLoc = make([]float64, s.nDim)
+	s.eqMult = make([]float64, nEq)
+	s.ineqMult = make([]float64, nIneq)
+	s.s = make([]float64, s.nDim)
+	s.y = make([]float64, s.nDim)
+	s.bs = make([]float64, s.nDim)
+	s.lagGrad = make([]float64, s.nDim)
+	return nil
+}
+
+func (s *SQP) Status() common.Status {
+	return common.Continue
+}
+
+func (s *SQP) Iterate(loc, grad, eqMult, ineqMult []float64) (obj, violation float64, nFunEvals int, err error) {
+	if len(loc) != s.nDim {
+		panic("dimension mismatch")
+	}
+	curr := s.curr
+	sub := s.sub
+	sub.C = curr.grad
+	sub.A = curr.eqJac
+	for i, c := range curr.eq {
+		sub.B[i] = -c
+	}
+	for i, c := range curr.ineq {
+		sub.H[i] = c
+		for j := 0; j < s.nDim; j++ {
+			sub.G.Set(i, j, -curr.ineqJac.At(i, j))
+		}
+	}
+	result, err := s.QP.Solve(sub)
+	if err != nil {
+		return math.NaN(), math.NaN(), 0, errors.New("sqp: " + err.Error())
+	}
+	copy(s.p, result.X)
+	// The multipliers of the subproblem use the opposite sign convention for
+	// the equality constraints
+	for i, m := range result.EqualityMultipliers {
+		s.eqMult[i] = -m
+	}
+	copy(s.ineqMult, result.InequalityMultipliers)
+
+	// The penalty must be larger than the multipliers for the step to be a
+	// descent direction of the merit function
+	var maxMult float64
+	for _, m := range s.eqMult {
+		maxMult = math.Max(maxMult, math.Abs(m))
+	}
+	for _, m := range s.ineqMult {
+		maxMult = math.Max(maxMult, m)
+	}
+	if s.penalty < 1.1*maxMult {
+		s.penalty = 2 * maxMult
+	}
+
+	// Backtracking linesearch on the merit function
+	merit := curr.obj + s.penalty*l1Violation(curr.eq, curr.ineq)
+	deriv := floats.Dot(curr.grad, s.p) - s.penalty*l1Violation(curr.eq, curr.ineq)
+	alpha := 1.0
+	for {
+		for i := range s.trialLoc {
+			s.trialLoc[i] = s.loc[i] + alpha*s.p[i]
+		}
+		s.trial.evaluate(s.fun, s.c, s.trialLoc)
+		nFunEvals++
+		trialMerit := s.trial.obj + s.penalty*l1Violation(s.trial.eq, s.trial.ineq)
+		if trialMerit <= merit+s.Decrease*alpha*math.Min(deriv, 0) {
+			break
+		}
+		alpha *= s.Contract
+		if alpha < s.MinStep {
+			return math.NaN(), math.NaN(), nFunEvals, errors.New("sqp: merit function linesearch failed")
+		}
+	}
+	s.step = alpha
+
+	// Damped BFGS update of the Hessian of the Lagrangian with the new
+	// multipliers
+	for i := range s.s {
+		s.s[i] = alpha * s.p[i]
+	}
+	lagrangianGrad(s.lagGrad, curr.grad, curr.eqJac, s.eqMult, curr.ineqJac, s.ineqMult)
+	lagrangianGrad(s.y, s.trial.grad, s.trial.eqJac, s.eqMult, s.trial.ineqJac, s.ineqMult)
+	floats.Sub(s.y, s.lagGrad)
+	dampedBFGSUpdate(s.hess, s.s, s.y, s.bs)
+
+	s.curr, s.trial = s.trial, s.curr
+	copy(s.loc, s.trialLoc)
+
+	copy(loc, s.loc)
+	copy(eqMult, s.eqMult)
+	copy(ineqMult, s.ineqMult)
+	lagrangianGrad(grad, s.curr.grad, s.curr.eqJac, eqMult, s.curr.ineqJac, ineqMult)
+	return s.curr.obj, s.curr.violation(), nFunEvals, nil
+}
+
+func (s *SQP) AppendWriteData(v []*write.Value) []*write.Value {
+	v = append(v, &write.Value{Heading: "Step", Value: s.step})
+	v = append(v, &write.Value{Heading: "Penalty", Value: s.penalty})
+	return v
+}
+
+func (s *SQP) Result() {}
+
+// l1Violation returns the l1 norm of the constraint violations
+func l1Violation(eq, ineq []float64) float64 {
+	var v float64
+	for _, c := range eq {
+		v += math.Abs(c)
+	}
+	for _, c := range ineq {
+		v += math.Max(0, -c)
+	}
+	return v
+}
+
+// dampedBFGSUpdate performs the BFGS update of the Hessian approximation b
+// with the step s and the change in gradient y, using Powell's damping to
+// keep b positive definite. bs is used as storage.
+func dampedBFGSUpdate(b *mat64.Dense, s, y, bs []float64) {
+	n := len(s)
+	for i := 0; i < n; i++ {
+		var v float64
+		for j := 0; j < n; j++ {
+			v += b.At(i, j) * s[j]
+		}
+		bs[i] = v
+	}
+	sBs := floats.Dot(s, bs)
+	sy := floats.Dot(s, y)
+	if sBs <= 0 {
+		return
+	}
+	// Replace y with a combination of y and b*s when the curvature is too small
+	if sy < 0.2*sBs {
+		theta := 0.8 * sBs / (sBs - sy)
+		for i := range y {
+			y[i] = theta*y[i] + (1-theta)*bs[i]
+		}
+		sy = floats.Dot(s, y)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			b.Set(i, j, b.At(i, j)-bs[i]*bs[j]/sBs+y[i]*y[j]/sy)
+		}
+	}
+}
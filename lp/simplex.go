@@ -0,0 +1,428 @@
+// Package lp solves linear programs.
+package lp
+
+import (
+	"errors"
+	"math"
+
+	"github.com/btracey/opt/common"
+	"github.com/gonum/matrix/mat64"
+)
+
+var (
+	ErrInfeasible = errors.New("lp: problem is infeasible")
+	ErrUnbounded  = errors.New("lp: problem is unbounded")
+	ErrSingular   = errors.New("lp: singular basis")
+)
+
+// degenerateLimit is the number of consecutive degenerate pivots after which
+// Bland's rule is used to choose the pivots
+const degenerateLimit = 10
+
+// Simplex solves linear programs with the revised simplex method. A feasible
+// basis is found in phase I by minimizing the sum of artificial variables,
+// and the objective is then minimized in phase II starting from that basis.
+//
+// The entering variable is the one with the most negative reduced cost. After
+// a sequence of degenerate pivots the method switches to Bland's rule, which
+// chooses the entering and leaving variables with the smallest index and
+// prevents cycling, until the objective decreases again.
+type Simplex struct {
+	Tol           float64 // Tolerance on the reduced costs, pivot elements and phase I objective
+	MaxIterations int     // Maximum number of pivots in each phase. Negative means no maximum
+	Refactor      int     // Number of pivots after which the basis inverse is recomputed
+
+	m, n   int
+	a      *mat64.Dense // Constraint matrix with an artificial variable for every row
+	b      []float64
+	cost   []float64
+	basis  []int
+	isBase []bool
+	binv   *mat64.Dense // Inverse of the basis matrix
+	xb     []float64    // Values of the basic variables
+	y      []float64
+	w      []float64
+}
+
+// NewSimplex returns a new Simplex with the default settings
+func NewSimplex() *Simplex {
+	return &Simplex{
+		Tol:           1e-10,
+		MaxIterations: -1,
+		Refactor:      50,
+	}
+}
+
+// Result is the solution of a linear program
+type Result struct {
+	Obj        float64   // Optimal objective value
+	X          []float64 // Optimal location
+	Dual       []float64 // Optimal dual variables, one for every constraint
+	Iterations int       // Total number of pivots in both phases
+}
+
+// Standard solves the linear program in standard form
+//
+//	minimize    c^T x
+//	subject to  A x = b
+//	            x >= 0
+//
+// The dual variables y are the solution of the dual problem
+//
+//	maximize    b^T y
+//	subject to  A^T y <= c
+//
+// ErrInfeasible is returned if there is no x that satisfies the constraints,
+// and ErrUnbounded if the objective is unbounded below. Linearly dependent
+// rows of A are allowed if they are consistent.
+func (s *Simplex) Standard(c []float64, a *mat64.Dense, b []float64) (*Result, error) {
+	m, n := a.Dims()
+	if len(c) != n || len(b) != m {
+		panic("lp: dimension mismatch")
+	}
+	if s.Tol <= 0 {
+		return nil, errors.New("lp: tolerance must be positive")
+	}
+	if s.Refactor <= 0 {
+		return nil, errors.New("lp: refactor frequency must be positive")
+	}
+	s.init(a, b)
+
+	// Phase I minimizes the sum of the artificial variables
+	var bNorm float64
+	for i := 0; i < m; i++ {
+		s.cost[n+i] = 1
+		bNorm = math.Max(bNorm, s.b[i])
+	}
+	iter, err := s.solve()
+	if err == ErrUnbounded {
+		// The phase I objective is bounded below by zero, so this can only be
+		// caused by rounding errors
+		return nil, errors.New("lp: numerical failure in phase I: " + err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	var infeas float64
+	for i, j := range s.basis {
+		if j >= n {
+			infeas += s.xb[i]
+		}
+	}
+	if infeas > s.Tol*math.Max(1, bNorm) {
+		return nil, ErrInfeasible
+	}
+	if err := s.removeArtificial(); err != nil {
+		return nil, err
+	}
+
+	// Phase II. Artificial variables remaining in the basis correspond to
+	// redundant rows and stay at zero
+	for i := 0; i < m; i++ {
+		s.cost[n+i] = 0
+	}
+	copy(s.cost, c)
+	iter2, err := s.solve()
+	if err != nil {
+		return nil, err
+	}
+
+	x := make([]float64, n)
+	for i, j := range s.basis {
+		if j < n {
+			x[j] = math.Max(s.xb[i], 0)
+		}
+	}
+	var obj float64
+	for j, v := range x {
+		obj += c[j] * v
+	}
+	s.duals()
+	dual := make([]float64, m)
+	for i, v := range s.y {
+		// Undo the sign change of rows with negative right hand side
+		if b[i] < 0 {
+			v = -v
+		}
+		dual[i] = v
+	}
+	return &Result{
+		Obj:        obj,
+		X:          x,
+		Dual:       dual,
+		Iterations: iter + iter2,
+	}, nil
+}
+
+// Inequality solves the linear program in inequality form
+//
+//	minimize    c^T x
+//	subject to  G x <= h
+//
+// where x is free. The dual variables λ >= 0 are the solution of the dual
+// problem
+//
+//	maximize    -h^T λ
+//	subject to  G^T λ + c = 0
+//
+// The problem is converted to standard form by writing x as the difference
+// of two nonnegative variables and adding a slack variable to every row.
+func (s *Simplex) Inequality(c []float64, g *mat64.Dense, h []float64) (*Result, error) {
+	m, n := g.Dims()
+	if len(c) != n || len(h) != m {
+		panic("lp: dimension mismatch")
+	}
+	a := mat64.NewDense(m, 2*n+m, nil)
+	for i := 0; i < m; i++ {
+		for j := 0; j < n; j++ {
+			v := g.At(i, j)
+			a.Set(i, j, v)
+			a.Set(i, n+j, -v)
+		}
+		a.Set(i, 2*n+i, 1)
+	}
+	cStd := make([]float64, 2*n+m)
+	for j, v := range c {
+		cStd[j] = v
+		cStd[n+j] = -v
+	}
+	result, err := s.Standard(cStd, a, h)
+	if err != nil {
+		return nil, err
+	}
+	x := make([]float64, n)
+	for j := range x {
+		x[j] = result.X[j] - result.X[n+j]
+	}
+	for i, v := range result.Dual {
+		result.Dual[i] = -v
+	}
+	result.X = x
+	return result, nil
+}
+
+// init sets up the phase I problem with the rows of a and b negated where b
+// is negative. The initial basis uses the columns of a that are unit vectors,
+// such as slack variables, and artificial variables for the remaining rows.
+func (s *Simplex) init(a *mat64.Dense, b []float64) {
+	m, n := a.Dims()
+	s.m = m
+	s.n = n
+	s.a = mat64.NewDense(m, n+m, nil)
+	s.b = make([]float64, m)
+	for i := 0; i < m; i++ {
+		sign := 1.0
+		if b[i] < 0 {
+			sign = -1
+		}
+		for j := 0; j < n; j++ {
+			s.a.Set(i, j, sign*a.At(i, j))
+		}
+		s.a.Set(i, n+i, 1)
+		s.b[i] = sign * b[i]
+	}
+	s.cost = make([]float64, n+m)
+	s.basis = make([]int, m)
+	s.isBase = make([]bool, n+m)
+	s.binv = mat64.NewDense(m, m, nil)
+	s.xb = make([]float64, m)
+	for i := 0; i < m; i++ {
+		s.basis[i] = n + i
+		s.binv.Set(i, i, 1)
+		s.xb[i] = s.b[i]
+	}
+	for j := 0; j < n; j++ {
+		if i := s.unitRow(j); i >= 0 && s.basis[i] >= n {
+			s.basis[i] = j
+		}
+	}
+	for _, j := range s.basis {
+		s.isBase[j] = true
+	}
+	s.y = make([]float64, m)
+	s.w = make([]float64, m)
+}
+
+// unitRow returns i if column j of the phase I matrix is the unit vector
+// e_i, and -1 otherwise
+func (s *Simplex) unitRow(j int) int {
+	row := -1
+	for i := 0; i < s.m; i++ {
+		switch v := s.a.At(i, j); {
+		case v == 0:
+		case v == 1 && row < 0:
+			row = i
+		default:
+			return -1
+		}
+	}
+	return row
+}
+
+// solve runs the simplex method from the current basis with the current
+// costs until the reduced costs are nonnegative, and returns the number of
+// pivots. Artificial variables never enter the basis.
+func (s *Simplex) solve() (int, error) {
+	var degenerate int
+	for iter := 0; ; iter++ {
+		if s.MaxIterations >= 0 && iter >= s.MaxIterations {
+			return iter, errors.New("lp: maximum iterations reached")
+		}
+		if iter > 0 && iter%s.Refactor == 0 {
+			if err := s.refactor(); err != nil {
+				return iter, err
+			}
+		}
+		bland := degenerate >= degenerateLimit
+
+		// Choose the entering variable
+		s.duals()
+		enter := -1
+		var minCost float64
+		for j := 0; j < s.n; j++ {
+			if s.isBase[j] {
+				continue
+			}
+			d := s.cost[j]
+			for i, y := range s.y {
+				d -= y * s.a.At(i, j)
+			}
+			if d < -s.Tol && (enter < 0 || d < minCost) {
+				enter = j
+				minCost = d
+				if bland {
+					break
+				}
+			}
+		}
+		if enter < 0 {
+			return iter, nil
+		}
+
+		// Ratio test for the leaving variable, with ratios within Tol of each
+		// other counted as ties and broken by the smallest variable index
+		s.column(s.w, enter)
+		leave := -1
+		var minRatio float64
+		for i, w := range s.w {
+			if w <= s.Tol {
+				continue
+			}
+			r := s.xb[i] / w
+			if leave < 0 || r < minRatio-s.Tol || (r <= minRatio+s.Tol && s.basis[i] < s.basis[leave]) {
+				leave = i
+				minRatio = r
+			}
+		}
+		if leave < 0 {
+			return iter, ErrUnbounded
+		}
+		if minRatio <= s.Tol {
+			degenerate++
+		} else {
+			degenerate = 0
+		}
+		s.pivot(enter, leave, minRatio)
+	}
+}
+
+// removeArtificial pivots the artificial variables at zero out of the basis
+// where possible. Artificial variables that cannot be removed correspond to
+// linearly dependent rows.
+func (s *Simplex) removeArtificial() error {
+	for i := 0; i < s.m; i++ {
+		if s.basis[i] < s.n {
+			continue
+		}
+		for j := 0; j < s.n; j++ {
+			if s.isBase[j] {
+				continue
+			}
+			s.column(s.w, j)
+			if math.Abs(s.w[i]) > s.Tol {
+				s.xb[i] = 0
+				s.pivot(j, i, 0)
+				break
+			}
+		}
+	}
+	return s.refactor()
+}
+
+// duals computes the simplex multipliers y = B^-T c_B
+func (s *Simplex) duals() {
+	for i := range s.y {
+		var v float64
+		for k, j := range s.basis {
+			v += s.cost[j] * s.binv.At(k, i)
+		}
+		s.y[i] = v
+	}
+}
+
+// column puts B^-1 a_j in dst
+func (s *Simplex) column(dst []float64, j int) {
+	for i := range dst {
+		var v float64
+		for k := 0; k < s.m; k++ {
+			v += s.binv.At(i, k) * s.a.At(k, j)
+		}
+		dst[i] = v
+	}
+}
+
+// pivot replaces the basic variable in row leave with the variable enter,
+// which takes the value theta. s.w must contain B^-1 a_enter.
+func (s *Simplex) pivot(enter, leave int, theta float64) {
+	for i, w := range s.w {
+		s.xb[i] -= theta * w
+	}
+	s.xb[leave] = theta
+	s.isBase[s.basis[leave]] = false
+	s.isBase[enter] = true
+	s.basis[leave] = enter
+
+	// Update the inverse with the elementary row operations that turn w
+	// into the unit vector e_leave
+	wr := s.w[leave]
+	for k := 0; k < s.m; k++ {
+		s.binv.Set(leave, k, s.binv.At(leave, k)/wr)
+	}
+	for i, w := range s.w {
+		if i == leave || w == 0 {
+			continue
+		}
+		for k := 0; k < s.m; k++ {
+			s.binv.Set(i, k, s.binv.At(i, k)-w*s.binv.At(leave, k))
+		}
+	}
+}
+
+// refactor recomputes the basis inverse and the basic variables from scratch
+// to remove the rounding errors accumulated by the updates
+func (s *Simplex) refactor() error {
+	m := s.m
+	bm := mat64.NewDense(m, m, nil)
+	for k, j := range s.basis {
+		for i := 0; i < m; i++ {
+			bm.Set(i, k, s.a.At(i, j))
+		}
+	}
+	lu, singular := common.LU(bm)
+	if singular {
+		return ErrSingular
+	}
+	eye := mat64.NewDense(m, m, nil)
+	for i := 0; i < m; i++ {
+		eye.Set(i, i, 1)
+	}
+	s.binv = lu.Solve(eye)
+	for i := range s.xb {
+		var v float64
+		for k, b := range s.b {
+			v += s.binv.At(i, k) * b
+		}
+		s.xb[i] = v
+	}
+	return nil
+}
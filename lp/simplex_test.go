@@ -0,0 +1,209 @@
+package lp
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+func TestStandard(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		c    []float64
+		a    *mat64.Dense
+		b    []float64
+		obj  float64
+		x    []float64
+		dual []float64
+		err  error
+	}{
+		{
+			name: "slack",
+			c:    []float64{-1, -1, 0, 0},
+			a: mat64.NewDense(2, 4, []float64{
+				1, 2, 1, 0,
+				3, 1, 0, 1,
+			}),
+			b:    []float64{4, 6},
+			obj:  -2.8,
+			x:    []float64{1.6, 1.2, 0, 0},
+			dual: []float64{-0.4, -0.2},
+		},
+		{
+			name: "negative rhs",
+			c:    []float64{1, 2},
+			a:    mat64.NewDense(1, 2, []float64{-1, -1}),
+			b:    []float64{-3},
+			obj:  3,
+			x:    []float64{3, 0},
+			dual: []float64{-1},
+		},
+		{
+			name: "redundant row",
+			c:    []float64{1, 3, 1},
+			a: mat64.NewDense(3, 3, []float64{
+				1, 1, 1,
+				2, 2, 2,
+				1, 0, -1,
+			}),
+			b:   []float64{2, 4, 0},
+			obj: 2,
+			x:   []float64{1, 0, 1},
+		},
+		{
+			// Beale's example, which cycles with the most negative reduced
+			// cost rule
+			name: "beale",
+			c:    []float64{0, 0, 0, -0.75, 20, -0.5, 6},
+			a: mat64.NewDense(3, 7, []float64{
+				1, 0, 0, 0.25, -8, -1, 9,
+				0, 1, 0, 0.5, -12, -0.5, 3,
+				0, 0, 1, 0, 0, 1, 0,
+			}),
+			b:   []float64{0, 0, 1},
+			obj: -1.25,
+			x:   []float64{0.75, 0, 0, 1, 0, 1, 0},
+		},
+		{
+			name: "infeasible",
+			c:    []float64{1, 1},
+			a:    mat64.NewDense(2, 2, []float64{1, 1, 1, -1}),
+			b:    []float64{-1, 0},
+			err:  ErrInfeasible,
+		},
+		{
+			name: "unbounded",
+			c:    []float64{-1, 0},
+			a:    mat64.NewDense(1, 2, []float64{1, -1}),
+			b:    []float64{1},
+			err:  ErrUnbounded,
+		},
+	} {
+		result, err := NewSimplex().Standard(test.c, test.a, test.b)
+		if err != test.err {
+			t.Errorf("%v: error mismatch. Want %v, found %v", test.name, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if math.Abs(result.Obj-test.obj) > 1e-10 {
+			t.Errorf("%v: objective mismatch. Want %v, found %v", test.name, test.obj, result.Obj)
+		}
+		if !floats.EqualApprox(result.X, test.x, 1e-10) {
+			t.Errorf("%v: location mismatch. Want %v, found %v", test.name, test.x, result.X)
+		}
+		if test.dual != nil && !floats.EqualApprox(result.Dual, test.dual, 1e-10) {
+			t.Errorf("%v: dual mismatch. Want %v, found %v", test.name, test.dual, result.Dual)
+		}
+		if dualObj := floats.Dot(test.b, result.Dual); math.Abs(dualObj-result.Obj) > 1e-10 {
+			t.Errorf("%v: duality gap. Primal %v, dual %v", test.name, result.Obj, dualObj)
+		}
+	}
+}
+
+func TestInequality(t *testing.T) {
+	c := []float64{-1, -1}
+	g := mat64.NewDense(4, 2, []float64{
+		1, 2,
+		3, 1,
+		-1, 0,
+		0, -1,
+	})
+	h := []float64{4, 6, 0, 0}
+	result, err := NewSimplex().Inequality(c, g, h)
+	if err != nil {
+		t.Fatalf("Error solving: %v", err)
+	}
+	if !floats.EqualApprox(result.X, []float64{1.6, 1.2}, 1e-10) {
+		t.Errorf("Location mismatch. Want %v, found %v", []float64{1.6, 1.2}, result.X)
+	}
+	if !floats.EqualApprox(result.Dual, []float64{0.4, 0.2, 0, 0}, 1e-10) {
+		t.Errorf("Dual mismatch. Want %v, found %v", []float64{0.4, 0.2, 0, 0}, result.Dual)
+	}
+
+	// With only the first constraint the problem is unbounded
+	g = mat64.NewDense(1, 2, []float64{1, 2})
+	_, err = NewSimplex().Inequality(c, g, h[:1])
+	if err != ErrUnbounded {
+		t.Errorf("Error mismatch. Want %v, found %v", ErrUnbounded, err)
+	}
+}
+
+func TestStandardRandom(t *testing.T) {
+	// Random problems that are feasible by construction and bounded because
+	// the costs are positive. The solutions are checked with the optimality
+	// conditions.
+	rnd := rand.New(rand.NewSource(1))
+	for k := 0; k < 50; k++ {
+		m := 1 + rnd.Intn(6)
+		n := m + rnd.Intn(10)
+		a := mat64.NewDense(m, n, nil)
+		x0 := make([]float64, n)
+		for j := range x0 {
+			x0[j] = rnd.Float64()
+		}
+		b := make([]float64, m)
+		for i := 0; i < m; i++ {
+			for j := 0; j < n; j++ {
+				v := rnd.NormFloat64()
+				a.Set(i, j, v)
+				b[i] += v * x0[j]
+			}
+		}
+		c := make([]float64, n)
+		for j := range c {
+			c[j] = rnd.Float64()
+		}
+		s := NewSimplex()
+		s.Refactor = 3
+		result, err := s.Standard(c, a, b)
+		if err != nil {
+			t.Errorf("Case %v: error solving: %v", k, err)
+			continue
+		}
+		for i := 0; i < m; i++ {
+			var ax float64
+			for j := 0; j < n; j++ {
+				ax += a.At(i, j) * result.X[j]
+			}
+			if math.Abs(ax-b[i]) > 1e-8 {
+				t.Errorf("Case %v: constraint %v violated by %v", k, i, ax-b[i])
+			}
+		}
+		for j := 0; j < n; j++ {
+			if result.X[j] < 0 {
+				t.Errorf("Case %v: negative x_%v = %v", k, j, result.X[j])
+			}
+			d := c[j]
+			for i := 0; i < m; i++ {
+				d -= a.At(i, j) * result.Dual[i]
+			}
+			if d < -1e-8 {
+				t.Errorf("Case %v: dual infeasible in column %v: %v", k, j, d)
+			}
+		}
+		if dualObj := floats.Dot(b, result.Dual); math.Abs(dualObj-result.Obj) > 1e-8 {
+			t.Errorf("Case %v: duality gap. Primal %v, dual %v", k, result.Obj, dualObj)
+		}
+	}
+}
+
+func TestRefactorSingular(t *testing.T) {
+	// The second column is a multiple of the first, but the elimination
+	// leaves a rounding error rather than an exact zero pivot
+	k := 0.3
+	a := mat64.NewDense(2, 2, []float64{
+		0.1, 0.1 * k,
+		0.3, 0.3 * k,
+	})
+	s := NewSimplex()
+	s.init(a, []float64{1, 1})
+	s.basis[0], s.basis[1] = 0, 1
+	if err := s.refactor(); err != ErrSingular {
+		t.Errorf("error mismatch. Want %v, found %v", ErrSingular, err)
+	}
+}
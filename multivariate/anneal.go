@@ -0,0 +1,275 @@
+package multivariate
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/write"
+)
+
+// TemperatureConverged is the status returned by SimulatedAnnealing when the
+// temperature drops below the minimum temperature
+var TemperatureConverged = common.NewStatus("TemperatureConverged")
+
+// NeighborGenerator proposes candidate locations for SimulatedAnnealing
+type NeighborGenerator interface {
+	// Neighbor sets dst to a candidate location near x. temp is the current
+	// temperature, and source is the source of randomness (which may be nil
+	// to signify the global source in math/rand)
+	Neighbor(dst, x []float64, temp float64, source *rand.Rand)
+}
+
+// GaussianNeighbor generates neighbors by adding normally distributed noise
+// with standard deviation StepSize to every dimension. If Lower and Upper are
+// not nil, the candidates are clamped to the bounds.
+type GaussianNeighbor struct {
+	StepSize float64
+	Lower    []float64
+	Upper    []float64
+}
+
+func (g *GaussianNeighbor) Neighbor(dst, x []float64, temp float64, source *rand.Rand) {
+	for i, v := range x {
+		dst[i] = v + g.StepSize*randNormFloat64(source)
+	}
+	if g.Lower != nil && g.Upper != nil {
+		ClampToBounds(dst, g.Lower, g.Upper)
+	}
+}
+
+// CoolingSchedule sets the temperature of SimulatedAnnealing
+type CoolingSchedule interface {
+	// Init is called at the start of the optimization and after every reheat
+	Init(initTemp float64)
+	// Temperature returns the temperature for the next iteration given the
+	// number of iterations since Init, the current temperature and the fraction
+	// of recent candidates that were accepted
+	Temperature(iter int, temp, acceptRate float64) float64
+}
+
+// ExponentialCooling multiplies the temperature by Rate every iteration
+type ExponentialCooling struct {
+	Rate float64
+}
+
+func (e *ExponentialCooling) Init(initTemp float64) {}
+
+func (e *ExponentialCooling) Temperature(iter int, temp, acceptRate float64) float64 {
+	return temp * e.Rate
+}
+
+// LogarithmicCooling sets the temperature to T_0 / log(e + iter). It cools
+// very slowly, which is the schedule needed for the convergence guarantees
+// of simulated annealing
+type LogarithmicCooling struct {
+	initTemp float64
+}
+
+func (l *LogarithmicCooling) Init(initTemp float64) {
+	l.initTemp = initTemp
+}
+
+func (l *LogarithmicCooling) Temperature(iter int, temp, acceptRate float64) float64 {
+	return l.initTemp / math.Log(math.E+float64(iter))
+}
+
+// AdaptiveCooling cools quickly when many candidates are accepted and slowly
+// when few are. The temperature is multiplied by Fast when the acceptance rate
+// is above Target and by Slow otherwise. Fast and Slow should be between zero
+// and one with Fast < Slow.
+type AdaptiveCooling struct {
+	Target float64
+	Fast   float64
+	Slow   float64
+}
+
+func (a *AdaptiveCooling) Init(initTemp float64) {}
+
+func (a *AdaptiveCooling) Temperature(iter int, temp, acceptRate float64) float64 {
+	if acceptRate > a.Target {
+		return temp * a.Fast
+	}
+	return temp * a.Slow
+}
+
+// SimulatedAnnealing is a gradient-free stochastic optimizer. At every
+// iteration a neighbor of the current location is proposed and accepted using
+// the Metropolis criterion at the current temperature. The location returned
+// at each iteration is the current location of the annealing process, so the
+// best location found is the one in the result.
+//
+// The temperature and the acceptance rate over the last AcceptanceWindow
+// iterations are added to the display.
+type SimulatedAnnealing struct {
+	Neighbor           NeighborGenerator
+	Cooling            CoolingSchedule
+	InitialTemperature float64
+	MinTemperature     float64 // Optimization ends with TemperatureConverged below this temperature
+	AcceptanceWindow   int     // Number of iterations over which the acceptance rate is computed
+
+	// ReheatAfter sets the temperature back to ReheatFraction times the
+	// initial temperature after that many iterations without improving on the
+	// best value found since the last reheat. If ReheatAfter is zero the
+	// temperature is never reheated.
+	ReheatAfter    int
+	ReheatFraction float64
+
+	Rand *rand.Rand // Source of randomness. If nil, the global source in math/rand is used
+
+	fun  Objective
+	nDim int
+
+	temp       float64
+	iter       int // Iterations since the last reheat
+	sinceImpr  int // Iterations since the best value improved
+	acceptHist []bool
+	nAccepted  int
+	histInd    int
+	histFilled bool
+
+	currLoc []float64
+	currObj float64
+	candLoc []float64
+	bestObj float64 // Best value since the last reheat
+}
+
+// NewSimulatedAnnealing returns a SimulatedAnnealing with Gaussian neighbors
+// of the given step size and exponential cooling from the given initial
+// temperature
+func NewSimulatedAnnealing(stepSize, initTemp float64) *SimulatedAnnealing {
+	return &SimulatedAnnealing{
+		Neighbor:           &GaussianNeighbor{StepSize: stepSize},
+		Cooling:            &ExponentialCooling{Rate: 0.995},
+		InitialTemperature: initTemp,
+		MinTemperature:     1e-8 * initTemp,
+		AcceptanceWindow:   100,
+		ReheatFraction:     1,
+	}
+}
+
+func (s *SimulatedAnnealing) Init(f Objective, initLoc []float64, initObj float64) error {
+	if s.Neighbor == nil {
+		return errors.New("simulated annealing: nil neighbor generator")
+	}
+	if s.Cooling == nil {
+		return errors.New("simulated annealing: nil cooling schedule")
+	}
+	if s.InitialTemperature <= 0 {
+		return errors.New("simulated annealing: initial temperature not positive")
+	}
+	if s.AcceptanceWindow < 1 {
+		return errors.New("simulated annealing: acceptance window must be positive")
+	}
+	s.fun = f
+	s.nDim = len(initLoc)
+
+	s.currLoc = make([]float64, s.nDim)
+	copy(s.currLoc, initLoc)
+	s.currObj = initObj
+	s.candLoc = make([]float64, s.nDim)
+
+	s.acceptHist = make([]bool, s.AcceptanceWindow)
+	s.setTemperature(s.InitialTemperature)
+	return nil
+}
+
+// setTemperature starts the cooling schedule from temp
+func (s *SimulatedAnnealing) setTemperature(temp float64) {
+	s.temp = temp
+	s.Cooling.Init(temp)
+	s.iter = 0
+	s.sinceImpr = 0
+	s.bestObj = s.currObj
+	for i := range s.acceptHist {
+		s.acceptHist[i] = false
+	}
+	s.nAccepted = 0
+	s.histInd = 0
+	s.histFilled = false
+}
+
+func (s *SimulatedAnnealing) Status() common.Status {
+	if s.temp < s.MinTemperature {
+		return TemperatureConverged
+	}
+	return common.Continue
+}
+
+// AcceptanceRate returns the fraction of candidates accepted over the last
+// AcceptanceWindow iterations
+func (s *SimulatedAnnealing) AcceptanceRate() float64 {
+	n := s.histInd
+	if s.histFilled {
+		n = len(s.acceptHist)
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(s.nAccepted) / float64(n)
+}
+
+// Temperature returns the current temperature
+func (s *SimulatedAnnealing) Temperature() float64 {
+	return s.temp
+}
+
+func (s *SimulatedAnnealing) Iterate(loc []float64) (obj float64, nFunEvals int, err error) {
+	if len(loc) != s.nDim {
+		panic("dimension mismatch")
+	}
+
+	s.Neighbor.Neighbor(s.candLoc, s.currLoc, s.temp, s.Rand)
+	candObj := s.fun.Obj(s.candLoc)
+
+	// Metropolis acceptance criterion
+	accepted := candObj <= s.currObj ||
+		randFloat64(s.Rand) < math.Exp(-(candObj-s.currObj)/s.temp)
+	if accepted {
+		s.currLoc, s.candLoc = s.candLoc, s.currLoc
+		s.currObj = candObj
+	}
+	s.addAcceptance(accepted)
+
+	if s.currObj < s.bestObj {
+		s.bestObj = s.currObj
+		s.sinceImpr = 0
+	} else {
+		s.sinceImpr++
+	}
+
+	s.iter++
+	if s.ReheatAfter > 0 && s.sinceImpr >= s.ReheatAfter {
+		s.setTemperature(s.ReheatFraction * s.InitialTemperature)
+	} else {
+		s.temp = s.Cooling.Temperature(s.iter, s.temp, s.AcceptanceRate())
+	}
+
+	copy(loc, s.currLoc)
+	return s.currObj, 1, nil
+}
+
+// addAcceptance adds the acceptance of the most recent candidate to the history
+func (s *SimulatedAnnealing) addAcceptance(accepted bool) {
+	if s.acceptHist[s.histInd] {
+		s.nAccepted--
+	}
+	s.acceptHist[s.histInd] = accepted
+	if accepted {
+		s.nAccepted++
+	}
+	s.histInd++
+	if s.histInd == len(s.acceptHist) {
+		s.histInd = 0
+		s.histFilled = true
+	}
+}
+
+func (s *SimulatedAnnealing) AppendWriteData(v []*write.Value) []*write.Value {
+	v = append(v, &write.Value{Heading: "Temp", Value: s.temp})
+	v = append(v, &write.Value{Heading: "AcceptRate", Value: s.AcceptanceRate()})
+	return v
+}
+
+func (s *SimulatedAnnealing) Result() {}
@@ -0,0 +1,97 @@
+package multivariate
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSimulatedAnnealing(t *testing.T) {
+	f := sphere{center: []float64{1, -2}}
+	for _, cooling := range []CoolingSchedule{
+		&ExponentialCooling{Rate: 0.99},
+		&LogarithmicCooling{},
+		&AdaptiveCooling{Target: 0.3, Fast: 0.95, Slow: 0.995},
+	} {
+		sa := NewSimulatedAnnealing(0.05, 1)
+		sa.Cooling = cooling
+		sa.ReheatAfter = 1000
+		sa.Rand = rand.New(rand.NewSource(1))
+
+		settings := DefaultSettings()
+		settings.DisplayWriters = nil
+		settings.MaximumFunctionEvaluations = 20000
+
+		result, err := OptimizeGradFree(f, []float64{3, 3}, settings, sa)
+		if err != nil {
+			t.Fatalf("Error optimizing: %v", err)
+		}
+		if result.Obj > 1e-3 {
+			t.Errorf("Cooling %T: minimum not found. Found %v at %v", cooling, result.Obj, result.Loc)
+		}
+		if rate := sa.AcceptanceRate(); rate < 0 || rate > 1 {
+			t.Errorf("Cooling %T: acceptance rate %v out of range", cooling, rate)
+		}
+	}
+}
+
+// firstCoord is an objective equal to the first coordinate
+type firstCoord struct{}
+
+func (firstCoord) Obj(x []float64) float64 {
+	return x[0]
+}
+
+// scriptedNeighbor proposes the candidates in order
+type scriptedNeighbor struct {
+	cands [][]float64
+}
+
+func (n *scriptedNeighbor) Neighbor(dst, x []float64, temp float64, source *rand.Rand) {
+	copy(dst, n.cands[0])
+	n.cands = n.cands[1:]
+}
+
+func TestSimulatedAnnealingReheat(t *testing.T) {
+	// The temperature is small enough that every uphill candidate is
+	// rejected. After two improvements and three rejections the temperature
+	// is reheated and the acceptance history is cleared.
+	sa := &SimulatedAnnealing{
+		Neighbor: &scriptedNeighbor{
+			cands: [][]float64{{-1}, {-2}, {0}, {0}, {0}, {-3}},
+		},
+		Cooling:            &ExponentialCooling{Rate: 0.5},
+		InitialTemperature: 1e-10,
+		AcceptanceWindow:   3,
+		ReheatAfter:        3,
+		ReheatFraction:     0.5,
+	}
+	if err := sa.Init(firstCoord{}, []float64{0}, 0); err != nil {
+		t.Fatalf("Error initializing: %v", err)
+	}
+	for i, want := range []struct {
+		temp, rate float64
+	}{
+		{0.5e-10, 1},
+		{0.25e-10, 1},
+		{0.125e-10, 2.0 / 3},
+		{0.0625e-10, 1.0 / 3},
+		{0.5e-10, 0},
+		{0.25e-10, 1},
+	} {
+		loc := make([]float64, 1)
+		if _, _, err := sa.Iterate(loc); err != nil {
+			t.Fatalf("Error iterating: %v", err)
+		}
+		v := sa.AppendWriteData(nil)
+		if len(v) != 2 || v[0].Heading != "Temp" || v[1].Heading != "AcceptRate" {
+			t.Fatalf("Wrong display columns: %v, %v", v[0].Heading, v[1].Heading)
+		}
+		if temp := v[0].Value.(float64); math.Abs(temp-want.temp) > 1e-14*want.temp {
+			t.Errorf("Iteration %v: temperature mismatch. Want %v, found %v", i, want.temp, temp)
+		}
+		if rate := v[1].Value.(float64); math.Abs(rate-want.rate) > 1e-14 {
+			t.Errorf("Iteration %v: acceptance rate mismatch. Want %v, found %v", i, want.rate, rate)
+		}
+	}
+}
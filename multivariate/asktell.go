@@ -0,0 +1,191 @@
+package multivariate
+
+import (
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/btracey/opt/common"
+)
+
+// Request is a location at which the caller of an AskTeller must evaluate
+// the objective function.
+type Request struct {
+	Loc  []float64 // Location to evaluate
+	Grad []float64 // For gradient-based optimizers, the gradient at Loc must be stored here before Tell. Nil otherwise
+
+	obj  float64
+	done chan struct{}
+}
+
+// AskTeller runs an optimizer in ask/tell mode. Instead of the optimizer
+// calling the objective function, the caller asks for the next location to
+// evaluate and tells the AskTeller the value once it is known. This allows
+// the evaluations to happen outside of the optimization call, for example as
+// jobs on a batch scheduler.
+//
+// The optimizer is run in its own goroutine, so the optimizers themselves
+// are unchanged. Optimizers that evaluate the objective concurrently (for
+// example a ParticleSwarm with a BatchEvaluator) may have several requests
+// outstanding at once, and the requests may be told in any order.
+//
+// A typical use is
+//
+//	at := NewGradAskTeller(initLoc, settings, optimizer)
+//	for {
+//		req, ok := at.Ask()
+//		if !ok {
+//			break
+//		}
+//		obj := myObjGrad(req.Loc, req.Grad)
+//		at.Tell(req, obj)
+//	}
+//	result, err := at.Result()
+type AskTeller struct {
+	requests chan *Request
+	abort    chan struct{}
+	stopOnce sync.Once
+
+	result *Result
+	err    error
+}
+
+// NewGradAskTeller starts an optimization with a gradient-based optimizer in
+// ask/tell mode. The arguments are the same as for OptimizeGrad, except that
+// the objective function is replaced by calls to Ask and Tell.
+func NewGradAskTeller(initLoc []float64, settings *Settings, optimizer GradOptimizer) *AskTeller {
+	a := newAskTeller()
+	go func() {
+		defer close(a.requests)
+		a.finish(OptimizeGrad(askFunction{a}, initLoc, settings, optimizer))
+	}()
+	return a
+}
+
+// NewGradFreeAskTeller starts an optimization with a gradient-free optimizer in
+// ask/tell mode. The arguments are the same as for OptimizeGradFree, except that
+// the objective function is replaced by calls to Ask and Tell.
+func NewGradFreeAskTeller(initLoc []float64, settings *Settings, optimizer GradFreeOptimizer) *AskTeller {
+	a := newAskTeller()
+	go func() {
+		defer close(a.requests)
+		a.finish(OptimizeGradFree(askFunction{a}, initLoc, settings, optimizer))
+	}()
+	return a
+}
+
+func newAskTeller() *AskTeller {
+	return &AskTeller{
+		requests: make(chan *Request),
+		abort:    make(chan struct{}),
+	}
+}
+
+// finish stores the result of the optimization. The error of a stopped
+// optimization is kept even if the optimizer ended normally.
+func (a *AskTeller) finish(result *Result, err error) {
+	a.result = result
+	a.err = err
+	if a.stopped() {
+		a.err = errStopped
+	}
+}
+
+// stopped returns whether Stop has been called
+func (a *AskTeller) stopped() bool {
+	select {
+	case <-a.abort:
+		return true
+	default:
+		return false
+	}
+}
+
+var errStopped = errors.New("ask tell: optimization stopped")
+
+// Ask returns the next location at which the objective must be evaluated. Ask
+// blocks until the optimizer needs a new evaluation. If the optimization has
+// finished, ok is false.
+func (a *AskTeller) Ask() (req *Request, ok bool) {
+	req, ok = <-a.requests
+	return req, ok
+}
+
+// Tell reports the value of the objective at the requested location. For
+// gradient-based optimizers the gradient must already be stored in req.Grad.
+func (a *AskTeller) Tell(req *Request, obj float64) {
+	req.obj = obj
+	close(req.done)
+}
+
+// Stop ends the optimization early. Any outstanding requests are abandoned,
+// and Result returns an error along with the result so far, if the optimizer
+// produced one. After Stop the objective is NaN at every location and the
+// optimization ends with the status UserFunctionError at the next
+// convergence check.
+func (a *AskTeller) Stop() {
+	a.stopOnce.Do(func() { close(a.abort) })
+}
+
+// Result returns the result of the optimization. It must only be called
+// after Ask has returned false.
+func (a *AskTeller) Result() (*Result, error) {
+	return a.result, a.err
+}
+
+// askFunction is the objective function given to the optimizer. Every call
+// sends a request to the caller of Ask and waits for it to be told.
+type askFunction struct {
+	a *AskTeller
+}
+
+// Status ends the optimization once it has been stopped. The objective
+// function may be called from any goroutine, such as a worker of a
+// BatchEvaluator, so it can not end the optimization itself.
+func (f askFunction) Status() common.Status {
+	if f.a.stopped() {
+		return common.UserFunctionError
+	}
+	return common.Continue
+}
+
+func (f askFunction) Obj(x []float64) float64 {
+	return f.request(x, nil)
+}
+
+func (f askFunction) ObjGrad(x []float64, g []float64) float64 {
+	return f.request(x, g)
+}
+
+func (f askFunction) request(x, g []float64) float64 {
+	req := &Request{
+		Loc:  make([]float64, len(x)),
+		done: make(chan struct{}),
+	}
+	copy(req.Loc, x)
+	if g != nil {
+		req.Grad = make([]float64, len(g))
+	}
+
+	select {
+	case f.a.requests <- req:
+	case <-f.a.abort:
+		return abandoned(g)
+	}
+	select {
+	case <-req.done:
+	case <-f.a.abort:
+		return abandoned(g)
+	}
+	copy(g, req.Grad)
+	return req.obj
+}
+
+// abandoned fills the gradient of an abandoned request with NaN and returns
+// NaN as the objective
+func abandoned(g []float64) float64 {
+	for i := range g {
+		g[i] = math.NaN()
+	}
+	return math.NaN()
+}
@@ -0,0 +1,113 @@
+package multivariate
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/btracey/opt/common"
+
+	"github.com/gonum/floats"
+)
+
+func TestAskTell(t *testing.T) {
+	settings := DefaultSettings()
+	settings.DisplayWriters = nil
+
+	r := &Rosenbrock{nDim: 5}
+	initLoc := []float64{1.3, 0.7, 0.8, 1.9, 1.2}
+	want, err := OptimizeGrad(r, initLoc, settings, NewBfgs())
+	if err != nil {
+		t.Fatalf("Error optimizing: %v", err)
+	}
+
+	at := NewGradAskTeller(initLoc, settings, NewBfgs())
+	for {
+		req, ok := at.Ask()
+		if !ok {
+			break
+		}
+		at.Tell(req, r.ObjGrad(req.Loc, req.Grad))
+	}
+	result, err := at.Result()
+	if err != nil {
+		t.Fatalf("Error in ask tell: %v", err)
+	}
+	if !floats.Equal(result.Loc, want.Loc) || result.Iterations != want.Iterations {
+		t.Errorf("Ask tell result does not match OptimizeGrad")
+	}
+
+	// Concurrent evaluations lead to several outstanding requests, which can
+	// be told in any order
+	f := sphere{center: []float64{1, -2}}
+	pso := NewParticleSwarm([]float64{-5, -5}, []float64{5, 5})
+	pso.Evaluator = NewBatchEvaluator(4)
+	pso.Rand = rand.New(rand.NewSource(1))
+	settings.MaximumIterations = 50
+	at = NewGradFreeAskTeller([]float64{4, 4}, settings, pso)
+	for {
+		req, ok := at.Ask()
+		if !ok {
+			break
+		}
+		if req.Grad != nil {
+			t.Errorf("Gradient requested from a gradient-free optimizer")
+		}
+		go func(req *Request) {
+			at.Tell(req, f.Obj(req.Loc))
+		}(req)
+	}
+	if _, err = at.Result(); err != nil {
+		t.Errorf("Error in ask tell: %v", err)
+	}
+
+	// Stopping should end the optimization with an error
+	at = NewGradAskTeller(initLoc, settings, NewBfgs())
+	at.Ask()
+	at.Stop()
+	for {
+		if _, ok := at.Ask(); !ok {
+			break
+		}
+	}
+	if _, err = at.Result(); err == nil {
+		t.Errorf("Expected error after stop")
+	}
+
+	// Stopping a swarm that evaluates in several workers abandons the whole
+	// batch and ends at the next convergence check
+	pso = NewParticleSwarm([]float64{-5, -5}, []float64{5, 5})
+	pso.NumParticles = 20
+	pso.Evaluator = NewBatchEvaluator(4)
+	pso.Rand = rand.New(rand.NewSource(1))
+	pso.Tol = 0
+	settings.MaximumIterations = 1000
+	at = NewGradFreeAskTeller([]float64{4, 4}, settings, pso)
+	var nTold int
+	for {
+		req, ok := at.Ask()
+		if !ok {
+			break
+		}
+		if nTold == 50 {
+			at.Stop()
+			continue
+		}
+		at.Tell(req, f.Obj(req.Loc))
+		nTold++
+	}
+	result, err = at.Result()
+	if err == nil {
+		t.Errorf("Expected error after stopping a concurrent optimizer")
+	}
+	if result == nil {
+		t.Fatalf("No result after stopping a concurrent optimizer")
+	}
+	if result.Status != common.UserFunctionError {
+		t.Errorf("Status mismatch after stop. Want %v, found %v", common.UserFunctionError, result.Status)
+	}
+	// 50 evaluations are the initial location and two batches of 20, so the
+	// swarm is in its third iteration when it is stopped
+	if result.Iterations > 3 {
+		t.Errorf("Optimization continued after stop: %v iterations", result.Iterations)
+	}
+}
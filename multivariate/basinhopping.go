@@ -0,0 +1,204 @@
+package multivariate
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/write"
+)
+
+// HopResult is the outcome of one hop of BasinHopping
+type HopResult struct {
+	Hop      int       // Number of the hop. The zero hop is the local optimization from the initial location
+	Start    []float64 // Perturbed location from which the local optimization started
+	Local    *Result   // Result of the local optimization, or nil if the local optimization failed
+	Accepted bool      // Whether the local minimum was accepted as the new current location
+	StepSize float64   // Size of the perturbation
+}
+
+// BasinHopping is a global optimizer that alternates random perturbations of
+// the current location with local optimizations. The local minimum found
+// after each perturbation is accepted as the new current location using the
+// Metropolis criterion. The size of the perturbation is adapted so that the
+// fraction of accepted hops is near TargetAcceptRate.
+//
+// Every iteration is one hop, and the location returned is the best local
+// minimum found so far. As every hop ends at a local minimum, the gradient
+// tolerance should not be used as the stopping criterion; see
+// DefaultBasinHoppingSettings.
+type BasinHopping struct {
+	Local         GradOptimizer // Optimizer for the local optimizations
+	LocalSettings *Settings     // Settings for the local optimizations
+
+	StepSize    float64 // Perturbations are uniform in [-StepSize, StepSize] in every dimension
+	Temperature float64 // Temperature of the Metropolis criterion
+
+	TargetAcceptRate float64 // Desired fraction of accepted hops
+	AdaptInterval    int     // Number of hops between updates of the step size. If zero, the step size is not adapted
+	StepFactor       float64 // Factor by which the step size is changed, between zero and one
+
+	Callback func(*HopResult) // If not nil, called after every hop
+
+	Rand *rand.Rand // Source of randomness. If nil, the global source in math/rand is used
+
+	fun  *countingObjGrader
+	nDim int
+
+	hop        int
+	step       float64
+	nAccepted  int // Accepted hops since the last step size update
+	acceptRate float64
+
+	trial    []float64
+	currLoc  []float64
+	currObj  float64
+	bestLoc  []float64
+	bestObj  float64
+	bestGrad []float64
+}
+
+// NewBasinHopping returns a BasinHopping with the given step size, with
+// local optimizations done by Bfgs
+func NewBasinHopping(stepSize float64) *BasinHopping {
+	localSettings := DefaultSettings()
+	localSettings.DisplayWriters = nil
+	return &BasinHopping{
+		Local:            NewBfgs(),
+		LocalSettings:    localSettings,
+		StepSize:         stepSize,
+		Temperature:      1,
+		TargetAcceptRate: 0.5,
+		AdaptInterval:    50,
+		StepFactor:       0.9,
+	}
+}
+
+// DefaultBasinHoppingSettings returns settings for optimizing with BasinHopping.
+// The gradient tolerance is turned off and the optimization ends after 100 hops.
+func DefaultBasinHoppingSettings() *Settings {
+	s := DefaultSettings()
+	s.GradAbsTol = math.NaN()
+	s.MaximumIterations = 100
+	return s
+}
+
+func (b *BasinHopping) Init(f ObjGrader, initLoc []float64, initObj float64, initGrad []float64) error {
+	if b.Local == nil {
+		return errors.New("basin hopping: nil local optimizer")
+	}
+	if b.StepSize <= 0 {
+		return errors.New("basin hopping: step size not positive")
+	}
+	if b.Temperature < 0 {
+		return errors.New("basin hopping: negative temperature")
+	}
+	b.fun = &countingObjGrader{f: f}
+	b.nDim = len(initLoc)
+
+	b.hop = 0
+	b.step = b.StepSize
+	b.nAccepted = 0
+	b.acceptRate = 0
+
+	b.trial = make([]float64, b.nDim)
+	b.currLoc = make([]float64, b.nDim)
+	copy(b.currLoc, initLoc)
+	b.currObj = initObj
+
+	b.bestLoc = make([]float64, b.nDim)
+	copy(b.bestLoc, initLoc)
+	b.bestObj = initObj
+	b.bestGrad = make([]float64, b.nDim)
+	copy(b.bestGrad, initGrad)
+	return nil
+}
+
+func (b *BasinHopping) Status() common.Status {
+	return common.Continue
+}
+
+func (b *BasinHopping) Iterate(loc, grad []float64) (obj float64, nFunEvals int, err error) {
+	if len(loc) != b.nDim {
+		panic("dimension mismatch")
+	}
+	if len(grad) != b.nDim {
+		panic("dimension mismatch")
+	}
+
+	// The first hop is a local optimization from the initial location
+	copy(b.trial, b.currLoc)
+	if b.hop > 0 {
+		for i := range b.trial {
+			b.trial[i] += b.step * (2*randFloat64(b.Rand) - 1)
+		}
+	}
+
+	b.fun.nFunEvals = 0
+	local, err := OptimizeGrad(b.fun, b.trial, b.LocalSettings, b.Local)
+	if err != nil || local.Loc == nil {
+		local = nil
+	}
+
+	// Metropolis acceptance criterion
+	accepted := local != nil &&
+		(b.hop == 0 || local.Obj <= b.currObj ||
+			randFloat64(b.Rand) < math.Exp(-(local.Obj-b.currObj)/b.Temperature))
+	if accepted {
+		copy(b.currLoc, local.Loc)
+		b.currObj = local.Obj
+		b.nAccepted++
+	}
+	if local != nil && local.Obj < b.bestObj {
+		copy(b.bestLoc, local.Loc)
+		b.bestObj = local.Obj
+		copy(b.bestGrad, local.Grad)
+	}
+
+	if b.Callback != nil {
+		start := make([]float64, b.nDim)
+		copy(start, b.trial)
+		b.Callback(&HopResult{
+			Hop:      b.hop,
+			Start:    start,
+			Local:    local,
+			Accepted: accepted,
+			StepSize: b.step,
+		})
+	}
+
+	b.hop++
+	if b.AdaptInterval > 0 && b.hop%b.AdaptInterval == 0 {
+		b.acceptRate = float64(b.nAccepted) / float64(b.AdaptInterval)
+		if b.acceptRate > b.TargetAcceptRate {
+			b.step /= b.StepFactor
+		} else {
+			b.step *= b.StepFactor
+		}
+		b.nAccepted = 0
+	}
+
+	copy(loc, b.bestLoc)
+	copy(grad, b.bestGrad)
+	return b.bestObj, b.fun.nFunEvals, nil
+}
+
+func (b *BasinHopping) AppendWriteData(v []*write.Value) []*write.Value {
+	v = append(v, &write.Value{Heading: "StepSize", Value: b.step})
+	v = append(v, &write.Value{Heading: "AcceptRate", Value: b.acceptRate})
+	return v
+}
+
+func (b *BasinHopping) Result() {}
+
+// countingObjGrader counts the number of function evaluations
+type countingObjGrader struct {
+	f         ObjGrader
+	nFunEvals int
+}
+
+func (c *countingObjGrader) ObjGrad(x []float64, g []float64) float64 {
+	c.nFunEvals++
+	return c.f.ObjGrad(x, g)
+}
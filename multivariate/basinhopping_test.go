@@ -0,0 +1,49 @@
+package multivariate
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// rastriginGrad is rastrigin with its gradient
+type rastriginGrad struct{}
+
+func (rastriginGrad) ObjGrad(x, grad []float64) float64 {
+	for i, v := range x {
+		grad[i] = 2*v + 20*math.Pi*math.Sin(2*math.Pi*v)
+	}
+	return rastrigin{}.Obj(x)
+}
+
+func TestBasinHopping(t *testing.T) {
+	b := NewBasinHopping(1)
+	b.AdaptInterval = 10
+	b.Rand = rand.New(rand.NewSource(1))
+	var nHops, nAccepted int
+	b.Callback = func(h *HopResult) {
+		if h.Hop != nHops {
+			t.Errorf("Hop number mismatch")
+		}
+		nHops++
+		if h.Accepted {
+			nAccepted++
+		}
+	}
+
+	settings := DefaultBasinHoppingSettings()
+	settings.DisplayWriters = nil
+	result, err := OptimizeGrad(rastriginGrad{}, []float64{3.1, -2.9}, settings, b)
+	if err != nil {
+		t.Fatalf("Error optimizing: %v", err)
+	}
+	if result.Obj > 1e-8 {
+		t.Errorf("Global minimum not found. Found %v at %v", result.Obj, result.Loc)
+	}
+	if nHops != result.Iterations {
+		t.Errorf("Callback called %v times for %v hops", nHops, result.Iterations)
+	}
+	if nAccepted == 0 || nAccepted == nHops {
+		t.Errorf("Unexpected number of accepted hops: %v of %v", nAccepted, nHops)
+	}
+}
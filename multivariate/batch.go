@@ -0,0 +1,162 @@
+package multivariate
+
+import (
+	"math"
+	"sync"
+)
+
+// ObjectiveCopier is an objective function that can make independent copies
+// of itself. Objectives that are not safe for concurrent use should implement
+// ObjectiveCopier so that each worker of a BatchEvaluator has its own copy.
+type ObjectiveCopier interface {
+	CopyObjective() Objective
+}
+
+// BatchEvaluator evaluates an objective function at a set of locations using
+// a pool of goroutines. The objective values are always returned in the order
+// of the locations, regardless of the order in which the evaluations finish.
+//
+// A nil *BatchEvaluator evaluates the locations serially.
+type BatchEvaluator struct {
+	// Workers is the number of goroutines used to evaluate the objective.
+	// If Workers is less than two, the objective is evaluated serially
+	Workers int
+}
+
+// NewBatchEvaluator returns a BatchEvaluator with the given number of workers
+func NewBatchEvaluator(workers int) *BatchEvaluator {
+	return &BatchEvaluator{
+		Workers: workers,
+	}
+}
+
+// Evaluate evaluates f at all of the locations and stores the values in objs.
+// If f is an ObjectiveCopier, every worker evaluates its own copy of f made
+// at the start of the call, otherwise f must be safe for concurrent use.
+// The locations must not be modified by f.
+func (b *BatchEvaluator) Evaluate(f Objective, locs [][]float64, objs []float64) {
+	if len(locs) != len(objs) {
+		panic("batch: length mismatch")
+	}
+	workers := 1
+	if b != nil {
+		workers = b.Workers
+	}
+	if workers > len(locs) {
+		workers = len(locs)
+	}
+	if workers < 2 {
+		for i, x := range locs {
+			objs[i] = f.Obj(x)
+		}
+		return
+	}
+
+	copier, isCopier := f.(ObjectiveCopier)
+
+	inds := make(chan int, len(locs))
+	for i := range locs {
+		inds <- i
+	}
+	close(inds)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		fun := f
+		if isCopier {
+			fun = copier.CopyObjective()
+		}
+		go func(fun Objective) {
+			defer wg.Done()
+			for i := range inds {
+				objs[i] = fun.Obj(locs[i])
+			}
+		}(fun)
+	}
+	wg.Wait()
+}
+
+// FiniteDifference is an ObjGrader that approximates the gradient of an
+// Objective using finite differences. All of the function evaluations for
+// one gradient are done as a single batch by Evaluator, so they can happen
+// concurrently.
+//
+// Note that the number of function evaluations reported by the optimizers
+// counts calls to ObjGrad, not the calls to the underlying objective.
+type FiniteDifference struct {
+	Objective
+
+	// Step is the relative step size. The step in dimension i is
+	// Step * max(1, |x_i|)
+	Step float64
+
+	// Central sets the use of central differences, which are more accurate
+	// but take twice as many function evaluations as forward differences
+	Central bool
+
+	// Evaluator evaluates the objective at the perturbed locations. If nil,
+	// the objective is evaluated serially
+	Evaluator *BatchEvaluator
+
+	locs [][]float64
+	objs []float64
+}
+
+// NewFiniteDifference returns a forward-difference approximation of the
+// gradient of f with a step size appropriate for double precision
+func NewFiniteDifference(f Objective) *FiniteDifference {
+	return &FiniteDifference{
+		Objective: f,
+		Step:      math.Sqrt(2.2e-16),
+	}
+}
+
+// ObjGrad returns the objective at x and puts the approximate gradient in g
+func (fd *FiniteDifference) ObjGrad(x []float64, g []float64) float64 {
+	if len(x) != len(g) {
+		panic("finite difference: dimension mismatch")
+	}
+	nDim := len(x)
+
+	// The first location is x itself, followed by the perturbations in
+	// the positive (and for central differences, negative) directions
+	nLocs := nDim + 1
+	if fd.Central {
+		nLocs += nDim
+	}
+	fd.locs = resizeSlices(fd.locs, nLocs, nDim)
+	if cap(fd.objs) < nLocs {
+		fd.objs = make([]float64, nLocs)
+	}
+	fd.objs = fd.objs[:nLocs]
+
+	for i := range fd.locs {
+		copy(fd.locs[i], x)
+	}
+	for i, v := range x {
+		h := fd.step(v)
+		fd.locs[i+1][i] += h
+		if fd.Central {
+			fd.locs[nDim+i+1][i] -= h
+		}
+	}
+
+	fd.Evaluator.Evaluate(fd.Objective, fd.locs, fd.objs)
+
+	obj := fd.objs[0]
+	for i, v := range x {
+		// Use the actual difference in the locations to reduce roundoff error
+		if fd.Central {
+			g[i] = (fd.objs[i+1] - fd.objs[nDim+i+1]) / (fd.locs[i+1][i] - fd.locs[nDim+i+1][i])
+		} else {
+			g[i] = (fd.objs[i+1] - obj) / (fd.locs[i+1][i] - v)
+		}
+	}
+	return obj
+}
+
+// step returns the finite difference step for a location with value v
+func (fd *FiniteDifference) step(v float64) float64 {
+	return fd.Step * math.Max(1, math.Abs(v))
+}
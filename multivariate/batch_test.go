@@ -0,0 +1,100 @@
+package multivariate
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/gonum/floats"
+)
+
+// scratchSphere is a sphere that is not safe for concurrent use because it
+// stores the difference in a buffer
+type scratchSphere struct {
+	center []float64
+	diff   []float64
+}
+
+func (s *scratchSphere) Obj(x []float64) float64 {
+	copy(s.diff, x)
+	floats.Sub(s.diff, s.center)
+	return floats.Dot(s.diff, s.diff)
+}
+
+func (s *scratchSphere) CopyObjective() Objective {
+	return &scratchSphere{
+		center: s.center,
+		diff:   make([]float64, len(s.diff)),
+	}
+}
+
+// objOnly hides the gradient of an ObjGrader
+type objOnly struct {
+	f ObjGrader
+}
+
+func (o objOnly) Obj(x []float64) float64 {
+	return o.f.ObjGrad(x, make([]float64, len(x)))
+}
+
+func TestBatchEvaluator(t *testing.T) {
+	f := &scratchSphere{center: []float64{1, 2, 3}, diff: make([]float64, 3)}
+	locs := make([][]float64, 100)
+	want := make([]float64, len(locs))
+	for i := range locs {
+		locs[i] = []float64{float64(i), 0, -float64(i)}
+		want[i] = f.Obj(locs[i])
+	}
+	for _, b := range []*BatchEvaluator{nil, NewBatchEvaluator(1), NewBatchEvaluator(8), NewBatchEvaluator(200)} {
+		objs := make([]float64, len(locs))
+		b.Evaluate(f, locs, objs)
+		if !floats.Equal(objs, want) {
+			t.Errorf("Batch evaluation mismatch with evaluator %v", b)
+		}
+	}
+}
+
+func TestFiniteDifference(t *testing.T) {
+	r := &Rosenbrock{nDim: 5}
+	x := []float64{1.3, 0.7, 0.8, 1.9, 1.2}
+	want := make([]float64, len(x))
+	wantObj := r.ObjGrad(x, want)
+
+	for _, central := range []bool{false, true} {
+		fd := NewFiniteDifference(objOnly{r})
+		fd.Central = central
+		fd.Evaluator = NewBatchEvaluator(4)
+		if central {
+			fd.Step = 1e-5
+		}
+		g := make([]float64, len(x))
+		obj := fd.ObjGrad(x, g)
+		if obj != wantObj {
+			t.Errorf("Objective mismatch. Want %v, found %v", wantObj, obj)
+		}
+		if !floats.EqualApprox(g, want, 1e-5) {
+			t.Errorf("Gradient mismatch with central = %v. Want %v, found %v", central, want, g)
+		}
+	}
+}
+
+func TestParticleSwarmBatch(t *testing.T) {
+	// Results should not depend on the evaluator
+	f := &scratchSphere{center: []float64{1, -2}, diff: make([]float64, 2)}
+	var results [][]float64
+	for _, b := range []*BatchEvaluator{nil, NewBatchEvaluator(4)} {
+		pso := NewParticleSwarm([]float64{-5, -5}, []float64{5, 5})
+		pso.Evaluator = b
+		pso.Rand = rand.New(rand.NewSource(1))
+		settings := DefaultSettings()
+		settings.DisplayWriters = nil
+		settings.MaximumIterations = 50
+		result, err := OptimizeGradFree(f, []float64{4, 4}, settings, pso)
+		if err != nil {
+			t.Fatalf("Error optimizing: %v", err)
+		}
+		results = append(results, result.Loc)
+	}
+	if !floats.Equal(results[0], results[1]) {
+		t.Errorf("Serial and concurrent results differ: %v and %v", results[0], results[1])
+	}
+}
@@ -0,0 +1,65 @@
+package bayesopt
+
+import "math"
+
+// Acquisition measures how useful it would be to evaluate the objective at a
+// location, given the prediction of the surrogate at that location. The next
+// location evaluated is the one that maximizes the acquisition function.
+type Acquisition interface {
+	// Value returns the value of the acquisition function given the predicted
+	// mean and standard deviation at a location and the best objective value
+	// observed so far
+	Value(mean, std, best float64) float64
+}
+
+// ExpectedImprovement is the expected amount by which the objective value at
+// a location will be less than best - Xi. Larger values of Xi favor
+// exploration.
+type ExpectedImprovement struct {
+	Xi float64
+}
+
+func (e ExpectedImprovement) Value(mean, std, best float64) float64 {
+	diff := best - e.Xi - mean
+	if std == 0 {
+		return math.Max(diff, 0)
+	}
+	z := diff / std
+	return diff*normCDF(z) + std*normPDF(z)
+}
+
+// ProbabilityOfImprovement is the probability that the objective value at a
+// location is less than best - Xi. Larger values of Xi favor exploration.
+type ProbabilityOfImprovement struct {
+	Xi float64
+}
+
+func (p ProbabilityOfImprovement) Value(mean, std, best float64) float64 {
+	diff := best - p.Xi - mean
+	if std == 0 {
+		if diff > 0 {
+			return 1
+		}
+		return 0
+	}
+	return normCDF(diff / std)
+}
+
+// UpperConfidenceBound is the optimistic bound mean - Kappa*std on the
+// objective value, negated so that it is maximized. For minimization this is
+// the lower confidence bound. Larger values of Kappa favor exploration.
+type UpperConfidenceBound struct {
+	Kappa float64
+}
+
+func (u UpperConfidenceBound) Value(mean, std, best float64) float64 {
+	return u.Kappa*std - mean
+}
+
+func normCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+func normPDF(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
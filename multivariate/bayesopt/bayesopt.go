@@ -0,0 +1,316 @@
+package bayesopt
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/multivariate"
+
+	"github.com/gonum/floats"
+)
+
+// BayesOpt is a Bayesian optimizer for bounded problems with expensive
+// objective functions. A Gaussian process surrogate is fit to all of the
+// observations, and the next location is chosen by maximizing an acquisition
+// function of the surrogate over the bounds. BayesOpt evaluates the objective
+// once per iteration, so the optimization should be limited by
+// MaximumFunctionEvaluations.
+//
+// The first iterations evaluate an initial design of InitialSamples
+// locations. The initial location is also used if it is within the bounds.
+// Lower and Upper must be set before optimizing and must be finite.
+type BayesOpt struct {
+	Lower []float64
+	Upper []float64
+
+	// Kernel is the covariance function of the surrogate. Its hyperparameters
+	// are refit at every iteration. If nil, a Matern52 kernel with length
+	// scales of a quarter of the width of the bounds is used
+	Kernel Kernel
+	Noise  float64 // Initial noise variance of the surrogate, relative to the variance of the observations
+
+	Acquisition Acquisition // Acquisition function. If nil, ExpectedImprovement with Xi = 0.01 is used
+
+	InitialSamples int                  // Number of locations in the initial design
+	Sampler        multivariate.Sampler // Generates the initial design. If nil, LatinHypercube is used
+
+	// The acquisition function is maximized by evaluating it at NumCandidates
+	// random locations and starting NumStarts local optimizations from the
+	// best of them
+	NumCandidates int
+	NumStarts     int
+
+	Rand *rand.Rand // Source of randomness. If nil, the global source in math/rand is used
+
+	fun  multivariate.Objective
+	nDim int
+	gp   *GP
+
+	design [][]float64 // Locations of the initial design not yet evaluated
+	x      [][]float64
+	y      []float64
+
+	bestInd int
+}
+
+// defaultAcquisition is the acquisition function used by NewBayesOpt and when
+// Acquisition is nil
+var defaultAcquisition = ExpectedImprovement{Xi: 0.01}
+
+// NewBayesOpt returns a BayesOpt with the given bounds, an initial design of
+// 2n+1 locations for an n-dimensional problem, and expected improvement as the
+// acquisition function
+func NewBayesOpt(lower, upper []float64) *BayesOpt {
+	return &BayesOpt{
+		Lower:          lower,
+		Upper:          upper,
+		Noise:          1e-6,
+		Acquisition:    defaultAcquisition,
+		InitialSamples: 2*len(lower) + 1,
+		NumCandidates:  1000,
+		NumStarts:      5,
+	}
+}
+
+func (b *BayesOpt) Init(f multivariate.Objective, initLoc []float64, initObj float64) error {
+	b.nDim = len(initLoc)
+	err := multivariate.CheckBounds(b.Lower, b.Upper, b.nDim)
+	if err != nil {
+		return errors.New("bayesopt: " + err.Error())
+	}
+	if b.Noise <= 0 {
+		return errors.New("bayesopt: noise not positive")
+	}
+	if b.NumStarts < 1 || b.NumCandidates < 1 {
+		return errors.New("bayesopt: number of starts and candidates must be positive")
+	}
+	b.fun = f
+
+	kernel := b.Kernel
+	if kernel == nil {
+		lengthScales := make([]float64, b.nDim)
+		for i := range lengthScales {
+			lengthScales[i] = 0.25 * (b.Upper[i] - b.Lower[i])
+		}
+		kernel = NewMatern52(lengthScales)
+	}
+	b.gp = NewGP(kernel, b.Noise)
+	b.setHyperBounds()
+
+	b.x = b.x[:0]
+	b.y = b.y[:0]
+	b.bestInd = -1
+	if multivariate.InBounds(initLoc, b.Lower, b.Upper) && !math.IsNaN(initObj) {
+		b.add(initLoc, initObj)
+	}
+
+	b.design = nil
+	if b.InitialSamples > 0 {
+		b.design = make([][]float64, b.InitialSamples)
+		for i := range b.design {
+			b.design[i] = make([]float64, b.nDim)
+		}
+		sampler := b.Sampler
+		if sampler == nil {
+			sampler = multivariate.LatinHypercube{Rand: b.Rand}
+		}
+		sampler.Sample(b.design, b.Lower, b.Upper)
+	}
+	return nil
+}
+
+func (b *BayesOpt) Status() common.Status {
+	return common.Continue
+}
+
+func (b *BayesOpt) Iterate(loc []float64) (obj float64, nFunEvals int, err error) {
+	if len(loc) != b.nDim {
+		panic("dimension mismatch")
+	}
+	var next []float64
+	if len(b.design) > 0 || len(b.x) == 0 {
+		next = make([]float64, b.nDim)
+		if len(b.design) > 0 {
+			copy(next, b.design[0])
+			b.design = b.design[1:]
+		} else {
+			multivariate.UniformSampler{Rand: b.Rand}.Sample([][]float64{next}, b.Lower, b.Upper)
+		}
+	} else {
+		err = b.gp.FitHyper(b.x, b.y)
+		if err != nil {
+			return b.y[b.bestInd], 0, errors.New("bayesopt: " + err.Error())
+		}
+		next = b.maximizeAcquisition()
+	}
+	b.add(next, b.fun.Obj(next))
+
+	copy(loc, b.x[b.bestInd])
+	return b.y[b.bestInd], 1, nil
+}
+
+// setHyperBounds limits the hyperparameters of the surrogate when the kernel
+// is one of the kernels in this package. The observations are normalized, so
+// the variance should be near one, and length scales much shorter or longer
+// than the bounds give a surrogate that does not generalize.
+func (b *BayesOpt) setHyperBounds() {
+	var lengthScales []float64
+	switch k := b.gp.Kernel.(type) {
+	case *SquaredExponential:
+		lengthScales = k.LengthScales
+	case *Matern52:
+		lengthScales = k.LengthScales
+	default:
+		return
+	}
+	if len(lengthScales) != b.nDim {
+		return
+	}
+	n := b.gp.NumHyper()
+	lower := make([]float64, n)
+	upper := make([]float64, n)
+	lower[0] = math.Log(0.05)
+	upper[0] = math.Log(10)
+	for i := 0; i < b.nDim; i++ {
+		width := b.Upper[i] - b.Lower[i]
+		lower[i+1] = math.Log(1e-2 * width)
+		upper[i+1] = math.Log(width)
+	}
+	lower[n-1] = minLogNoise
+	upper[n-1] = math.Log(0.1)
+	b.gp.HyperLower = lower
+	b.gp.HyperUpper = upper
+}
+
+// add adds an observation to the data
+func (b *BayesOpt) add(x []float64, obj float64) {
+	b.x = append(b.x, append([]float64(nil), x...))
+	b.y = append(b.y, obj)
+	if b.bestInd < 0 || obj < b.y[b.bestInd] {
+		b.bestInd = len(b.y) - 1
+	}
+}
+
+// maximizeAcquisition returns the location within the bounds with the largest
+// value of the acquisition function
+func (b *BayesOpt) maximizeAcquisition() []float64 {
+	acquisition := b.Acquisition
+	if acquisition == nil {
+		acquisition = defaultAcquisition
+	}
+	f := &acquisitionObjective{
+		gp:          b.gp,
+		acquisition: acquisition,
+		best:        (b.y[b.bestInd] - b.gp.yMean) / b.gp.yStd,
+		lower:       b.Lower,
+		upper:       b.Upper,
+		clamped:     make([]float64, b.nDim),
+	}
+
+	// The acquisition function is flat far from its maxima, so the local
+	// optimizations start from the best of many random candidates
+	candidates := &candidateSampler{
+		f:          f,
+		candidates: make([][]float64, b.NumCandidates),
+		rand:       b.Rand,
+	}
+	for i := range candidates.candidates {
+		candidates.candidates[i] = make([]float64, b.nDim)
+	}
+	settings := multivariate.DefaultMultistartSettings()
+	settings.NumStarts = b.NumStarts
+	settings.Sampler = candidates
+	settings.MaximumFunctionEvaluations = 100 * b.nDim
+
+	next := make([]float64, b.nDim)
+	result, err := multivariate.Multistart(multivariate.NewFiniteDifference(f), b.Lower, b.Upper, settings, nil)
+	if err != nil || candidates.bestObj <= result.Minima[0].Obj {
+		// The local optimizations can fail or end at a worse location when
+		// the acquisition function is poorly scaled
+		copy(next, candidates.best)
+		return next
+	}
+	copy(next, result.Minima[0].Loc)
+	multivariate.ClampToBounds(next, b.Lower, b.Upper)
+	return next
+}
+
+// Result refits the surrogate to all of the observations. If fitting the
+// hyperparameters fails, the surrogate keeps the hyperparameters of the last
+// successful fit.
+func (b *BayesOpt) Result() {
+	if len(b.x) == 0 {
+		return
+	}
+	hyper := make([]float64, b.gp.NumHyper())
+	b.gp.Hyper(hyper)
+	// The locations of the last fit are a prefix of the observations
+	x, y := b.gp.x, b.y[:len(b.gp.x)]
+	if b.gp.FitHyper(b.x, b.y) == nil {
+		return
+	}
+	b.gp.SetHyper(hyper)
+	if b.gp.Fit(b.x, b.y) != nil && len(x) > 0 {
+		b.gp.Fit(x, y)
+	}
+}
+
+// Surrogate returns the Gaussian process fit to the observations. After the
+// optimization has finished, the surrogate has been fit to all of the
+// observations unless fitting failed, in which case it is the last successful
+// fit.
+func (b *BayesOpt) Surrogate() *GP {
+	return b.gp
+}
+
+// acquisitionObjective is the negative of the acquisition function, to be
+// minimized over the bounds. Locations outside the bounds are clamped to the
+// bounds, with a penalty for the distance outside.
+type acquisitionObjective struct {
+	gp          *GP
+	acquisition Acquisition
+	best        float64 // Best observation in normalized units
+	lower       []float64
+	upper       []float64
+	clamped     []float64
+}
+
+func (a *acquisitionObjective) Obj(x []float64) float64 {
+	copy(a.clamped, x)
+	multivariate.ClampToBounds(a.clamped, a.lower, a.upper)
+	mean, std := a.gp.predictNormalized(a.clamped)
+	obj := -a.acquisition.Value(mean, std, a.best)
+	for i, v := range x {
+		diff := (v - a.clamped[i]) / (a.upper[i] - a.lower[i])
+		obj += diff * diff
+	}
+	return obj
+}
+
+// candidateSampler evaluates the acquisition function at random candidate
+// locations, and samples the best of the candidates
+type candidateSampler struct {
+	f          *acquisitionObjective
+	candidates [][]float64
+	rand       *rand.Rand
+
+	best    []float64
+	bestObj float64
+}
+
+func (c *candidateSampler) Sample(dst [][]float64, lower, upper []float64) {
+	multivariate.LatinHypercube{Rand: c.rand}.Sample(c.candidates, lower, upper)
+	order := make([]int, len(c.candidates))
+	objs := make([]float64, len(c.candidates))
+	for i, x := range c.candidates {
+		objs[i] = c.f.Obj(x)
+	}
+	floats.Argsort(objs, order)
+	c.best = c.candidates[order[0]]
+	c.bestObj = objs[0]
+	for i := range dst {
+		copy(dst[i], c.candidates[order[i%len(order)]])
+	}
+}
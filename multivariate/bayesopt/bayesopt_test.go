@@ -0,0 +1,163 @@
+package bayesopt
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/btracey/opt/multivariate"
+)
+
+// branin has three global minima with a value of 0.397887
+type branin struct{}
+
+func (branin) Obj(x []float64) float64 {
+	b := 5.1 / (4 * math.Pi * math.Pi)
+	c := 5 / math.Pi
+	t := 1 / (8 * math.Pi)
+	v := x[1] - b*x[0]*x[0] + c*x[0] - 6
+	return v*v + 10*(1-t)*math.Cos(x[0]) + 10
+}
+
+func TestKernelGrad(t *testing.T) {
+	x := []float64{0.3, -1.2}
+	y := []float64{1.1, 0.4}
+	for _, kernel := range []Kernel{
+		NewSquaredExponential([]float64{0.7, 1.5}),
+		NewMatern52([]float64{0.7, 1.5}),
+	} {
+		hyper := make([]float64, kernel.NumHyper())
+		kernel.Hyper(hyper)
+		grad := make([]float64, len(hyper))
+		k := kernel.CovGrad(grad, x, y)
+		if math.Abs(k-kernel.Cov(x, y)) > 1e-14 {
+			t.Errorf("%T: CovGrad and Cov mismatch", kernel)
+		}
+		const h = 1e-6
+		for i := range hyper {
+			hyper[i] += h
+			kernel.SetHyper(hyper)
+			plus := kernel.Cov(x, y)
+			hyper[i] -= 2 * h
+			kernel.SetHyper(hyper)
+			minus := kernel.Cov(x, y)
+			hyper[i] += h
+			kernel.SetHyper(hyper)
+			fd := (plus - minus) / (2 * h)
+			if math.Abs(fd-grad[i]) > 1e-6 {
+				t.Errorf("%T: hyperparameter %v derivative mismatch. Want %v, found %v", kernel, i, fd, grad[i])
+			}
+		}
+	}
+}
+
+func TestGP(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	x := make([][]float64, 20)
+	y := make([]float64, len(x))
+	for i := range x {
+		x[i] = []float64{6 * rnd.Float64()}
+		y[i] = 3 * math.Sin(x[i][0])
+	}
+
+	// The likelihood gradient should match finite differences
+	gp := NewGP(NewMatern52([]float64{0.8}), 1e-2)
+	err := gp.Fit(x, y)
+	if err != nil {
+		t.Fatalf("Error fitting: %v", err)
+	}
+	hyper := make([]float64, gp.NumHyper())
+	gp.Hyper(hyper)
+	grad := make([]float64, len(hyper))
+	gp.logLikelihoodGrad(grad)
+	const h = 1e-6
+	for i := range hyper {
+		hyper[i] += h
+		gp.SetHyper(hyper)
+		gp.factor()
+		plus := gp.LogLikelihood()
+		hyper[i] -= 2 * h
+		gp.SetHyper(hyper)
+		gp.factor()
+		minus := gp.LogLikelihood()
+		hyper[i] += h
+		gp.SetHyper(hyper)
+		fd := (plus - minus) / (2 * h)
+		if math.Abs(fd-grad[i]) > 1e-4*math.Max(1, math.Abs(fd)) {
+			t.Errorf("Likelihood derivative %v mismatch. Want %v, found %v", i, fd, grad[i])
+		}
+	}
+
+	gp = NewGP(NewSquaredExponential([]float64{1}), 1e-6)
+	err = gp.FitHyper(x, y)
+	if err != nil {
+		t.Fatalf("Error fitting: %v", err)
+	}
+	for _, v := range []float64{0.5, 2.5, 4} {
+		mean, std := gp.Predict([]float64{v})
+		if math.Abs(mean-3*math.Sin(v)) > 1e-2 {
+			t.Errorf("Poor prediction at %v. Want %v, found %v", v, 3*math.Sin(v), mean)
+		}
+		if std > 0.1 {
+			t.Errorf("Standard deviation too large within the data at %v: %v", v, std)
+		}
+	}
+	_, std := gp.Predict([]float64{20})
+	if std < 1 {
+		t.Errorf("Standard deviation too small far from the data: %v", std)
+	}
+}
+
+// quadratic has its minimum of zero at (1, 2)
+type quadratic struct{}
+
+func (quadratic) Obj(x []float64) float64 {
+	return (x[0]-1)*(x[0]-1) + 3*(x[1]-2)*(x[1]-2)
+}
+
+func TestBayesOpt(t *testing.T) {
+	for _, test := range []struct {
+		f           multivariate.Objective
+		lower       []float64
+		upper       []float64
+		acquisition Acquisition
+		maxEvals    int
+		tol         float64
+		min         float64
+	}{
+		{quadratic{}, []float64{-5, -5}, []float64{5, 5}, ExpectedImprovement{Xi: 0.01}, 25, 0.2, 0},
+		{quadratic{}, []float64{-5, -5}, []float64{5, 5}, ProbabilityOfImprovement{Xi: 0.01}, 25, 0.2, 0},
+		{quadratic{}, []float64{-5, -5}, []float64{5, 5}, UpperConfidenceBound{Kappa: 2}, 25, 0.2, 0},
+		{branin{}, []float64{-5, 0}, []float64{10, 15}, ExpectedImprovement{Xi: 0.01}, 40, 0.1, 0.397887},
+	} {
+		b := NewBayesOpt(test.lower, test.upper)
+		b.Acquisition = test.acquisition
+		b.Rand = rand.New(rand.NewSource(1))
+
+		settings := multivariate.DefaultSettings()
+		settings.DisplayWriters = nil
+		settings.MaximumFunctionEvaluations = test.maxEvals
+
+		result, err := multivariate.OptimizeGradFree(test.f, []float64{0, 0}, settings, b)
+		if err != nil {
+			t.Fatalf("Error optimizing: %v", err)
+		}
+		// The optimization stops once the maximum has been exceeded
+		if result.FunctionEvaluations > settings.MaximumFunctionEvaluations+1 {
+			t.Errorf("%T, %T: too many function evaluations: %v", test.f, test.acquisition, result.FunctionEvaluations)
+		}
+		for i, v := range result.Loc {
+			if v < test.lower[i] || v > test.upper[i] {
+				t.Errorf("%T, %T: location out of bounds: %v", test.f, test.acquisition, result.Loc)
+			}
+		}
+		if result.Obj > test.min+test.tol {
+			t.Errorf("%T, %T: global minimum not found. Found %v at %v", test.f, test.acquisition, result.Obj, result.Loc)
+		}
+
+		mean, _ := b.Surrogate().Predict(result.Loc)
+		if math.Abs(mean-result.Obj) > test.tol {
+			t.Errorf("%T, %T: surrogate does not fit the best location. Want %v, found %v", test.f, test.acquisition, result.Obj, mean)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package bayesopt
+
+import (
+	"errors"
+	"math"
+
+	"github.com/btracey/opt/multivariate"
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+// Default limits on the log hyperparameters during fitting
+const (
+	minLogHyper = -20
+	maxLogHyper = 20
+	minLogNoise = -23 // Noise variance of about 1e-10
+	maxLogNoise = 0
+)
+
+// jitter is added to the diagonal of the covariance matrix for numerical stability
+const jitter = 1e-10
+
+// ErrNotPositiveDefinite is returned when the covariance matrix cannot be factored
+var ErrNotPositiveDefinite = errors.New("gp: covariance matrix not positive definite")
+
+// GP is a Gaussian process regression model. The observed values are
+// normalized to have zero mean and unit variance before fitting, so Noise and
+// the variance of the kernel are relative to the variance of the data.
+type GP struct {
+	Kernel Kernel
+	Noise  float64 // Variance of the observation noise
+
+	// HyperLower and HyperUpper limit the hyperparameters in FitHyper. If nil,
+	// the kernel hyperparameters are limited to [-20, 20] and log(Noise) is
+	// limited to [-23, 0]
+	HyperLower []float64
+	HyperUpper []float64
+
+	x     [][]float64
+	y     []float64 // Normalized observations
+	yMean float64
+	yStd  float64
+
+	cov   *mat64.Dense         // Covariance matrix of the observations
+	chol  mat64.CholeskyFactor // Cholesky factorization of cov
+	alpha []float64            // Inverse of the covariance matrix times y
+
+	hyperGrad []float64
+}
+
+// NewGP returns a new Gaussian process with the given kernel and noise variance
+func NewGP(kernel Kernel, noise float64) *GP {
+	return &GP{
+		Kernel: kernel,
+		Noise:  noise,
+	}
+}
+
+// NumHyper returns the number of hyperparameters of the model, which are the
+// hyperparameters of the kernel followed by log(Noise)
+func (g *GP) NumHyper() int {
+	return g.Kernel.NumHyper() + 1
+}
+
+// Hyper puts the hyperparameters of the model in dst
+func (g *GP) Hyper(dst []float64) {
+	n := g.Kernel.NumHyper()
+	g.Kernel.Hyper(dst[:n])
+	dst[n] = math.Log(g.Noise)
+}
+
+// SetHyper sets the hyperparameters of the model. Fit must be called for the
+// change to take effect.
+func (g *GP) SetHyper(hyper []float64) {
+	n := g.Kernel.NumHyper()
+	g.Kernel.SetHyper(hyper[:n])
+	g.Noise = math.Exp(hyper[n])
+}
+
+// Fit conditions the model on the observations y at the locations x with the
+// current hyperparameters. x and y must not be modified while the model is in use.
+func (g *GP) Fit(x [][]float64, y []float64) error {
+	if len(x) != len(y) {
+		return errors.New("gp: length mismatch")
+	}
+	if len(x) == 0 {
+		return errors.New("gp: no data")
+	}
+	g.x = x
+	g.y = make([]float64, len(y))
+	g.yMean = floats.Sum(y) / float64(len(y))
+	var variance float64
+	for _, v := range y {
+		variance += (v - g.yMean) * (v - g.yMean)
+	}
+	g.yStd = math.Sqrt(variance / float64(len(y)))
+	if g.yStd == 0 {
+		g.yStd = 1
+	}
+	for i, v := range y {
+		g.y[i] = (v - g.yMean) / g.yStd
+	}
+	return g.factor()
+}
+
+// factor computes the Cholesky factor of the covariance matrix and alpha
+func (g *GP) factor() error {
+	n := len(g.x)
+	if g.cov == nil {
+		g.cov = mat64.NewDense(n, n, nil)
+	} else if r, _ := g.cov.Dims(); r != n {
+		g.cov = mat64.NewDense(n, n, nil)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			k := g.Kernel.Cov(g.x[i], g.x[j])
+			if i == j {
+				k += g.Noise + jitter
+			}
+			g.cov.Set(i, j, k)
+			g.cov.Set(j, i, k)
+		}
+	}
+	g.chol = mat64.Cholesky(g.cov)
+	if !g.chol.SPD {
+		return ErrNotPositiveDefinite
+	}
+	g.alpha = g.solve(g.alpha, g.y)
+	return nil
+}
+
+// solve puts the solution of K x = b in dst, where K is the covariance
+// matrix, and returns it. dst is reallocated if it is too short
+func (g *GP) solve(dst, b []float64) []float64 {
+	n := len(b)
+	if cap(dst) < n {
+		dst = make([]float64, n)
+	}
+	dst = dst[:n]
+	x := g.chol.Solve(mat64.NewDense(n, 1, append([]float64(nil), b...)))
+	for i := range dst {
+		dst[i] = x.At(i, 0)
+	}
+	return dst
+}
+
+// LogLikelihood returns the log of the marginal likelihood of the normalized
+// observations given the hyperparameters
+func (g *GP) LogLikelihood() float64 {
+	n := len(g.x)
+	ll := -0.5*floats.Dot(g.y, g.alpha) - 0.5*float64(n)*math.Log(2*math.Pi)
+	for i := 0; i < n; i++ {
+		ll -= math.Log(g.chol.L.At(i, i))
+	}
+	return ll
+}
+
+// logLikelihoodGrad puts the gradient of the log likelihood with respect to
+// the hyperparameters in grad. Fit must have been called with the current
+// hyperparameters.
+func (g *GP) logLikelihoodGrad(grad []float64) {
+	n := len(g.x)
+	nKernel := g.Kernel.NumHyper()
+
+	// W = alpha * alpha^T - K^-1, and dL/dtheta = 1/2 tr(W dK/dtheta)
+	eye := mat64.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		eye.Set(i, i, 1)
+	}
+	w := g.chol.Solve(eye)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			w.Set(i, j, g.alpha[i]*g.alpha[j]-w.At(i, j))
+		}
+	}
+
+	for i := range grad {
+		grad[i] = 0
+	}
+	if len(g.hyperGrad) != nKernel {
+		g.hyperGrad = make([]float64, nKernel)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			g.Kernel.CovGrad(g.hyperGrad, g.x[i], g.x[j])
+			scale := w.At(i, j)
+			if i != j {
+				// Off-diagonal elements appear twice in the trace
+				scale *= 2
+			}
+			floats.AddScaled(grad[:nKernel], 0.5*scale, g.hyperGrad)
+		}
+		// Derivative of the noise term with respect to log(Noise)
+		grad[nKernel] += 0.5 * w.At(i, i) * g.Noise
+	}
+}
+
+// FitHyper fits the hyperparameters by maximizing the marginal likelihood of
+// the observations using Lbfgs, starting from the current hyperparameters,
+// and then conditions the model on the observations.
+func (g *GP) FitHyper(x [][]float64, y []float64) error {
+	// The current hyperparameters may not give a positive definite covariance
+	// matrix for the new data, but others may
+	err := g.Fit(x, y)
+	if err != nil && err != ErrNotPositiveDefinite {
+		return err
+	}
+	init := make([]float64, g.NumHyper())
+	g.Hyper(init)
+	lower, upper := g.hyperBounds()
+	clampHyper(init, init, lower, upper)
+
+	nll := &negLogLikelihood{
+		gp:      g,
+		lower:   lower,
+		upper:   upper,
+		clamped: make([]float64, len(init)),
+		best:    math.Inf(1),
+		bestLoc: make([]float64, len(init)),
+	}
+	settings := multivariate.DefaultSettings()
+	settings.DisplayWriters = nil
+	settings.GradAbsTol = 1e-5
+	settings.MaximumFunctionEvaluations = 200
+
+	// The linesearch can fail when the likelihood is poorly conditioned, so
+	// the best hyperparameters seen are used regardless of the outcome
+	multivariate.OptimizeGrad(nll, init, settings, multivariate.NewLbfgs())
+	if math.IsInf(nll.best, 1) {
+		g.SetHyper(init)
+	} else {
+		g.SetHyper(nll.bestLoc)
+	}
+	return g.factor()
+}
+
+// Predict returns the mean and standard deviation of the model at x
+func (g *GP) Predict(x []float64) (mean, std float64) {
+	mean, std = g.predictNormalized(x)
+	return mean*g.yStd + g.yMean, std * g.yStd
+}
+
+// predictNormalized returns the predicted mean and standard deviation in the
+// normalized units of the observations
+func (g *GP) predictNormalized(x []float64) (mean, std float64) {
+	n := len(g.x)
+	k := make([]float64, n)
+	for i, xi := range g.x {
+		k[i] = g.Kernel.Cov(x, xi)
+	}
+	mean = floats.Dot(k, g.alpha)
+
+	// The variance is k(x,x) - k^T K^-1 k
+	variance := g.Kernel.Cov(x, x) - floats.Dot(k, g.solve(nil, k))
+	return mean, math.Sqrt(math.Max(variance, 0))
+}
+
+// negLogLikelihood is the objective function for fitting the hyperparameters.
+// The hyperparameters are clamped to a reasonable range, with a penalty for
+// leaving the range.
+type negLogLikelihood struct {
+	gp      *GP
+	lower   []float64
+	upper   []float64
+	clamped []float64
+	best    float64
+	bestLoc []float64
+}
+
+func (n *negLogLikelihood) ObjGrad(x []float64, grad []float64) float64 {
+	clampHyper(n.clamped, x, n.lower, n.upper)
+	n.gp.SetHyper(n.clamped)
+	if n.gp.factor() != nil {
+		// Infinite objective lets the linesearch backtrack
+		for i := range grad {
+			grad[i] = 0
+		}
+		return math.Inf(1)
+	}
+	obj := -n.gp.LogLikelihood()
+	n.gp.logLikelihoodGrad(grad)
+	floats.Scale(-1, grad)
+	for i, v := range x {
+		diff := v - n.clamped[i]
+		obj += 0.5 * diff * diff
+		if diff != 0 {
+			grad[i] = diff
+		}
+	}
+	if obj < n.best {
+		n.best = obj
+		copy(n.bestLoc, n.clamped)
+	}
+	return obj
+}
+
+// hyperBounds returns the limits on the hyperparameters in FitHyper
+func (g *GP) hyperBounds() (lower, upper []float64) {
+	n := g.NumHyper()
+	if g.HyperLower != nil && g.HyperUpper != nil {
+		if len(g.HyperLower) != n || len(g.HyperUpper) != n {
+			panic("gp: hyperparameter bounds length mismatch")
+		}
+		return g.HyperLower, g.HyperUpper
+	}
+	lower = make([]float64, n)
+	upper = make([]float64, n)
+	for i := 0; i < n-1; i++ {
+		lower[i] = minLogHyper
+		upper[i] = maxLogHyper
+	}
+	lower[n-1] = minLogNoise
+	upper[n-1] = maxLogNoise
+	return lower, upper
+}
+
+// clampHyper puts the hyperparameters limited to [lower, upper] in dst
+func clampHyper(dst, hyper, lower, upper []float64) {
+	for i, v := range hyper {
+		dst[i] = math.Min(math.Max(v, lower[i]), upper[i])
+	}
+}
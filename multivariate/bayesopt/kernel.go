@@ -0,0 +1,146 @@
+package bayesopt
+
+import "math"
+
+// Kernel is a covariance function for a Gaussian process. The hyperparameters
+// of a kernel are expressed on a log scale so that they can be fit by an
+// unconstrained optimizer.
+type Kernel interface {
+	// NumHyper returns the number of hyperparameters
+	NumHyper() int
+	// Hyper puts the hyperparameters in dst
+	Hyper(dst []float64)
+	// SetHyper sets the hyperparameters
+	SetHyper(hyper []float64)
+	// Cov returns the covariance between x and y
+	Cov(x, y []float64) float64
+	// CovGrad puts the derivative of Cov(x, y) with respect to each of the
+	// hyperparameters in dst and returns Cov(x, y)
+	CovGrad(dst []float64, x, y []float64) float64
+}
+
+// SquaredExponential is the squared exponential (Gaussian) kernel with a
+// separate length scale for every dimension
+//
+//	k(x, y) = Variance * exp(-r^2/2),  r^2 = sum_i ((x_i - y_i)/LengthScales_i)^2
+//
+// The hyperparameters are log(Variance) followed by the logs of the length scales.
+type SquaredExponential struct {
+	Variance     float64
+	LengthScales []float64
+}
+
+// NewSquaredExponential returns a squared exponential kernel with unit variance
+// and the given length scales
+func NewSquaredExponential(lengthScales []float64) *SquaredExponential {
+	return &SquaredExponential{
+		Variance:     1,
+		LengthScales: lengthScales,
+	}
+}
+
+func (s *SquaredExponential) NumHyper() int {
+	return len(s.LengthScales) + 1
+}
+
+func (s *SquaredExponential) Hyper(dst []float64) {
+	logHyper(dst, s.Variance, s.LengthScales)
+}
+
+func (s *SquaredExponential) SetHyper(hyper []float64) {
+	s.Variance = setLogHyper(hyper, s.LengthScales)
+}
+
+func (s *SquaredExponential) Cov(x, y []float64) float64 {
+	return s.Variance * math.Exp(-scaledDistSq(x, y, s.LengthScales)/2)
+}
+
+func (s *SquaredExponential) CovGrad(dst []float64, x, y []float64) float64 {
+	k := s.Cov(x, y)
+	dst[0] = k
+	for i, l := range s.LengthScales {
+		z := (x[i] - y[i]) / l
+		dst[i+1] = k * z * z
+	}
+	return k
+}
+
+// Matern52 is the Matérn kernel with smoothness 5/2 with a separate length
+// scale for every dimension
+//
+//	k(x, y) = Variance * (1 + sqrt(5) r + 5 r^2 / 3) * exp(-sqrt(5) r)
+//
+// where r is the scaled distance as in SquaredExponential. Matérn kernels
+// make weaker smoothness assumptions than the squared exponential, which is
+// usually more realistic for physical objectives.
+// The hyperparameters are log(Variance) followed by the logs of the length scales.
+type Matern52 struct {
+	Variance     float64
+	LengthScales []float64
+}
+
+// NewMatern52 returns a Matérn 5/2 kernel with unit variance and the given
+// length scales
+func NewMatern52(lengthScales []float64) *Matern52 {
+	return &Matern52{
+		Variance:     1,
+		LengthScales: lengthScales,
+	}
+}
+
+func (m *Matern52) NumHyper() int {
+	return len(m.LengthScales) + 1
+}
+
+func (m *Matern52) Hyper(dst []float64) {
+	logHyper(dst, m.Variance, m.LengthScales)
+}
+
+func (m *Matern52) SetHyper(hyper []float64) {
+	m.Variance = setLogHyper(hyper, m.LengthScales)
+}
+
+func (m *Matern52) Cov(x, y []float64) float64 {
+	r := math.Sqrt(5 * scaledDistSq(x, y, m.LengthScales))
+	return m.Variance * (1 + r + r*r/3) * math.Exp(-r)
+}
+
+func (m *Matern52) CovGrad(dst []float64, x, y []float64) float64 {
+	r := math.Sqrt(5 * scaledDistSq(x, y, m.LengthScales))
+	e := math.Exp(-r)
+	k := m.Variance * (1 + r + r*r/3) * e
+	dst[0] = k
+	// d k / d log(l_i) = Variance * 5/3 * (1 + sqrt(5) r) * exp(-sqrt(5) r) * z_i^2
+	c := m.Variance * 5 / 3 * (1 + r) * e
+	for i, l := range m.LengthScales {
+		z := (x[i] - y[i]) / l
+		dst[i+1] = c * z * z
+	}
+	return k
+}
+
+// scaledDistSq returns sum_i ((x_i - y_i)/l_i)^2
+func scaledDistSq(x, y, l []float64) float64 {
+	var sum float64
+	for i, v := range x {
+		z := (v - y[i]) / l[i]
+		sum += z * z
+	}
+	return sum
+}
+
+func logHyper(dst []float64, variance float64, lengthScales []float64) {
+	dst[0] = math.Log(variance)
+	for i, l := range lengthScales {
+		dst[i+1] = math.Log(l)
+	}
+}
+
+// setLogHyper sets the length scales from the hyperparameters and returns the
+// variance
+func setLogHyper(hyper []float64, lengthScales []float64) float64 {
+	for i := range lengthScales {
+		lengthScales[i] = math.Exp(hyper[i+1])
+	}
+	return math.Exp(hyper[0])
+}
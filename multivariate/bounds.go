@@ -0,0 +1,82 @@
+package multivariate
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+)
+
+// CheckBounds verifies that lower and upper describe a finite box with
+// dimension nDim.
+func CheckBounds(lower, upper []float64, nDim int) error {
+	if lower == nil || upper == nil {
+		return errors.New("bounds not set")
+	}
+	if len(lower) != nDim || len(upper) != nDim {
+		return errors.New("bounds length does not match the dimension")
+	}
+	for i := range lower {
+		if math.IsInf(lower[i], 0) || math.IsInf(upper[i], 0) {
+			return errors.New("bounds must be finite")
+		}
+		if !(lower[i] < upper[i]) {
+			return errors.New("lower bound not less than upper bound")
+		}
+	}
+	return nil
+}
+
+// InBounds returns true if x is within the box [lower, upper]
+func InBounds(x, lower, upper []float64) bool {
+	for i, v := range x {
+		if v < lower[i] || v > upper[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ClampToBounds moves all of the elements of x into the box [lower, upper]
+func ClampToBounds(x, lower, upper []float64) {
+	for i, v := range x {
+		x[i] = math.Min(math.Max(v, lower[i]), upper[i])
+	}
+}
+
+// boundsAt returns the bounds of the ith variable, where a nil lower or
+// upper means that side is unbounded
+func boundsAt(lower, upper []float64, i int) (l, u float64) {
+	l, u = math.Inf(-1), math.Inf(1)
+	if lower != nil {
+		l = lower[i]
+	}
+	if upper != nil {
+		u = upper[i]
+	}
+	return l, u
+}
+
+// randFloat64 returns a random number in [0,1) from source, or from the global
+// source in math/rand if source is nil
+func randFloat64(source *rand.Rand) float64 {
+	if source == nil {
+		return rand.Float64()
+	}
+	return source.Float64()
+}
+
+// randNormFloat64 returns a normally distributed random number from source,
+// or from the global source in math/rand if source is nil
+func randNormFloat64(source *rand.Rand) float64 {
+	if source == nil {
+		return rand.NormFloat64()
+	}
+	return source.NormFloat64()
+}
+
+// randInBounds sets x to be uniformly distributed within the box [lower, upper]
+func randInBounds(x, lower, upper []float64, source *rand.Rand) {
+	for i := range x {
+		x[i] = lower[i] + randFloat64(source)*(upper[i]-lower[i])
+	}
+}
@@ -0,0 +1,275 @@
+package multivariate
+
+import (
+	"math"
+
+	"github.com/gonum/floats"
+)
+
+// CompactLbfgs is the limited memory BFGS approximation of the Hessian
+// stored in the compact representation of Byrd, Nocedal and Schnabel. With
+// the steps S = [s_0 ... s_{k-1}] and changes in gradient Y = [y_0 ... y_{k-1}],
+// oldest first, and S^T Y = L + D + R, where L is strictly lower triangular,
+// D is diagonal and R is strictly upper triangular, the Hessian approximation
+// with B_0 = δ I is
+//
+//	B = δ I - [δS Y] [δS^T S  L; L^T  -D]^-1 [δS^T; Y^T]
+//
+// and its inverse with H_0 = I/δ is
+//
+//	H = H_0 + [S H_0 Y] [U^-T (D + Y^T H_0 Y) U^-1  -U^-T; -U^-1  0] [S^T; Y^T H_0]
+//
+// where U = D + R. δ is y^T y / s^T y for the most recent pair. Products with
+// B and H cost O(mn) for m stored pairs and dimension n, so the approximation
+// can be used in trust region subproblems or to estimate the covariance of a
+// maximum likelihood estimate.
+type CompactLbfgs struct {
+	memory int
+	nDim   int
+	k      int // Number of stored pairs
+
+	s [][]float64 // Stored steps, oldest first
+	y [][]float64
+
+	// Matrices of inner products of the stored pairs, with stride memory
+	sy []float64 // sy[i*memory+j] = s_i^T y_j
+	ss []float64
+	yy []float64
+
+	delta float64
+	chol  []float64 // Cholesky factor of δ S^T S + L D^-1 L^T
+
+	// Workspace of length memory
+	p1 []float64
+	p2 []float64
+	u  []float64
+	t  []float64
+}
+
+// NewCompactLbfgs returns a new CompactLbfgs for dimension nDim that stores
+// at most memory pairs
+func NewCompactLbfgs(nDim, memory int) *CompactLbfgs {
+	if memory < 1 {
+		panic("compact: memory must be positive")
+	}
+	c := &CompactLbfgs{
+		memory: memory,
+		nDim:   nDim,
+		s:      make([][]float64, memory),
+		y:      make([][]float64, memory),
+		sy:     make([]float64, memory*memory),
+		ss:     make([]float64, memory*memory),
+		yy:     make([]float64, memory*memory),
+		chol:   make([]float64, memory*memory),
+		p1:     make([]float64, memory),
+		p2:     make([]float64, memory),
+		u:      make([]float64, memory),
+		t:      make([]float64, memory),
+	}
+	for i := range c.s {
+		c.s[i] = make([]float64, nDim)
+		c.y[i] = make([]float64, nDim)
+	}
+	return c
+}
+
+// Len returns the number of stored pairs
+func (c *CompactLbfgs) Len() int {
+	return c.k
+}
+
+// Reset removes all of the stored pairs, so that the approximation is the
+// identity
+func (c *CompactLbfgs) Reset() {
+	c.k = 0
+}
+
+// Update adds the step s and change in gradient y to the approximation,
+// removing the oldest pair if the memory is full. The pair is not added and
+// false is returned if s^T y is not positive.
+func (c *CompactLbfgs) Update(s, y []float64) bool {
+	if len(s) != c.nDim || len(y) != c.nDim {
+		panic("dimension mismatch")
+	}
+	if !(floats.Dot(s, y) > 0) {
+		return false
+	}
+	if c.k == c.memory {
+		c.removeOldest()
+	}
+	m := c.memory
+	k := c.k
+	copy(c.s[k], s)
+	copy(c.y[k], y)
+	c.k++
+	for i := 0; i <= k; i++ {
+		c.ss[i*m+k] = floats.Dot(c.s[i], s)
+		c.ss[k*m+i] = c.ss[i*m+k]
+		c.yy[i*m+k] = floats.Dot(c.y[i], y)
+		c.yy[k*m+i] = c.yy[i*m+k]
+		c.sy[i*m+k] = floats.Dot(c.s[i], y)
+		c.sy[k*m+i] = floats.Dot(s, c.y[i])
+	}
+	c.delta = c.yy[k*m+k] / c.sy[k*m+k]
+
+	// Nearly dependent steps make the middle matrix of B singular, in which
+	// case the oldest pairs are dropped
+	for !c.factorize() {
+		c.removeOldest()
+	}
+	return true
+}
+
+// removeOldest removes the oldest pair
+func (c *CompactLbfgs) removeOldest() {
+	m := c.memory
+	s0, y0 := c.s[0], c.y[0]
+	copy(c.s, c.s[1:c.k])
+	copy(c.y, c.y[1:c.k])
+	c.s[c.k-1], c.y[c.k-1] = s0, y0
+	for i := 0; i < c.k-1; i++ {
+		for j := 0; j < c.k-1; j++ {
+			c.sy[i*m+j] = c.sy[(i+1)*m+j+1]
+			c.ss[i*m+j] = c.ss[(i+1)*m+j+1]
+			c.yy[i*m+j] = c.yy[(i+1)*m+j+1]
+		}
+	}
+	c.k--
+}
+
+// factorize computes the Cholesky factor of δ S^T S + L D^-1 L^T. It returns
+// false if the matrix is not numerically positive definite.
+func (c *CompactLbfgs) factorize() bool {
+	m := c.memory
+	for i := 0; i < c.k; i++ {
+		for j := 0; j <= i; j++ {
+			v := c.delta * c.ss[i*m+j]
+			for l := 0; l < j; l++ {
+				v += c.sy[i*m+l] * c.sy[j*m+l] / c.sy[l*m+l]
+			}
+			c.chol[i*m+j] = v
+		}
+	}
+	for j := 0; j < c.k; j++ {
+		d := c.chol[j*m+j]
+		for l := 0; l < j; l++ {
+			d -= c.chol[j*m+l] * c.chol[j*m+l]
+		}
+		if !(d > 1e-14*c.delta*c.ss[j*m+j]) {
+			return false
+		}
+		d = math.Sqrt(d)
+		c.chol[j*m+j] = d
+		for i := j + 1; i < c.k; i++ {
+			v := c.chol[i*m+j]
+			for l := 0; l < j; l++ {
+				v -= c.chol[i*m+l] * c.chol[j*m+l]
+			}
+			c.chol[i*m+j] = v / d
+		}
+	}
+	return true
+}
+
+// MulHess puts in dst the product of the Hessian approximation and v
+func (c *CompactLbfgs) MulHess(dst, v []float64) {
+	if len(dst) != c.nDim || len(v) != c.nDim {
+		panic("dimension mismatch")
+	}
+	if c.k == 0 {
+		copy(dst, v)
+		return
+	}
+	m := c.memory
+	k := c.k
+	q1, q2, w1, w2 := c.p1[:k], c.p2[:k], c.u[:k], c.t[:k]
+	for i := 0; i < k; i++ {
+		q1[i] = c.delta * floats.Dot(c.s[i], v)
+		q2[i] = floats.Dot(c.y[i], v)
+	}
+	// Block elimination of [δS^T S  L; L^T  -D] [w1; w2] = [q1; q2]
+	//  (δS^T S + L D^-1 L^T) w1 = q1 + L D^-1 q2
+	//  w2 = D^-1 (L^T w1 - q2)
+	for i := 0; i < k; i++ {
+		w1[i] = q1[i]
+		for j := 0; j < i; j++ {
+			w1[i] += c.sy[i*m+j] * q2[j] / c.sy[j*m+j]
+		}
+	}
+	for i := 0; i < k; i++ {
+		for j := 0; j < i; j++ {
+			w1[i] -= c.chol[i*m+j] * w1[j]
+		}
+		w1[i] /= c.chol[i*m+i]
+	}
+	for i := k - 1; i >= 0; i-- {
+		for j := i + 1; j < k; j++ {
+			w1[i] -= c.chol[j*m+i] * w1[j]
+		}
+		w1[i] /= c.chol[i*m+i]
+	}
+	for i := 0; i < k; i++ {
+		sum := -q2[i]
+		for j := i + 1; j < k; j++ {
+			sum += c.sy[j*m+i] * w1[j]
+		}
+		w2[i] = sum / c.sy[i*m+i]
+	}
+
+	copy(dst, v)
+	floats.Scale(c.delta, dst)
+	for i := 0; i < k; i++ {
+		floats.AddScaled(dst, -c.delta*w1[i], c.s[i])
+		floats.AddScaled(dst, -w2[i], c.y[i])
+	}
+}
+
+// MulInvHess puts in dst the product of the inverse Hessian approximation
+// and v
+func (c *CompactLbfgs) MulInvHess(dst, v []float64) {
+	if len(dst) != c.nDim || len(v) != c.nDim {
+		panic("dimension mismatch")
+	}
+	if c.k == 0 {
+		copy(dst, v)
+		return
+	}
+	m := c.memory
+	k := c.k
+	gamma := 1 / c.delta
+	p1, p2, u, t := c.p1[:k], c.p2[:k], c.u[:k], c.t[:k]
+	for i := 0; i < k; i++ {
+		p1[i] = floats.Dot(c.s[i], v)
+		p2[i] = gamma * floats.Dot(c.y[i], v)
+	}
+	// u = U^-1 p1
+	for i := k - 1; i >= 0; i-- {
+		u[i] = p1[i]
+		for j := i + 1; j < k; j++ {
+			u[i] -= c.sy[i*m+j] * u[j]
+		}
+		u[i] /= c.sy[i*m+i]
+	}
+	// t = (D + γ Y^T Y) u - p2
+	for i := 0; i < k; i++ {
+		sum := c.sy[i*m+i]*u[i] - p2[i]
+		for j := 0; j < k; j++ {
+			sum += gamma * c.yy[i*m+j] * u[j]
+		}
+		t[i] = sum
+	}
+	// t = U^-T t
+	for i := 0; i < k; i++ {
+		for j := 0; j < i; j++ {
+			t[i] -= c.sy[j*m+i] * t[j]
+		}
+		t[i] /= c.sy[i*m+i]
+	}
+
+	copy(dst, v)
+	floats.Scale(gamma, dst)
+	for i := 0; i < k; i++ {
+		floats.AddScaled(dst, t[i], c.s[i])
+		floats.AddScaled(dst, -gamma*u[i], c.y[i])
+	}
+}
@@ -0,0 +1,132 @@
+package multivariate
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+// denseBfgsHessian returns the BFGS approximation of the Hessian from the
+// pairs, oldest first, starting from δ I with δ = y^T y / s^T y of the most
+// recent pair
+func denseBfgsHessian(s, y [][]float64) *mat64.Dense {
+	n := len(s[0])
+	k := len(s) - 1
+	delta := floats.Dot(y[k], y[k]) / floats.Dot(s[k], y[k])
+	b := mat64.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		b.Set(i, i, delta)
+	}
+	bs := make([]float64, n)
+	for p := range s {
+		symMulVec(bs, b, s[p])
+		sbs := floats.Dot(s[p], bs)
+		sy := floats.Dot(s[p], y[p])
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				b.Set(i, j, b.At(i, j)-bs[i]*bs[j]/sbs+y[p][i]*y[p][j]/sy)
+			}
+		}
+	}
+	return b
+}
+
+// randomPairs returns k steps and the changes in gradient of a random
+// convex quadratic
+func randomPairs(n, k int, rnd *rand.Rand) (s, y [][]float64) {
+	a := mat64.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			v := rnd.NormFloat64() / float64(n)
+			a.Set(i, j, v)
+			a.Set(j, i, v)
+		}
+		a.Set(i, i, a.At(i, i)+1+float64(i))
+	}
+	for p := 0; p < k; p++ {
+		sp := make([]float64, n)
+		for i := range sp {
+			sp[i] = rnd.NormFloat64()
+		}
+		yp := make([]float64, n)
+		symMulVec(yp, a, sp)
+		s = append(s, sp)
+		y = append(y, yp)
+	}
+	return s, y
+}
+
+func TestCompactLbfgs(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const n = 8
+	s, y := randomPairs(n, 6, rnd)
+	for _, memory := range []int{1, 3, 6, 10} {
+		c := NewCompactLbfgs(n, memory)
+		for p := range s {
+			if !c.Update(s[p], y[p]) {
+				t.Fatalf("Memory %v: pair %v not added", memory, p)
+			}
+		}
+		first := len(s) - memory
+		if first < 0 {
+			first = 0
+		}
+		if c.Len() != len(s)-first {
+			t.Errorf("Memory %v: length mismatch. Want %v, found %v", memory, len(s)-first, c.Len())
+		}
+		b := denseBfgsHessian(s[first:], y[first:])
+
+		v := make([]float64, n)
+		for i := range v {
+			v[i] = rnd.NormFloat64()
+		}
+		want := make([]float64, n)
+		symMulVec(want, b, v)
+		bv := make([]float64, n)
+		c.MulHess(bv, v)
+		if !floats.EqualApprox(bv, want, 1e-10) {
+			t.Errorf("Memory %v: Hessian product mismatch. Want %v, found %v", memory, want, bv)
+		}
+		hbv := make([]float64, n)
+		c.MulInvHess(hbv, bv)
+		if !floats.EqualApprox(hbv, v, 1e-10) {
+			t.Errorf("Memory %v: inverse Hessian product is not the inverse. Want %v, found %v", memory, v, hbv)
+		}
+
+		// The secant equation holds for the most recent pair
+		c.MulInvHess(hbv, y[len(y)-1])
+		if !floats.EqualApprox(hbv, s[len(s)-1], 1e-10) {
+			t.Errorf("Memory %v: secant equation does not hold", memory)
+		}
+	}
+
+	c := NewCompactLbfgs(2, 3)
+	if c.Update([]float64{1, 0}, []float64{-1, 0}) {
+		t.Errorf("Pair with negative curvature added")
+	}
+}
+
+func TestLbfgsCompact(t *testing.T) {
+	l := NewLbfgs()
+	l.Memory = 3
+	settings := DefaultSettings()
+	settings.DisplayWriters = nil
+	settings.MaximumIterations = 5
+	_, err := OptimizeGrad(&Rosenbrock{4}, []float64{-1.2, 1, -1.2, 1}, settings, l)
+	if err != nil {
+		t.Fatalf("Error optimizing: %v", err)
+	}
+	c := NewCompactLbfgs(4, 3)
+	l.Compact(c)
+	if c.Len() != 3 {
+		t.Fatalf("Length mismatch. Want 3, found %v", c.Len())
+	}
+	newest := (l.counter + 2) % 3
+	hy := make([]float64, 4)
+	c.MulInvHess(hy, l.yHist[newest])
+	if !floats.EqualApprox(hy, l.sHist[newest], 1e-8) {
+		t.Errorf("Secant equation does not hold for the most recent step")
+	}
+}
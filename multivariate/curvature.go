@@ -0,0 +1,67 @@
+package multivariate
+
+import (
+	"github.com/gonum/floats"
+)
+
+// CurvaturePolicy sets what a quasi-Newton optimizer does with a step s and
+// change in gradient y that do not satisfy the curvature condition
+//
+//	s^T y >= CurvatureTol * s^T B s
+//
+// where B is the current Hessian approximation. The update with such a pair
+// makes the approximation badly conditioned, or indefinite if s^T y <= 0,
+// which happens when the linesearch does not enforce the Wolfe conditions
+// or the function is not convex.
+type CurvaturePolicy int
+
+const (
+	// SkipUpdate keeps the current approximation
+	SkipUpdate CurvaturePolicy = iota
+
+	// DampUpdate replaces y by θ y + (1-θ) B s, with θ chosen so that the
+	// curvature condition holds with equality. This is Powell's damping,
+	// which is usually used with a CurvatureTol of 0.2
+	DampUpdate
+
+	// ResetUpdate resets the approximation to the initial one
+	ResetUpdate
+)
+
+// heading is the display heading for the number of safeguarded updates
+func (c CurvaturePolicy) heading() string {
+	switch c {
+	case DampUpdate:
+		return "Damped"
+	case ResetUpdate:
+		return "Resets"
+	}
+	return "Skipped"
+}
+
+// stepHess puts in bs the product of the Hessian approximation and the step
+// s taken along the quasi-Newton direction p = -H g, which is B s = -α g with
+// s = α p
+func stepHess(bs, s, p, g []float64) {
+	alpha := floats.Dot(s, p) / floats.Dot(p, p)
+	for i, v := range g {
+		bs[i] = -alpha * v
+	}
+}
+
+// curvatureHolds returns whether s and y satisfy the curvature condition,
+// where bs is the product of the Hessian approximation with s
+func curvatureHolds(tol float64, s, y, bs []float64) bool {
+	sy := floats.Dot(s, y)
+	return sy > 0 && sy >= tol*floats.Dot(s, bs)
+}
+
+// dampCurvature replaces y by θ y + (1-θ) B s so that s^T y = tol s^T B s
+func dampCurvature(tol float64, s, y, bs []float64) {
+	sy := floats.Dot(s, y)
+	sbs := floats.Dot(s, bs)
+	theta := (1 - tol) * sbs / (sbs - sy)
+	for i := range y {
+		y[i] = theta*y[i] + (1-theta)*bs[i]
+	}
+}
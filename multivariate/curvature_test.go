@@ -0,0 +1,65 @@
+package multivariate
+
+import (
+	"math"
+	"testing"
+
+	"github.com/btracey/opt/common"
+
+	"github.com/gonum/floats"
+)
+
+func TestDampCurvature(t *testing.T) {
+	s := []float64{1, 2}
+	bs := []float64{2, 1}
+	y := []float64{-1, 0}
+	const tol = 0.2
+	if curvatureHolds(tol, s, y, bs) {
+		t.Errorf("Curvature condition holds for negative s^T y")
+	}
+	dampCurvature(tol, s, y, bs)
+	if sy, want := floats.Dot(s, y), tol*floats.Dot(s, bs); math.Abs(sy-want) > 1e-14 {
+		t.Errorf("Damped s^T y mismatch. Want %v, found %v", want, sy)
+	}
+}
+
+func TestCurvaturePolicy(t *testing.T) {
+	// A large CurvatureTol makes the condition fail often on the Rosenbrock
+	// function, and the optimizers must converge regardless
+	for _, policy := range []CurvaturePolicy{SkipUpdate, DampUpdate, ResetUpdate} {
+		q := NewQuasiNewton()
+		q.CurvaturePolicy = policy
+		q.CurvatureTol = 0.5
+		l := NewLbfgs()
+		l.CurvaturePolicy = policy
+		l.CurvatureTol = 0.5
+		for _, test := range []struct {
+			name         string
+			optimizer    GradOptimizer
+			nSafeguarded *int
+		}{
+			{"quasinewton", q, &q.nSafeguarded},
+			{"lbfgs", l, &l.nSafeguarded},
+		} {
+			settings := DefaultSettings()
+			settings.DisplayWriters = nil
+			settings.GradAbsTol = 1e-8
+			settings.MaximumFunctionEvaluations = 10000
+			f := &Rosenbrock{5}
+			result, err := OptimizeGrad(f, []float64{1.3, 0.7, 0.8, 1.9, 1.2}, settings, test.optimizer)
+			if err != nil {
+				t.Errorf("%v %v: error optimizing: %v", test.name, policy.heading(), err)
+				continue
+			}
+			if result.Status != common.GradAbsTol {
+				t.Errorf("%v %v: status mismatch. Want %v, found %v", test.name, policy.heading(), common.GradAbsTol, result.Status)
+			}
+			if !floats.EqualApprox(result.Loc, f.OptLoc(), 1e-6) {
+				t.Errorf("%v %v: location mismatch. Want %v, found %v", test.name, policy.heading(), f.OptLoc(), result.Loc)
+			}
+			if *test.nSafeguarded == 0 {
+				t.Errorf("%v %v: curvature condition never failed", test.name, policy.heading())
+			}
+		}
+	}
+}
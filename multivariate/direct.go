@@ -0,0 +1,327 @@
+package multivariate
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/btracey/opt/common"
+)
+
+// maxDirectLevel is the maximum number of times a side of a rectangle can be
+// trisected in Direct. Smaller rectangles are not distinguishable in floating
+// point.
+const maxDirectLevel = 30
+
+// Direct implements the DIRECT (DIviding RECTangles) algorithm of Jones et al.
+// for bounded global optimization. The bounded region is divided into
+// rectangles, each sampled at its center, and at every iteration the
+// rectangles that could contain the global minimum for some Lipschitz constant
+// are divided further. DIRECT has no parameters that need tuning; the
+// optimization should instead be limited by MaximumFunctionEvaluations.
+//
+// If LocallyBiased is true, the DIRECT-L variant of Gablonsky and Kelley is
+// used. It measures rectangles by their longest side and divides at most one
+// rectangle of each size per iteration, which works better for problems with
+// few local minima.
+//
+// Lower and Upper must be set before optimizing and must be finite. The
+// initial location is not used by the algorithm, which starts at the center of
+// the bounds.
+type Direct struct {
+	Lower []float64
+	Upper []float64
+
+	LocallyBiased bool
+
+	// Epsilon is the minimum relative improvement over the best value that a
+	// rectangle must be able to give to be divided. The standard value is 1e-4
+	Epsilon float64
+
+	// Tol is the convergence tolerance. The optimization has converged when the
+	// longest side of the rectangle containing the best location is less than
+	// Tol times the width of the bounds
+	Tol float64
+
+	// Evaluator evaluates the objective at the new centers of each iteration.
+	// If nil, the objective is evaluated serially
+	Evaluator *BatchEvaluator
+
+	fun  Objective
+	nDim int
+
+	rects []*directRect
+	best  *directRect
+
+	// Storage for the new centers of an iteration
+	locs [][]float64
+	objs []float64
+}
+
+// directRect is a rectangle in the bounds normalized to the unit hypercube
+type directRect struct {
+	center []float64
+	obj    float64
+	levels []int   // The side in dimension i has length 3^-levels[i]
+	size   float64 // Measure of the size of the rectangle
+}
+
+// NewDirect returns a Direct with the given bounds and the standard parameters
+func NewDirect(lower, upper []float64) *Direct {
+	return &Direct{
+		Lower:   lower,
+		Upper:   upper,
+		Epsilon: 1e-4,
+		Tol:     1e-8,
+	}
+}
+
+func (d *Direct) Init(f Objective, initLoc []float64, initObj float64) error {
+	d.nDim = len(initLoc)
+	err := CheckBounds(d.Lower, d.Upper, d.nDim)
+	if err != nil {
+		return errors.New("direct: " + err.Error())
+	}
+	if d.Epsilon < 0 {
+		return errors.New("direct: negative epsilon")
+	}
+	d.fun = f
+	d.rects = d.rects[:0]
+	d.best = nil
+	return nil
+}
+
+func (d *Direct) Status() common.Status {
+	if d.best == nil {
+		return common.Continue
+	}
+	if minLevel(d.best.levels) >= maxDirectLevel {
+		return common.LocChangeTol
+	}
+	if math.Pow(3, -float64(minLevel(d.best.levels))) < d.Tol {
+		return common.LocChangeTol
+	}
+	return common.Continue
+}
+
+func (d *Direct) Iterate(loc []float64) (obj float64, nFunEvals int, err error) {
+	if len(loc) != d.nDim {
+		panic("dimension mismatch")
+	}
+	if len(d.rects) == 0 {
+		// Start with a single rectangle covering the bounds
+		r := &directRect{
+			center: make([]float64, d.nDim),
+			levels: make([]int, d.nDim),
+		}
+		for i := range r.center {
+			r.center[i] = 0.5
+		}
+		d.locs = resizeSlices(d.locs, 1, d.nDim)
+		d.objs = d.objs[:0]
+		d.objs = append(d.objs, 0)
+		d.toOriginal(d.locs[0], r.center)
+		d.Evaluator.Evaluate(d.fun, d.locs, d.objs)
+		r.obj = d.objs[0]
+		d.setSize(r)
+		d.rects = append(d.rects, r)
+		d.best = r
+		d.toOriginal(loc, r.center)
+		return r.obj, 1, nil
+	}
+
+	selected := d.potentiallyOptimal()
+
+	// Find and evaluate the centers of all of the new rectangles. Each
+	// rectangle is sampled at distance delta on both sides of its center
+	// along each of its longest sides
+	var nLocs int
+	for _, r := range selected {
+		nLocs += 2 * len(longestSides(r.levels))
+	}
+	d.locs = resizeSlices(d.locs, nLocs, d.nDim)
+	if cap(d.objs) < nLocs {
+		d.objs = make([]float64, nLocs)
+	}
+	d.objs = d.objs[:nLocs]
+	newCenters := resizeSlices(nil, nLocs, d.nDim)
+	var ind int
+	for _, r := range selected {
+		delta := math.Pow(3, -float64(minLevel(r.levels)+1))
+		for _, dim := range longestSides(r.levels) {
+			for _, sign := range []float64{1, -1} {
+				copy(newCenters[ind], r.center)
+				newCenters[ind][dim] += sign * delta
+				d.toOriginal(d.locs[ind], newCenters[ind])
+				ind++
+			}
+		}
+	}
+	d.Evaluator.Evaluate(d.fun, d.locs, d.objs)
+
+	ind = 0
+	for _, r := range selected {
+		dims := longestSides(r.levels)
+		n := len(dims)
+		d.divide(r, dims, newCenters[ind:ind+2*n], d.objs[ind:ind+2*n])
+		ind += 2 * n
+	}
+
+	d.toOriginal(loc, d.best.center)
+	return d.best.obj, nLocs, nil
+}
+
+// divide divides r along the dimensions in dims. centers and objs hold the
+// new centers and their values, with the positive and negative sides of each
+// dimension adjacent. The dimensions with the best values are divided first,
+// so that the best new centers are in the largest rectangles.
+func (d *Direct) divide(r *directRect, dims []int, centers [][]float64, objs []float64) {
+	order := make([]int, len(dims))
+	w := make([]float64, len(dims))
+	for i := range dims {
+		order[i] = i
+		w[i] = math.Min(objs[2*i], objs[2*i+1])
+	}
+	sort.Sort(byValue{order, w})
+
+	for _, i := range order {
+		r.levels[dims[i]]++
+		for k := 2 * i; k <= 2*i+1; k++ {
+			child := &directRect{
+				center: centers[k],
+				obj:    objs[k],
+				levels: make([]int, d.nDim),
+			}
+			copy(child.levels, r.levels)
+			d.rects = append(d.rects, child)
+		}
+	}
+	d.setSize(r)
+	for _, child := range d.rects[len(d.rects)-2*len(dims):] {
+		d.setSize(child)
+		if child.obj < d.best.obj {
+			d.best = child
+		}
+	}
+}
+
+// potentiallyOptimal returns the rectangles that should be divided
+func (d *Direct) potentiallyOptimal() []*directRect {
+	// Find the best rectangles of each size
+	bySize := make(map[float64][]*directRect)
+	for _, r := range d.rects {
+		if minLevel(r.levels) >= maxDirectLevel {
+			continue
+		}
+		group := bySize[r.size]
+		switch {
+		case len(group) == 0 || r.obj < group[0].obj:
+			bySize[r.size] = []*directRect{r}
+		case r.obj == group[0].obj && !d.LocallyBiased:
+			bySize[r.size] = append(group, r)
+		}
+	}
+	groups := make([][]*directRect, 0, len(bySize))
+	for _, group := range bySize {
+		groups = append(groups, group)
+	}
+	sort.Sort(bySizeGroup(groups))
+
+	// A group is potentially optimal if there is a Lipschitz constant K for
+	// which it has the lowest lower bound of all of the groups, and that lower
+	// bound is sufficiently better than the current best
+	fmin := d.best.obj
+	var selected []*directRect
+	for j, group := range groups {
+		fj := group[0].obj
+		dj := group[0].size
+		lower := 0.0
+		upper := math.Inf(1)
+		for i, other := range groups {
+			fi := other[0].obj
+			di := other[0].size
+			switch {
+			case i < j:
+				lower = math.Max(lower, (fj-fi)/(dj-di))
+			case i > j:
+				upper = math.Min(upper, (fi-fj)/(di-dj))
+			}
+		}
+		if lower > upper {
+			continue
+		}
+		if !math.IsInf(upper, 1) && fj-upper*dj > fmin-d.Epsilon*math.Abs(fmin) {
+			continue
+		}
+		selected = append(selected, group...)
+	}
+	return selected
+}
+
+// setSize sets the size measure of the rectangle. DIRECT uses the distance
+// from the center to the corners, and DIRECT-L uses the longest side.
+func (d *Direct) setSize(r *directRect) {
+	if d.LocallyBiased {
+		r.size = math.Pow(3, -float64(minLevel(r.levels)))
+		return
+	}
+	// Sum in sorted order so that rectangles with the same side lengths have
+	// exactly the same size
+	levels := make([]int, len(r.levels))
+	copy(levels, r.levels)
+	sort.Ints(levels)
+	var sum float64
+	for _, l := range levels {
+		sum += math.Pow(9, -float64(l))
+	}
+	r.size = 0.5 * math.Sqrt(sum)
+}
+
+// toOriginal maps a location in the unit hypercube to the bounds
+func (d *Direct) toOriginal(dst, x []float64) {
+	for i, v := range x {
+		dst[i] = d.Lower[i] + v*(d.Upper[i]-d.Lower[i])
+	}
+}
+
+func (d *Direct) Result() {}
+
+// minLevel returns the smallest level, which is the level of the longest sides
+func minLevel(levels []int) int {
+	min := levels[0]
+	for _, l := range levels {
+		if l < min {
+			min = l
+		}
+	}
+	return min
+}
+
+// longestSides returns the dimensions with the longest side
+func longestSides(levels []int) []int {
+	min := minLevel(levels)
+	var dims []int
+	for i, l := range levels {
+		if l == min {
+			dims = append(dims, i)
+		}
+	}
+	return dims
+}
+
+// byValue sorts indices by their value
+type byValue struct {
+	inds   []int
+	values []float64
+}
+
+func (b byValue) Len() int           { return len(b.inds) }
+func (b byValue) Less(i, j int) bool { return b.values[b.inds[i]] < b.values[b.inds[j]] }
+func (b byValue) Swap(i, j int)      { b.inds[i], b.inds[j] = b.inds[j], b.inds[i] }
+
+// bySizeGroup sorts groups of rectangles by size
+type bySizeGroup [][]*directRect
+
+func (b bySizeGroup) Len() int           { return len(b) }
+func (b bySizeGroup) Less(i, j int) bool { return b[i][0].size < b[j][0].size }
+func (b bySizeGroup) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
@@ -0,0 +1,40 @@
+package multivariate
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDirect(t *testing.T) {
+	for _, locallyBiased := range []bool{false, true} {
+		d := NewDirect([]float64{-3, -2}, []float64{3, 2})
+		d.LocallyBiased = locallyBiased
+		d.Evaluator = NewBatchEvaluator(2)
+
+		settings := DefaultSettings()
+		settings.DisplayWriters = nil
+		settings.MaximumFunctionEvaluations = 2000
+
+		result, err := OptimizeGradFree(objOnly{sixHumpCamel{}}, []float64{1, 1}, settings, d)
+		if err != nil {
+			t.Fatalf("Error optimizing: %v", err)
+		}
+		if math.Abs(result.Obj+1.0316) > 1e-3 {
+			t.Errorf("LocallyBiased = %v: global minimum not found. Found %v at %v", locallyBiased, result.Obj, result.Loc)
+		}
+	}
+
+	// Rastrigin has its minimum at the center, so should be found
+	// immediately and then refined to the tolerance
+	d := NewDirect([]float64{-5.12, -5.12, -5.12}, []float64{5.12, 5.12, 5.12})
+	d.Tol = 1e-4
+	settings := DefaultSettings()
+	settings.DisplayWriters = nil
+	result, err := OptimizeGradFree(rastrigin{}, []float64{1, 1, 1}, settings, d)
+	if err != nil {
+		t.Fatalf("Error optimizing: %v", err)
+	}
+	if result.Obj != 0 {
+		t.Errorf("Global minimum of rastrigin not found. Found %v at %v", result.Obj, result.Loc)
+	}
+}
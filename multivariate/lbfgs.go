@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/btracey/opt/common"
 	"github.com/btracey/opt/multivariate/linesearch"
+	"github.com/btracey/opt/write"
 
 	"github.com/gonum/blas/dbw"
 	"github.com/gonum/floats"
@@ -13,8 +14,12 @@ type Lbfgs struct {
 	LinesearchSettings *linesearch.Settings
 	Memory             int // How many past iterations
 
-	fun  ObjGrader
-	nDim int
+	CurvaturePolicy CurvaturePolicy // What to do when the curvature condition fails
+	CurvatureTol    float64         // Threshold of the curvature condition
+
+	fun          ObjGrader
+	nDim         int
+	linesearcher *linesearch.Linesearcher
 
 	counter int // Counter is where the new values will be stored
 	looped  bool
@@ -34,12 +39,19 @@ type Lbfgs struct {
 	currLoc  []float64
 	currObj  float64
 	prevObj  float64
+
+	s            []float64 // Most recent step
+	y            []float64 // Most recent change in gradient
+	bs           []float64 // B*s
+	nSafeguarded int       // Number of updates where the curvature condition failed
 }
 
 func NewLbfgs() *Lbfgs {
 	return &Lbfgs{
 		LinesearchSettings: linesearch.DefaultSettings(),
 		Memory:             30,
+		CurvaturePolicy:    SkipUpdate,
+		CurvatureTol:       1e-8,
 	}
 }
 
@@ -51,8 +63,20 @@ func (lbfgs *Lbfgs) Init(f ObjGrader, initLoc []float64, initObj float64, initGr
 		return errors.New("lbfgs: initGrad is nil")
 	}
 
+	if lbfgs.CurvatureTol < 0 || lbfgs.CurvatureTol >= 1 || (lbfgs.CurvaturePolicy == DampUpdate && lbfgs.CurvatureTol == 0) {
+		return errors.New("lbfgs: bad curvature tolerance")
+	}
+
 	lbfgs.fun = f
 	lbfgs.nDim = len(initLoc)
+	if lbfgs.linesearcher == nil {
+		lbfgs.linesearcher = linesearch.NewLinesearcher(lbfgs.LinesearchSettings)
+	}
+	lbfgs.linesearcher.Settings = lbfgs.LinesearchSettings
+	lbfgs.s = make([]float64, lbfgs.nDim)
+	lbfgs.y = make([]float64, lbfgs.nDim)
+	lbfgs.bs = make([]float64, lbfgs.nDim)
+	lbfgs.nSafeguarded = 0
 
 	lbfgs.counter = 0
 	lbfgs.looped = false
@@ -110,7 +134,7 @@ func (lbfgs *Lbfgs) Iterate(loc, grad []float64) (obj float64, nFunEvals int, er
 		fmt.Println("lbfgs currGrad ", lbfgs.currGrad)
 		fmt.Println("lbfgs currLoc", lbfgs.currLoc)
 	*/
-	result, err := linesearch.GradLinesearch(lbfgs.LinesearchSettings, lbfgs.fun,
+	result, err := lbfgs.linesearcher.Linesearch(lbfgs.fun,
 		lbfgs.q.Data, lbfgs.currLoc, lbfgs.currObj, lbfgs.currGrad, lbfgs.prevObj)
 
 	if err != nil {
@@ -128,15 +152,52 @@ func (lbfgs *Lbfgs) Iterate(loc, grad []float64) (obj float64, nFunEvals int, er
 	newGrad := result.Grad
 
 	// y_k = g_{k+1} - g_k
-	copy(lbfgs.yHist[counter], newGrad)
-	floats.Sub(lbfgs.yHist[counter], lbfgs.currGrad)
+	copy(lbfgs.y, newGrad)
+	floats.Sub(lbfgs.y, lbfgs.currGrad)
 
 	// s_k = x_{k+1} - x_k
-	copy(lbfgs.sHist[counter], newLoc)
-	floats.Sub(lbfgs.sHist[counter], lbfgs.currLoc)
+	copy(lbfgs.s, newLoc)
+	floats.Sub(lbfgs.s, lbfgs.currLoc)
+
+	// Safeguard the pair before the search direction is overwritten
+	update := true
+	stepHess(lbfgs.bs, lbfgs.s, lbfgs.q.Data, lbfgs.currGrad)
+	if !curvatureHolds(lbfgs.CurvatureTol, lbfgs.s, lbfgs.y, lbfgs.bs) {
+		lbfgs.nSafeguarded++
+		switch lbfgs.CurvaturePolicy {
+		case DampUpdate:
+			dampCurvature(lbfgs.CurvatureTol, lbfgs.s, lbfgs.y, lbfgs.bs)
+		case ResetUpdate:
+			// Forget all of the stored pairs
+			lbfgs.counter = 0
+			lbfgs.looped = false
+			counter = 0
+			update = false
+		default:
+			update = false
+		}
+	}
 
-	invRho := floats.Dot(lbfgs.yHist[counter], lbfgs.sHist[counter])
-	lbfgs.invRhoHist[counter] = invRho
+	// newest is the index of the most recent pair and max is the number of
+	// pairs stored
+	newest := counter
+	if update {
+		copy(lbfgs.sHist[counter], lbfgs.s)
+		copy(lbfgs.yHist[counter], lbfgs.y)
+		lbfgs.invRhoHist[counter] = floats.Dot(lbfgs.yHist[counter], lbfgs.sHist[counter])
+	} else {
+		newest = counter - 1
+		if newest < 0 {
+			newest += m
+		}
+	}
+	max := m
+	if !lbfgs.looped {
+		max = newest + 1 // Can't go m iterations ago
+		if !update && counter == 0 {
+			max = 0
+		}
+	}
 
 	// Calculate the search direction for the new iteration
 	// q = gk
@@ -144,16 +205,11 @@ func (lbfgs *Lbfgs) Iterate(loc, grad []float64) (obj float64, nFunEvals int, er
 	floats.Scale(-1, lbfgs.q.Data)
 	// Update based on the previous gradient values (most recent first)
 
-	max := m
-	if !lbfgs.looped {
-		max = counter + 1 // Can't go m iterations ago
-	}
-
 	// i is how many iterations ago (zero indexed because the linesearch found k+1)
 	for i := 0; i < max; i++ {
 		//for i := m - 1; i >= 0; i-- {
-		ind := counter - i // go back i iterations
-		if ind < 0 {       // storage is wrapped
+		ind := newest - i // go back i iterations
+		if ind < 0 {      // storage is wrapped
 			ind += m
 		}
 		//fmt.Println("ind", ind, "rho ind", lbfgs.rhoHist[ind], "s dot q", floats.Dot(lbfgs.sHist[ind], lbfgs.q.Data))
@@ -166,8 +222,8 @@ func (lbfgs *Lbfgs) Iterate(loc, grad []float64) (obj float64, nFunEvals int, er
 		dbw.Axpy(-1*lbfgs.alpha[ind], yVec, lbfgs.q)
 	}
 
-	gamma_k := floats.Dot(lbfgs.yHist[counter], lbfgs.sHist[counter])
-	gamma_k /= floats.Dot(lbfgs.yHist[counter], lbfgs.yHist[counter])
+	gamma_k := floats.Dot(lbfgs.yHist[newest], lbfgs.sHist[newest])
+	gamma_k /= floats.Dot(lbfgs.yHist[newest], lbfgs.yHist[newest])
 
 	//fmt.Println("gamma k", gamma_k)
 
@@ -177,7 +233,7 @@ func (lbfgs *Lbfgs) Iterate(loc, grad []float64) (obj float64, nFunEvals int, er
 
 	//for i := 0; i < m; i++ {
 	for i := max - 1; i >= 0; i-- {
-		ind := counter - i
+		ind := newest - i
 		if ind < 0 {
 			ind += m
 		}
@@ -197,10 +253,12 @@ func (lbfgs *Lbfgs) Iterate(loc, grad []float64) (obj float64, nFunEvals int, er
 		fmt.Println("alhpha hist", lbfgs.alpha)
 		fmt.Println("y hist", lbfgs.yHist)
 	*/
-	lbfgs.counter++
-	if lbfgs.counter == lbfgs.Memory {
-		lbfgs.counter = 0
-		lbfgs.looped = true
+	if update {
+		lbfgs.counter++
+		if lbfgs.counter == lbfgs.Memory {
+			lbfgs.counter = 0
+			lbfgs.looped = true
+		}
 	}
 
 	lbfgs.prevObj = lbfgs.currObj
@@ -215,4 +273,28 @@ func (lbfgs *Lbfgs) Iterate(loc, grad []float64) (obj float64, nFunEvals int, er
 	return result.Obj, result.NFunEvals, nil
 }
 
+// Compact puts the pairs in the memory of the optimizer in c, oldest first,
+// after resetting it. If c has less memory than the optimizer only the most
+// recent pairs are kept. Note that c scales the initial Hessian
+// approximation, while the search directions of Lbfgs start from the
+// identity.
+func (lbfgs *Lbfgs) Compact(c *CompactLbfgs) {
+	c.Reset()
+	n := lbfgs.counter
+	start := 0
+	if lbfgs.looped {
+		n = lbfgs.Memory
+		start = lbfgs.counter
+	}
+	for i := 0; i < n; i++ {
+		ind := (start + i) % lbfgs.Memory
+		c.Update(lbfgs.sHist[ind], lbfgs.yHist[ind])
+	}
+}
+
+func (lbfgs *Lbfgs) AppendWriteData(v []*write.Value) []*write.Value {
+	v = append(v, &write.Value{Heading: lbfgs.CurvaturePolicy.heading(), Value: lbfgs.nSafeguarded})
+	return v
+}
+
 func (lbfgs *Lbfgs) Result() {}
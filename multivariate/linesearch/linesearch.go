@@ -227,27 +227,63 @@ type Result struct {
 
 // GradFreeLinesearch performs a gradient-free linesearch on the objective. If
 // the strong wolfe conditions are used, fun must be an ObjGrad
+//
+// GradLinesearch allocates new memory on every call. Optimizers that perform
+// many linesearches should use a Linesearcher instead.
 func GradLinesearch(settings *Settings,
 	fun ObjGrader, searchVector []float64, initLoc []float64, initObj float64, initGrad []float64, prevObj float64) (*Result, error) {
+	return NewLinesearcher(settings).Linesearch(fun, searchVector, initLoc, initObj, initGrad, prevObj)
+}
+
+// Linesearcher performs gradient-based linesearches with Settings, reusing
+// its memory between calls. The Loc and Grad of the returned Result are
+// overwritten by the next call to Linesearch. A Linesearcher is not safe for
+// concurrent use.
+type Linesearcher struct {
+	Settings *Settings
+
+	optimizer LinesearchOptimizer // Optimizer used by wrapper
+	wrapper   *univariate.GradWrapper
+	line      linesearchFun
+	wolfe     WolfeConditions
+	result    Result
+}
+
+// NewLinesearcher returns a new Linesearcher with the given settings
+func NewLinesearcher(settings *Settings) *Linesearcher {
+	return &Linesearcher{
+		Settings: settings,
+	}
+}
+
+// Linesearch performs a linesearch on fun along searchVector starting from
+// initLoc. See GradLinesearch.
+func (l *Linesearcher) Linesearch(fun ObjGrader, searchVector []float64, initLoc []float64, initObj float64, initGrad []float64, prevObj float64) (*Result, error) {
+	settings := l.Settings
 
 	if len(searchVector) != len(initLoc) {
 		return nil, errors.New("linesearch: search vector length does not match init loc")
 	}
 
-	// TODO: Fix this to remove allocations
 	// TODO: Add error checking
-	wrapper := univariate.NewGradWrapper(settings.Optimizer)
-
-	line := &linesearchFun{
-		fun:          fun,
-		searchVector: searchVector,
-		initLoc:      initLoc,
-		currLoc:      make([]float64, len(initLoc)),
-		currLocCpy:   make([]float64, len(initLoc)),
-		currGrad:     make([]float64, len(initLoc)),
+	if l.wrapper == nil || l.optimizer != settings.Optimizer {
+		l.wrapper = univariate.NewGradWrapper(settings.Optimizer)
+		l.optimizer = settings.Optimizer
+	}
+	wrapper := l.wrapper
+
+	line := &l.line
+	line.fun = fun
+	line.searchVector = searchVector
+	line.initLoc = initLoc
+	if len(line.currLoc) != len(initLoc) {
+		line.currLoc = make([]float64, len(initLoc))
+		line.currLocCpy = make([]float64, len(initLoc))
+		line.currGrad = make([]float64, len(initLoc))
 	}
 
-	wolfe := &WolfeConditions{}
+	wolfe := &l.wolfe
+	*wolfe = WolfeConditions{}
 
 	grad := floats.Dot(searchVector, initGrad)
 
@@ -359,7 +395,8 @@ func GradLinesearch(settings *Settings,
 
 	lineresult := wrapper.Result(status)
 
-	result := &Result{
+	result := &l.result
+	*result = Result{
 		Loc:       line.currLoc,
 		Obj:       line.currObj,
 		Grad:      line.currGrad,
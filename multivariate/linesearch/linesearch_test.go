@@ -0,0 +1,80 @@
+package linesearch
+
+import (
+	"testing"
+
+	"github.com/gonum/floats"
+)
+
+// quadratic is sum_i (i+1) x_i^2
+type quadratic struct{}
+
+func (quadratic) ObjGrad(x, grad []float64) float64 {
+	var obj float64
+	for i, v := range x {
+		obj += float64(i+1) * v * v
+		grad[i] = 2 * float64(i+1) * v
+	}
+	return obj
+}
+
+func TestLinesearcher(t *testing.T) {
+	l := NewLinesearcher(DefaultSettings())
+	for _, n := range []int{2, 5, 5, 3} {
+		loc := make([]float64, n)
+		for i := range loc {
+			loc[i] = 1
+		}
+		grad := make([]float64, n)
+		obj := quadratic{}.ObjGrad(loc, grad)
+		dir := make([]float64, n)
+		for i, g := range grad {
+			dir[i] = -g
+		}
+		want, err := GradLinesearch(DefaultSettings(), quadratic{}, dir, loc, obj, grad, obj+5000)
+		if err != nil {
+			t.Fatalf("Error in linesearch: %v", err)
+		}
+		got, err := l.Linesearch(quadratic{}, dir, loc, obj, grad, obj+5000)
+		if err != nil {
+			t.Fatalf("Error in reused linesearch: %v", err)
+		}
+		if got.Obj != want.Obj || got.Step != want.Step || got.NFunEvals != want.NFunEvals {
+			t.Errorf("Result mismatch with dimension %v. Want %+v, found %+v", n, want, got)
+		}
+		if !floats.Equal(got.Loc, want.Loc) || !floats.Equal(got.Grad, want.Grad) {
+			t.Errorf("Location mismatch with dimension %v", n)
+		}
+		if got.Obj >= obj {
+			t.Errorf("Linesearch did not decrease the objective")
+		}
+	}
+}
+
+func benchmarkLinesearch(b *testing.B, reuse bool) {
+	const n = 10
+	loc := make([]float64, n)
+	for i := range loc {
+		loc[i] = 1
+	}
+	grad := make([]float64, n)
+	obj := quadratic{}.ObjGrad(loc, grad)
+	dir := make([]float64, n)
+	for i, g := range grad {
+		dir[i] = -g
+	}
+	settings := DefaultSettings()
+	l := NewLinesearcher(settings)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if reuse {
+			l.Linesearch(quadratic{}, dir, loc, obj, grad, obj+5000)
+		} else {
+			GradLinesearch(settings, quadratic{}, dir, loc, obj, grad, obj+5000)
+		}
+	}
+}
+
+func BenchmarkGradLinesearch(b *testing.B) { benchmarkLinesearch(b, false) }
+func BenchmarkLinesearcher(b *testing.B)   { benchmarkLinesearch(b, true) }
@@ -0,0 +1,200 @@
+package multivariate
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+)
+
+// MultistartSettings contains the settings for a Multistart
+type MultistartSettings struct {
+	// Settings are the settings used for every local optimization
+	*Settings
+
+	NumStarts int     // Number of local optimizations
+	Sampler   Sampler // Generates the starting locations. If nil, Sobol is used when possible
+	Workers   int     // Number of local optimizations run concurrently
+
+	// ClusterTol sets when two local minima are considered the same. Two
+	// minima are the same if in every dimension the distance between them is
+	// less than ClusterTol times the width of the bounds
+	ClusterTol float64
+}
+
+// DefaultMultistartSettings returns the default settings for Multistart. The
+// display of the local optimizations is turned off.
+func DefaultMultistartSettings() *MultistartSettings {
+	s := &MultistartSettings{
+		Settings:   DefaultSettings(),
+		NumStarts:  20,
+		Workers:    1,
+		ClusterTol: 1e-4,
+	}
+	s.DisplayWriters = nil
+	return s
+}
+
+// MultistartResult is the result of a Multistart
+type MultistartResult struct {
+	// Minima are the distinct local minima found, sorted by objective value
+	// with the best first. Only local optimizations that converged, that is
+	// that ended with a positive status, are used
+	Minima []*Result
+
+	// Counts is the number of local optimizations that converged to each of
+	// the Minima
+	Counts []int
+
+	// Starts are the starting locations, and Runs are the results of the local
+	// optimizations started from them, including those that did not converge.
+	// Runs that ended in an error are nil
+	Starts [][]float64
+	Runs   []*Result
+}
+
+// Multistart runs local optimizations from many starting locations within the
+// bounds [lower, upper] and returns all of the distinct local minima found.
+// newOptimizer is called to create the optimizer for every local optimization
+// and may be nil, in which case Bfgs is used. The local optimizations are not
+// restricted to stay within the bounds.
+//
+// If settings.Workers is more than one, the local optimizations are run
+// concurrently. In that case f must be safe for concurrent use, or f must be an
+// ObjectiveCopier whose copies are also ObjGraders.
+//
+// Every local optimization uses a copy of settings.Settings with the initial
+// objective and gradient cleared, as they only apply to one location. Local
+// optimizations that end in an error or without converging are not used for
+// the minima. An error is returned if none of the local optimizations
+// converge.
+func Multistart(f ObjGrader, lower, upper []float64, settings *MultistartSettings, newOptimizer func() GradOptimizer) (*MultistartResult, error) {
+	if f == nil {
+		return nil, errors.New("multistart: objective function is nil")
+	}
+	if settings == nil {
+		settings = DefaultMultistartSettings()
+	}
+	if newOptimizer == nil {
+		newOptimizer = func() GradOptimizer { return NewBfgs() }
+	}
+	nDim := len(lower)
+	err := CheckBounds(lower, upper, nDim)
+	if err != nil {
+		return nil, errors.New("multistart: " + err.Error())
+	}
+	if settings.NumStarts < 1 {
+		return nil, errors.New("multistart: number of starts must be positive")
+	}
+
+	sampler := settings.Sampler
+	if sampler == nil {
+		if nDim <= MaxSobolDim {
+			sampler = Sobol{Skip: 1}
+		} else {
+			sampler = LatinHypercube{}
+		}
+	}
+	starts := resizeSlices(nil, settings.NumStarts, nDim)
+	sampler.Sample(starts, lower, upper)
+
+	runs := make([]*Result, len(starts))
+	workers := settings.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	inds := make(chan int, len(starts))
+	for i := range starts {
+		inds <- i
+	}
+	close(inds)
+
+	funs := make([]ObjGrader, workers)
+	copier, isCopier := f.(ObjectiveCopier)
+	for w := range funs {
+		funs[w] = f
+		if workers > 1 && isCopier {
+			fun, ok := copier.CopyObjective().(ObjGrader)
+			if !ok {
+				return nil, errors.New("multistart: copy of objective is not an ObjGrader")
+			}
+			funs[w] = fun
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for _, fun := range funs {
+		go func(fun ObjGrader) {
+			defer wg.Done()
+			optimizer := newOptimizer()
+			for i := range inds {
+				runSettings := *settings.Settings
+				runSettings.InitialObjective = math.NaN()
+				runSettings.InitialGradient = nil
+				result, err := OptimizeGrad(fun, starts[i], &runSettings, optimizer)
+				if err == nil {
+					runs[i] = result
+				}
+			}
+		}(fun)
+	}
+	wg.Wait()
+
+	minima, counts := clusterMinima(runs, lower, upper, settings.ClusterTol)
+	if len(minima) == 0 {
+		return nil, errors.New("multistart: no local optimization converged")
+	}
+	return &MultistartResult{
+		Minima: minima,
+		Counts: counts,
+		Starts: starts,
+		Runs:   runs,
+	}, nil
+}
+
+// clusterMinima groups the converged results into distinct minima, keeping the
+// best result of each group. The minima are returned in order of objective
+// value.
+func clusterMinima(runs []*Result, lower, upper []float64, tol float64) (minima []*Result, counts []int) {
+	sorted := make([]*Result, 0, len(runs))
+	for _, r := range runs {
+		if r != nil && r.Loc != nil && r.Status > 0 {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.Sort(byObj(sorted))
+
+	for _, r := range sorted {
+		found := false
+		for i, m := range minima {
+			if sameMinimum(r.Loc, m.Loc, lower, upper, tol) {
+				counts[i]++
+				found = true
+				break
+			}
+		}
+		if !found {
+			minima = append(minima, r)
+			counts = append(counts, 1)
+		}
+	}
+	return minima, counts
+}
+
+// sameMinimum returns whether the locations are within tol times the width of
+// the bounds in every dimension
+func sameMinimum(x, y, lower, upper []float64, tol float64) bool {
+	for i := range x {
+		if math.Abs(x[i]-y[i]) > tol*(upper[i]-lower[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+type byObj []*Result
+
+func (b byObj) Len() int           { return len(b) }
+func (b byObj) Less(i, j int) bool { return b[i].Obj < b[j].Obj }
+func (b byObj) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
@@ -0,0 +1,144 @@
+package multivariate
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/gonum/floats"
+)
+
+// sixHumpCamel has six local minima, two of which are global with a value
+// of -1.0316
+type sixHumpCamel struct{}
+
+func (sixHumpCamel) ObjGrad(x, grad []float64) float64 {
+	x1, x2 := x[0], x[1]
+	grad[0] = 8*x1 - 8.4*x1*x1*x1 + 2*math.Pow(x1, 5) + x2
+	grad[1] = x1 - 8*x2 + 16*x2*x2*x2
+	return (4-2.1*x1*x1+math.Pow(x1, 4)/3)*x1*x1 + x1*x2 + (-4+4*x2*x2)*x2*x2
+}
+
+func TestSamplers(t *testing.T) {
+	lower := []float64{0, 0}
+	upper := []float64{1, 1}
+
+	locs := resizeSlices(nil, 4, 2)
+	Sobol{}.Sample(locs, lower, upper)
+	want := [][]float64{{0, 0}, {0.5, 0.5}, {0.75, 0.25}, {0.25, 0.75}}
+	for i := range want {
+		if !floats.Equal(locs[i], want[i]) {
+			t.Errorf("Sobol point %v mismatch. Want %v, found %v", i, want[i], locs[i])
+		}
+	}
+
+	locs = resizeSlices(nil, 10, MaxSobolDim)
+	lower = make([]float64, MaxSobolDim)
+	upper = make([]float64, MaxSobolDim)
+	floats.AddConst(-2, lower)
+	floats.AddConst(3, upper)
+	for _, sampler := range []Sampler{UniformSampler{}, LatinHypercube{rand.New(rand.NewSource(1))}, Sobol{Skip: 1}} {
+		sampler.Sample(locs, lower, upper)
+		for _, x := range locs {
+			if !InBounds(x, lower, upper) {
+				t.Errorf("%T: location %v out of bounds", sampler, x)
+			}
+		}
+	}
+
+	// Every interval of the latin hypercube should contain one point
+	LatinHypercube{}.Sample(locs, lower, upper)
+	for j := range lower {
+		counts := make([]int, len(locs))
+		for _, x := range locs {
+			counts[int((x[j]-lower[j])/(upper[j]-lower[j])*float64(len(locs)))]++
+		}
+		for _, c := range counts {
+			if c != 1 {
+				t.Errorf("Latin hypercube intervals not evenly filled: %v", counts)
+				break
+			}
+		}
+	}
+}
+
+func TestMultistart(t *testing.T) {
+	lower := []float64{-3, -2}
+	upper := []float64{3, 2}
+	for _, workers := range []int{1, 4} {
+		settings := DefaultMultistartSettings()
+		settings.NumStarts = 30
+		settings.Workers = workers
+		settings.MaximumFunctionEvaluations = 1000
+		result, err := Multistart(sixHumpCamel{}, lower, upper, settings, nil)
+		if err != nil {
+			t.Fatalf("Error in multistart: %v", err)
+		}
+		if math.Abs(result.Minima[0].Obj+1.0316) > 1e-4 {
+			t.Errorf("Global minimum not found. Found %v", result.Minima[0].Obj)
+		}
+		if len(result.Minima) < 2 {
+			t.Errorf("Expected several distinct minima, found %v", len(result.Minima))
+		}
+		var total int
+		for i, m := range result.Minima {
+			total += result.Counts[i]
+			if i > 0 && m.Obj < result.Minima[i-1].Obj {
+				t.Errorf("Minima not sorted")
+			}
+		}
+		var nRuns int
+		for _, r := range result.Runs {
+			if r != nil && r.Status > 0 {
+				nRuns++
+			}
+		}
+		if total != nRuns {
+			t.Errorf("Counts do not add up to the number of converged runs")
+		}
+	}
+
+	// The initial objective applies to a single location, so it must not be
+	// used by every start
+	settings := DefaultMultistartSettings()
+	settings.NumStarts = 10
+	settings.InitialObjective = -100
+	settings.InitialGradient = []float64{0, 0}
+	result, err := Multistart(sixHumpCamel{}, lower, upper, settings, nil)
+	if err != nil {
+		t.Fatalf("Error in multistart: %v", err)
+	}
+	if result.Minima[0].Obj < -1.0317 {
+		t.Errorf("Initial objective used as a minimum: %v", result.Minima[0].Obj)
+	}
+	if settings.InitialObjective != -100 {
+		t.Errorf("Settings modified by multistart")
+	}
+
+	// Runs that stop on a limit are not local minima. The bounds are not
+	// centered at the origin, which is a stationary point
+	settings = DefaultMultistartSettings()
+	settings.NumStarts = 10
+	settings.MaximumIterations = 1
+	_, err = Multistart(sixHumpCamel{}, lower, []float64{2.5, 1.5}, settings, nil)
+	if err == nil {
+		t.Errorf("No error when no local optimization converged")
+	}
+
+	// Every worker needs a copy of the objective with a gradient
+	settings = DefaultMultistartSettings()
+	settings.Workers = 2
+	_, err = Multistart(objOnlyCopier{}, lower, upper, settings, nil)
+	if err == nil {
+		t.Errorf("No error when the copy of the objective has no gradient")
+	}
+}
+
+// objOnlyCopier is an ObjGrader whose copies have no gradient
+type objOnlyCopier struct {
+	sixHumpCamel
+}
+
+func (objOnlyCopier) CopyObjective() Objective {
+	return objOnly{sixHumpCamel{}}
+}
@@ -79,7 +79,7 @@ func (u *Helper) AppendWriteData(v []*write.Value) []*write.Value {
 func (u *Helper) Init(s *Settings, objectiveFunction interface{}, initObj float64, initGrad []float64) {
 	u.Common.Init(s.CommonSettings, objectiveFunction)
 
-	gradNrm := floats.Norm(initGrad, 2)
+	gradNrm := gradNorm(initGrad)
 
 	u.SingleOutput.Init(s.SingleOutputSettings, initObj, gradNrm)
 
@@ -89,9 +89,21 @@ func (u *Helper) Init(s *Settings, objectiveFunction interface{}, initObj float6
 	u.gradNrmBest = gradNrm
 }
 
+// InitBest makes the initial location the best location until an iteration
+// improves on it, so that the result is set even if no iteration is taken.
+// It is called after Init. initGrad is nil for gradient-free optimizers
+func (u *Helper) InitBest(initLoc []float64, initObj float64, initGrad []float64) {
+	u.objBest = initObj
+	u.locBest = append(u.locBest[:0], initLoc...)
+	u.gradBest = nil
+	if initGrad != nil {
+		u.gradBest = append([]float64(nil), initGrad...)
+	}
+}
+
 func (u *Helper) Iterate(loc []float64, obj float64, grad []float64, nFunEvals int) {
 	u.Common.Iterate(nFunEvals)
-	gradNrm := floats.Norm(grad, 2)
+	gradNrm := gradNorm(grad)
 	/*
 		fmt.Println("multivariate loc = ", loc)
 		fmt.Println("multivariate, obj = ", obj)
@@ -102,13 +114,28 @@ func (u *Helper) Iterate(loc []float64, obj float64, grad []float64, nFunEvals i
 	u.SingleOutput.Iterate(gradNrm, obj)
 
 	if obj <= u.objBest {
+		// Copy the values, as the optimizers are free to reuse loc and grad
 		u.objBest = obj
-		u.gradBest = grad
-		u.locBest = loc
+		u.locBest = append(u.locBest[:0], loc...)
+		if grad == nil {
+			u.gradBest = nil
+		} else {
+			u.gradBest = append(u.gradBest[:0], grad...)
+		}
 		u.gradNrmBest = gradNrm
 	}
 }
 
+// gradNorm returns the norm of the gradient. Gradient-free optimizers have
+// a nil gradient, in which case the norm is infinite so that the gradient
+// tolerances are never met.
+func gradNorm(grad []float64) float64 {
+	if grad == nil {
+		return math.Inf(1)
+	}
+	return floats.Norm(grad, 2)
+}
+
 func (u *Helper) Status() common.Status {
 	status := u.SingleOutput.Status()
 	if status != common.Continue {
@@ -0,0 +1,198 @@
+package multivariate
+
+import (
+	"errors"
+	"math"
+
+	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/multivariate/linesearch"
+
+	"github.com/gonum/floats"
+)
+
+// HessVecer is an objective function that can compute the product of its
+// Hessian at x with the vector v, and puts it in hv
+type HessVecer interface {
+	HessVec(x, v, hv []float64)
+}
+
+// NewtonCG is a truncated Newton optimizer that needs only products of the
+// Hessian with vectors. The Newton equations H p = -g are solved approximately
+// with the conjugate gradient method, which is stopped once the residual is
+// less than η ||g|| with the forcing term η = min(MaxForcing, sqrt(||g||)), so
+// that the solves are inexact far from the minimum and the convergence is
+// superlinear close to it. If the conjugate gradient iterations find a
+// direction of negative curvature they stop and the current iterate is used,
+// or the steepest descent direction if that is the first iteration. The step
+// length is found by a linesearch.
+//
+// If the objective function is a HessVecer its Hessian-vector products are
+// used, and otherwise they are approximated by the finite differences
+//
+//	H v ≈ (g(x + h v) - g(x)) / h
+//
+// which cost one evaluation of the function each.
+type NewtonCG struct {
+	LinesearchSettings *linesearch.Settings
+
+	MaxForcing     float64 // Largest relative tolerance of the conjugate gradient solves
+	MaxCGIter      int     // Maximum number of conjugate gradient iterations. If 0, twice the dimension is used
+	FiniteDiffStep float64 // Relative step of the finite difference Hessian-vector products
+
+	fun          ObjGrader
+	hessVecer    HessVecer
+	nDim         int
+	linesearcher *linesearch.Linesearcher
+
+	currLoc  []float64
+	currObj  float64
+	prevObj  float64
+	currGrad []float64
+	p        []float64 // Step direction
+
+	// Conjugate gradient workspace
+	r  []float64
+	d  []float64
+	hd []float64
+
+	// Finite difference workspace
+	fdLoc  []float64
+	fdGrad []float64
+}
+
+// NewNewtonCG returns a new NewtonCG with the default settings
+func NewNewtonCG() *NewtonCG {
+	return &NewtonCG{
+		LinesearchSettings: linesearch.DefaultSettings(),
+		MaxForcing:         0.5,
+		FiniteDiffStep:     1e-8,
+	}
+}
+
+func (nt *NewtonCG) Init(f ObjGrader, initLoc []float64, initObj float64, initGrad []float64) error {
+	if initGrad == nil {
+		return errors.New("newtoncg: initGrad is nil")
+	}
+	if nt.MaxForcing <= 0 || nt.MaxForcing >= 1 {
+		return errors.New("newtoncg: forcing term must be between zero and one")
+	}
+	nt.fun = f
+	nt.hessVecer, _ = f.(HessVecer)
+	if nt.hessVecer == nil && nt.FiniteDiffStep <= 0 {
+		return errors.New("newtoncg: finite difference step must be positive")
+	}
+	nt.nDim = len(initLoc)
+	n := nt.nDim
+	if nt.linesearcher == nil {
+		nt.linesearcher = linesearch.NewLinesearcher(nt.LinesearchSettings)
+	}
+	nt.linesearcher.Settings = nt.LinesearchSettings
+
+	nt.currLoc = append(nt.currLoc[:0], initLoc...)
+	nt.currGrad = append(nt.currGrad[:0], initGrad...)
+	nt.currObj = initObj
+	nt.prevObj = initObj + 5000 // trick taken from scipy
+	nt.p = make([]float64, n)
+	nt.r = make([]float64, n)
+	nt.d = make([]float64, n)
+	nt.hd = make([]float64, n)
+	nt.fdLoc = make([]float64, n)
+	nt.fdGrad = make([]float64, n)
+	return nil
+}
+
+func (nt *NewtonCG) Status() common.Status {
+	return common.Continue
+}
+
+func (nt *NewtonCG) Iterate(loc, grad []float64) (obj float64, nFunEvals int, err error) {
+	if len(loc) != nt.nDim {
+		panic("dimension mismatch")
+	}
+	if len(grad) != nt.nDim {
+		panic("dimension mismatch")
+	}
+	nFunEvals = nt.direction()
+
+	result, err := nt.linesearcher.Linesearch(nt.fun, nt.p, nt.currLoc, nt.currObj, nt.currGrad, nt.prevObj)
+	if err != nil {
+		return 0, nFunEvals, err
+	}
+	nFunEvals += result.NFunEvals
+
+	copy(nt.currLoc, result.Loc)
+	copy(nt.currGrad, result.Grad)
+	nt.prevObj = nt.currObj
+	nt.currObj = result.Obj
+
+	copy(loc, nt.currLoc)
+	copy(grad, nt.currGrad)
+	return result.Obj, nFunEvals, nil
+}
+
+// direction puts in p the truncated Newton direction found with the
+// conjugate gradient method and returns the number of function evaluations
+// used by the finite difference Hessian-vector products
+func (nt *NewtonCG) direction() (nFunEvals int) {
+	for i := range nt.p {
+		nt.p[i] = 0
+	}
+	copy(nt.r, nt.currGrad)
+	gNorm := floats.Norm(nt.r, 2)
+	if gNorm == 0 {
+		return 0
+	}
+	tol := math.Min(nt.MaxForcing, math.Sqrt(gNorm)) * gNorm
+	for i, v := range nt.r {
+		nt.d[i] = -v
+	}
+	maxIter := nt.MaxCGIter
+	if maxIter <= 0 {
+		maxIter = 2 * nt.nDim
+	}
+	rr := gNorm * gNorm
+	for k := 0; k < maxIter; k++ {
+		nFunEvals += nt.hessVec(nt.hd, nt.d)
+		dhd := floats.Dot(nt.d, nt.hd)
+		if dhd <= 1e-14*floats.Dot(nt.d, nt.d) {
+			// Negative or zero curvature
+			if k == 0 {
+				copy(nt.p, nt.d)
+			}
+			return nFunEvals
+		}
+		alpha := rr / dhd
+		floats.AddScaled(nt.p, alpha, nt.d)
+		floats.AddScaled(nt.r, alpha, nt.hd)
+		rrNew := floats.Dot(nt.r, nt.r)
+		if math.Sqrt(rrNew) < tol {
+			return nFunEvals
+		}
+		beta := rrNew / rr
+		rr = rrNew
+		for i, v := range nt.r {
+			nt.d[i] = -v + beta*nt.d[i]
+		}
+	}
+	return nFunEvals
+}
+
+// hessVec puts in hv the product of the Hessian at the current location with
+// v and returns the number of function evaluations used
+func (nt *NewtonCG) hessVec(hv, v []float64) (nFunEvals int) {
+	if nt.hessVecer != nil {
+		nt.hessVecer.HessVec(nt.currLoc, v, hv)
+		return 0
+	}
+	h := nt.FiniteDiffStep * (1 + floats.Norm(nt.currLoc, 2)) / floats.Norm(v, 2)
+	for i, x := range nt.currLoc {
+		nt.fdLoc[i] = x + h*v[i]
+	}
+	nt.fun.ObjGrad(nt.fdLoc, nt.fdGrad)
+	for i, g := range nt.fdGrad {
+		hv[i] = (g - nt.currGrad[i]) / h
+	}
+	return 1
+}
+
+func (nt *NewtonCG) Result() {}
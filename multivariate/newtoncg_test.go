@@ -0,0 +1,64 @@
+package multivariate
+
+import (
+	"math"
+	"testing"
+
+	"github.com/btracey/opt/common"
+
+	"github.com/gonum/floats"
+)
+
+// rosenbrockHessVec is the Rosenbrock function with Hessian-vector products
+type rosenbrockHessVec struct {
+	Rosenbrock
+	nHessVec int // Number of calls to HessVec
+}
+
+func (r *rosenbrockHessVec) HessVec(x, v, hv []float64) {
+	r.nHessVec++
+	for i := range hv {
+		hv[i] = 0
+	}
+	for i := 0; i < len(x)-1; i++ {
+		// Hessian of (1-x_i)^2 + 100 (x_{i+1} - x_i^2)^2
+		hii := 2 - 400*(x[i+1]-x[i]*x[i]) + 800*x[i]*x[i]
+		hij := -400 * x[i]
+		hv[i] += hii*v[i] + hij*v[i+1]
+		hv[i+1] += hij*v[i] + 200*v[i+1]
+	}
+}
+
+func TestNewtonCG(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		f       ObjGrader
+		initLoc []float64
+		optLoc  []float64
+	}{
+		{"rosen", &Rosenbrock{5}, []float64{1.3, 0.7, 0.8, 1.9, 1.2}, []float64{1, 1, 1, 1, 1}},
+		{"rosen hessvec", &rosenbrockHessVec{Rosenbrock: Rosenbrock{5}}, []float64{1.3, 0.7, 0.8, 1.9, 1.2}, []float64{1, 1, 1, 1, 1}},
+		{"rosen far", &rosenbrockHessVec{Rosenbrock: Rosenbrock{10}}, []float64{-1.2, 1, -1.2, 1, -1.2, 1, -1.2, 1, -1.2, 1}, []float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}},
+		// Starting near the saddle point, where the Hessian is indefinite
+		{"doubleWell", doubleWell{}, []float64{1e-3, 1}, []float64{1 / math.Sqrt2, 0}},
+	} {
+		settings := DefaultSettings()
+		settings.DisplayWriters = nil
+		settings.GradAbsTol = 1e-10
+		settings.MaximumFunctionEvaluations = 10000
+		result, err := OptimizeGrad(test.f, test.initLoc, settings, NewNewtonCG())
+		if err != nil {
+			t.Errorf("%v: error optimizing: %v", test.name, err)
+			continue
+		}
+		if result.Status != common.GradAbsTol {
+			t.Errorf("%v: status mismatch. Want %v, found %v", test.name, common.GradAbsTol, result.Status)
+		}
+		if !floats.EqualApprox(result.Loc, test.optLoc, 1e-6) {
+			t.Errorf("%v: location mismatch. Want %v, found %v", test.name, test.optLoc, result.Loc)
+		}
+		if hv, ok := test.f.(*rosenbrockHessVec); ok && hv.nHessVec == 0 {
+			t.Errorf("%v: Hessian-vector products not used", test.name)
+		}
+	}
+}
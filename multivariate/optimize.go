@@ -5,8 +5,19 @@ import (
 	"math"
 
 	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/write"
 )
 
+// GradFreeOptimizer represents a gradient-free optimizer
+type GradFreeOptimizer interface {
+	Init(f Objective, initLoc []float64, initObj float64) error
+	Status() common.Status
+	// loc put in place
+	Iterate(loc []float64) (obj float64, nFunEvals int, err error)
+	Result()
+}
+
+// GradOptimizer represents a gradient-based optimizer
 type GradOptimizer interface {
 	Init(f ObjGrader, initLoc []float64, initObj float64, initGrad []float64) error
 	Status() common.Status
@@ -20,11 +31,17 @@ type GradWrapper struct {
 	helper    *Helper
 }
 
+// NewGradWrapper creates a new wrapper around the optimizer. If the optimizer
+// is a write.DataAdder, its data is added to the display
 func NewGradWrapper(optimizer GradOptimizer) *GradWrapper {
-	return &GradWrapper{
+	g := &GradWrapper{
 		optimizer: optimizer,
 		helper:    NewHelper(),
 	}
+	if dataAdder, ok := optimizer.(write.DataAdder); ok {
+		g.helper.AddDataAdder(dataAdder)
+	}
+	return g
 }
 
 func (g *GradWrapper) Init(settings *Settings, fun ObjGrader, initLoc []float64) error {
@@ -38,6 +55,7 @@ func (g *GradWrapper) Init(settings *Settings, fun ObjGrader, initLoc []float64)
 	}
 
 	g.helper.Init(settings, fun, initObj, initGrad)
+	g.helper.InitBest(initLoc, initObj, initGrad)
 	return g.optimizer.Init(fun, initLoc, initObj, initGrad)
 }
 
@@ -107,3 +125,97 @@ func OptimizeGrad(f ObjGrader, initLoc []float64, settings *Settings, optimizer
 	}
 	return wrapper.Result(status), nil
 }
+
+// GradFreeWrapper is a convenience wrapper around a gradient-free algorithm that
+// allows more fine-grained control over optimization progress. See OptimizeGradFree
+// for example usage
+type GradFreeWrapper struct {
+	optimizer GradFreeOptimizer
+	helper    *Helper
+}
+
+// NewGradFreeWrapper creates a new wrapper around the optimizer. If the optimizer
+// is a write.DataAdder, its data is added to the display
+func NewGradFreeWrapper(optimizer GradFreeOptimizer) *GradFreeWrapper {
+	g := &GradFreeWrapper{
+		optimizer: optimizer,
+		helper:    NewHelper(),
+	}
+	if dataAdder, ok := optimizer.(write.DataAdder); ok {
+		g.helper.AddDataAdder(dataAdder)
+	}
+	return g
+}
+
+func (g *GradFreeWrapper) Init(settings *Settings, fun Objective, initLoc []float64) error {
+
+	initObj := settings.InitialObjective
+	if math.IsNaN(initObj) {
+		initObj = fun.Obj(initLoc)
+	}
+
+	g.helper.Init(settings, fun, initObj, nil)
+	g.helper.InitBest(initLoc, initObj, nil)
+	return g.optimizer.Init(fun, initLoc, initObj)
+}
+
+func (g *GradFreeWrapper) Status() common.Status {
+	return common.CheckStatus(g.helper, g.optimizer)
+}
+
+func (g *GradFreeWrapper) Iterate(loc []float64) (obj float64, err error) {
+	var nFunEvals int
+	obj, nFunEvals, err = g.optimizer.Iterate(loc)
+	if err != nil {
+		return obj, errors.New("error iterating optimizer: " + err.Error())
+	}
+	// There is no gradient, so the helper is given a nil gradient
+	g.helper.Iterate(loc, obj, nil, nFunEvals)
+	return obj, nil
+}
+
+func (g *GradFreeWrapper) Result(status common.Status) *Result {
+	g.optimizer.Result()
+	return g.helper.Result(status)
+}
+
+// OptimizeGradFree optimizes a function that doesn't have (or use) the gradient
+func OptimizeGradFree(f Objective, initLoc []float64, settings *Settings, optimizer GradFreeOptimizer) (*Result, error) {
+	if optimizer == nil {
+		return nil, errors.New("no optimizer provided")
+	}
+
+	if settings == nil {
+		settings = DefaultSettings()
+	}
+
+	if initLoc == nil {
+		return nil, errors.New("nil init loc")
+	}
+	if f == nil {
+		return nil, errors.New("objective function is nil")
+	}
+
+	wrapper := NewGradFreeWrapper(optimizer)
+
+	err := wrapper.Init(settings, f, initLoc)
+	if err != nil {
+		return nil, errors.New("error initializing: " + err.Error())
+	}
+	loc := make([]float64, len(initLoc))
+
+	var status common.Status
+	for {
+		// Check if it has converged
+		status = wrapper.Status()
+		if status != common.Continue {
+			break
+		}
+
+		_, err := wrapper.Iterate(loc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return wrapper.Result(status), nil
+}
@@ -0,0 +1,307 @@
+package multivariate
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/btracey/opt/common"
+)
+
+// Topology specifies which particles share information in a ParticleSwarm
+type Topology int
+
+const (
+	// GlobalTopology has every particle attracted to the best location found
+	// by the whole swarm
+	GlobalTopology Topology = iota
+
+	// RingTopology has every particle attracted to the best location found by
+	// its neighbors on a ring. This converges more slowly than GlobalTopology,
+	// but is less prone to premature convergence on multimodal problems
+	RingTopology
+)
+
+// InertiaSchedule sets the inertia weight of the particles at each iteration
+// of a ParticleSwarm. iter is the number of swarm updates so far.
+type InertiaSchedule interface {
+	Inertia(iter int) float64
+}
+
+// ConstantInertia is an inertia weight that doesn't change between iterations
+type ConstantInertia float64
+
+func (c ConstantInertia) Inertia(iter int) float64 {
+	return float64(c)
+}
+
+// LinearInertia decreases the inertia weight linearly from Start to End over
+// the first Iterations iterations, and is End thereafter
+type LinearInertia struct {
+	Start      float64
+	End        float64
+	Iterations int
+}
+
+func (l LinearInertia) Inertia(iter int) float64 {
+	if iter >= l.Iterations {
+		return l.End
+	}
+	return l.Start + (l.End-l.Start)*float64(iter)/float64(l.Iterations)
+}
+
+// ParticleSwarm is a particle swarm optimizer for bounded problems. Every
+// iteration moves all of the particles and evaluates the objective at their
+// new locations. The location returned at each iteration is the best location
+// found by the swarm so far.
+//
+// Lower and Upper must be set before optimizing and must be finite.
+type ParticleSwarm struct {
+	Lower []float64
+	Upper []float64
+
+	NumParticles int      // Number of particles. If zero, set based on the dimension of the problem
+	Topology     Topology // How the particles share information
+	Neighbors    int      // Number of neighbors on each side of a particle for RingTopology
+
+	Inertia   InertiaSchedule // Inertia weight of the velocity
+	Cognitive float64         // Weight of the attraction to the personal best location
+	Social    float64         // Weight of the attraction to the neighborhood best location
+
+	// MaxVelocity clamps the velocity in every dimension to MaxVelocity times
+	// the width of the bounds in that dimension. If MaxVelocity is not positive
+	// the velocity is not clamped
+	MaxVelocity float64
+
+	// Tol is the convergence tolerance for the swarm. The optimization has
+	// converged when all of the particles are within Tol times the bound
+	// widths of the best location
+	Tol float64
+
+	// Evaluator evaluates the objective at the particle locations. If nil,
+	// the objective is evaluated serially
+	Evaluator *BatchEvaluator
+
+	Rand *rand.Rand // Source of randomness. If nil, the global source in math/rand is used
+
+	fun  Objective
+	nDim int
+	iter int
+
+	loc      [][]float64
+	vel      [][]float64
+	obj      []float64
+	bestLoc  [][]float64 // Personal best locations
+	bestObj  []float64
+	neighbor []int // Index of the best personal best in the neighborhood
+
+	globalBest int // Index of the best personal best of the swarm
+
+	initEvaluated bool
+}
+
+// NewParticleSwarm returns a ParticleSwarm with the bounds given and default
+// parameter values for the rest. The default uses the constriction coefficients
+// of Clerc and Kennedy with a global topology
+func NewParticleSwarm(lower, upper []float64) *ParticleSwarm {
+	return &ParticleSwarm{
+		Lower:       lower,
+		Upper:       upper,
+		Topology:    GlobalTopology,
+		Neighbors:   1,
+		Inertia:     ConstantInertia(0.7298),
+		Cognitive:   1.49618,
+		Social:      1.49618,
+		MaxVelocity: 0.5,
+		Tol:         1e-8,
+	}
+}
+
+func (p *ParticleSwarm) Init(f Objective, initLoc []float64, initObj float64) error {
+	p.fun = f
+	p.nDim = len(initLoc)
+	err := CheckBounds(p.Lower, p.Upper, p.nDim)
+	if err != nil {
+		return errors.New("particle swarm: " + err.Error())
+	}
+	if p.Inertia == nil {
+		return errors.New("particle swarm: nil inertia schedule")
+	}
+	if p.Topology != GlobalTopology && p.Topology != RingTopology {
+		return errors.New("particle swarm: unknown topology")
+	}
+
+	nParticles := p.NumParticles
+	if nParticles == 0 {
+		// Default from the 2007 standard PSO
+		nParticles = 10 + int(2*math.Sqrt(float64(p.nDim)))
+	}
+	if nParticles < 2 {
+		return errors.New("particle swarm: need at least two particles")
+	}
+	if p.Topology == RingTopology && (p.Neighbors < 1 || 2*p.Neighbors+1 > nParticles) {
+		return errors.New("particle swarm: bad number of neighbors")
+	}
+
+	p.iter = 0
+	p.initEvaluated = false
+
+	p.loc = resizeSlices(p.loc, nParticles, p.nDim)
+	p.vel = resizeSlices(p.vel, nParticles, p.nDim)
+	p.bestLoc = resizeSlices(p.bestLoc, nParticles, p.nDim)
+	p.obj = make([]float64, nParticles)
+	p.bestObj = make([]float64, nParticles)
+	p.neighbor = make([]int, nParticles)
+
+	for i := range p.loc {
+		randInBounds(p.loc[i], p.Lower, p.Upper, p.Rand)
+		for j := range p.vel[i] {
+			// Initial velocity takes the particle somewhere in the box
+			other := p.Lower[j] + randFloat64(p.Rand)*(p.Upper[j]-p.Lower[j])
+			p.vel[i][j] = (other - p.loc[i][j]) / 2
+		}
+	}
+
+	// Use the initial location as one of the particles if it is feasible, as
+	// its value is already known
+	p.obj[0] = math.NaN()
+	if InBounds(initLoc, p.Lower, p.Upper) {
+		copy(p.loc[0], initLoc)
+		p.obj[0] = initObj
+	}
+	return nil
+}
+
+func (p *ParticleSwarm) Status() common.Status {
+	if !p.initEvaluated {
+		return common.Continue
+	}
+	best := p.bestLoc[p.globalBest]
+	for _, loc := range p.loc {
+		for j, v := range loc {
+			if math.Abs(v-best[j]) > p.Tol*(p.Upper[j]-p.Lower[j]) {
+				return common.Continue
+			}
+		}
+	}
+	return common.LocChangeTol
+}
+
+func (p *ParticleSwarm) Iterate(loc []float64) (obj float64, nFunEvals int, err error) {
+	if len(loc) != p.nDim {
+		panic("dimension mismatch")
+	}
+
+	if !p.initEvaluated {
+		nFunEvals = p.evaluateInitial()
+		p.initEvaluated = true
+	} else {
+		p.move()
+		p.Evaluator.Evaluate(p.fun, p.loc, p.obj)
+		nFunEvals = len(p.loc)
+		p.iter++
+	}
+	p.updateBest()
+
+	copy(loc, p.bestLoc[p.globalBest])
+	return p.bestObj[p.globalBest], nFunEvals, nil
+}
+
+// evaluateInitial evaluates the objective at the initial particle locations
+// and returns the number of function evaluations.
+func (p *ParticleSwarm) evaluateInitial() int {
+	for i := range p.bestObj {
+		p.bestObj[i] = math.Inf(1)
+	}
+	if !math.IsNaN(p.obj[0]) {
+		// Initial value is known
+		p.Evaluator.Evaluate(p.fun, p.loc[1:], p.obj[1:])
+		return len(p.loc) - 1
+	}
+	p.Evaluator.Evaluate(p.fun, p.loc, p.obj)
+	return len(p.loc)
+}
+
+// move updates the particle velocities and locations
+func (p *ParticleSwarm) move() {
+	inertia := p.Inertia.Inertia(p.iter)
+	for i, x := range p.loc {
+		v := p.vel[i]
+		personal := p.bestLoc[i]
+		social := p.bestLoc[p.neighbor[i]]
+		for j := range v {
+			width := p.Upper[j] - p.Lower[j]
+
+			v[j] = inertia*v[j] +
+				p.Cognitive*randFloat64(p.Rand)*(personal[j]-x[j]) +
+				p.Social*randFloat64(p.Rand)*(social[j]-x[j])
+			if p.MaxVelocity > 0 {
+				max := p.MaxVelocity * width
+				v[j] = math.Min(math.Max(v[j], -max), max)
+			}
+
+			// Particles stop at the bounds
+			x[j] += v[j]
+			if x[j] < p.Lower[j] {
+				x[j] = p.Lower[j]
+				v[j] = 0
+			}
+			if x[j] > p.Upper[j] {
+				x[j] = p.Upper[j]
+				v[j] = 0
+			}
+		}
+	}
+}
+
+// updateBest updates the personal, neighborhood and global best locations
+// from the most recent objective values
+func (p *ParticleSwarm) updateBest() {
+	for i, obj := range p.obj {
+		if obj < p.bestObj[i] {
+			p.bestObj[i] = obj
+			copy(p.bestLoc[i], p.loc[i])
+		}
+	}
+
+	p.globalBest = 0
+	for i, obj := range p.bestObj {
+		if obj < p.bestObj[p.globalBest] {
+			p.globalBest = i
+		}
+	}
+
+	nParticles := len(p.loc)
+	for i := range p.neighbor {
+		if p.Topology == GlobalTopology {
+			p.neighbor[i] = p.globalBest
+			continue
+		}
+		best := i
+		for k := -p.Neighbors; k <= p.Neighbors; k++ {
+			ind := (i + k + nParticles) % nParticles
+			if p.bestObj[ind] < p.bestObj[best] {
+				best = ind
+			}
+		}
+		p.neighbor[i] = best
+	}
+}
+
+func (p *ParticleSwarm) Result() {}
+
+// resizeSlices returns a set of n slices each of length nDim, reusing the
+// memory in s where possible
+func resizeSlices(s [][]float64, n, nDim int) [][]float64 {
+	if cap(s) < n {
+		s = make([][]float64, n)
+	}
+	s = s[:n]
+	for i := range s {
+		if cap(s[i]) < nDim {
+			s[i] = make([]float64, nDim)
+		}
+		s[i] = s[i][:nDim]
+	}
+	return s
+}
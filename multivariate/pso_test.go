@@ -0,0 +1,86 @@
+package multivariate
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/btracey/opt/common"
+	"github.com/gonum/floats"
+)
+
+// sphere is a bowl centered at center
+type sphere struct {
+	center []float64
+}
+
+func (s sphere) Obj(x []float64) float64 {
+	var sum float64
+	for i, v := range x {
+		sum += (v - s.center[i]) * (v - s.center[i])
+	}
+	return sum
+}
+
+// rastrigin is a multimodal function with a global minimum of zero at the origin
+type rastrigin struct{}
+
+func (rastrigin) Obj(x []float64) float64 {
+	sum := 10 * float64(len(x))
+	for _, v := range x {
+		sum += v*v - 10*math.Cos(2*math.Pi*v)
+	}
+	return sum
+}
+
+func TestParticleSwarm(t *testing.T) {
+	for _, topology := range []Topology{GlobalTopology, RingTopology} {
+		f := sphere{center: []float64{1, -2, 0.5}}
+		lower := []float64{-5, -5, -5}
+		upper := []float64{5, 5, 5}
+
+		pso := NewParticleSwarm(lower, upper)
+		pso.Topology = topology
+		pso.Rand = rand.New(rand.NewSource(1))
+
+		settings := DefaultSettings()
+		settings.DisplayWriters = nil
+		settings.MaximumFunctionEvaluations = 20000
+
+		result, err := OptimizeGradFree(f, []float64{4, 4, 4}, settings, pso)
+		if err != nil {
+			t.Fatalf("Error optimizing: %v", err)
+		}
+		if result.Status != common.LocChangeTol {
+			t.Errorf("Topology %v: expected LocChangeTol status, found %v", topology, result.Status)
+		}
+		if !floats.EqualApprox(result.Loc, f.center, 1e-6) {
+			t.Errorf("Topology %v: expected location %v, found %v", topology, f.center, result.Loc)
+		}
+	}
+
+	// The global minimum of rastrigin should be found on a small problem
+	pso := NewParticleSwarm([]float64{-5.12, -5.12}, []float64{5.12, 5.12})
+	pso.NumParticles = 40
+	pso.Topology = RingTopology
+	pso.Inertia = LinearInertia{Start: 0.9, End: 0.4, Iterations: 200}
+	pso.Cognitive = 2
+	pso.Social = 2
+	pso.Rand = rand.New(rand.NewSource(1))
+	settings := DefaultSettings()
+	settings.DisplayWriters = nil
+	settings.MaximumFunctionEvaluations = 20000
+	result, err := OptimizeGradFree(rastrigin{}, []float64{3, 3}, settings, pso)
+	if err != nil {
+		t.Fatalf("Error optimizing: %v", err)
+	}
+	if result.Obj > 1e-6 {
+		t.Errorf("Global minimum of rastrigin not found. Found %v at %v", result.Obj, result.Loc)
+	}
+
+	// Should fail without bounds
+	_, err = OptimizeGradFree(rastrigin{}, []float64{3, 3}, settings, &ParticleSwarm{})
+	if err == nil {
+		t.Errorf("Expected error with no bounds")
+	}
+}
@@ -0,0 +1,291 @@
+package multivariate
+
+import (
+	"errors"
+
+	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/multivariate/linesearch"
+	"github.com/btracey/opt/write"
+
+	"github.com/gonum/blas/dbw"
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+// QuasiNewton is a quasi-Newton optimizer that updates an approximation H of
+// the inverse Hessian with the Broyden class of updates
+//
+//	H_DFP  = H - H y y^T H / y^T H y + s s^T / y^T s
+//	H_Phi  = H_DFP + Phi (y^T H y) w w^T,  w = s / y^T s - H y / y^T H y
+//
+// where s is the step and y is the change in gradient. Phi = 1 is the BFGS
+// update and Phi = 0 is the DFP update, and values in between are convex
+// combinations of the two. If SelfScaling is true, H is multiplied by
+// y^T s / y^T H y before every update, which is the self-scaling variable
+// metric method of Oren and Luenberger.
+//
+// The initial approximation is InitialInverseHessian or the diagonal matrix
+// InitialInverseDiagonal if either is set, and these are used as given.
+// Otherwise it is the identity, which is multiplied by y^T s / y^T H y before
+// the first update if ScaleInitial is true so that its scale matches the
+// curvature of the function along the first step.
+//
+// Pairs of s and y that do not satisfy the curvature condition are handled
+// according to CurvaturePolicy, and the number of them is displayed.
+type QuasiNewton struct {
+	LinesearchSettings *linesearch.Settings
+
+	InitialInverseHessian  *mat64.Dense // Initial inverse Hessian approximation
+	InitialInverseDiagonal []float64    // Diagonal of the initial inverse Hessian approximation
+	ScaleInitial           bool         // Scale the identity initial approximation before the first update
+
+	Phi         float64 // Broyden class parameter. 1 is BFGS and 0 is DFP
+	SelfScaling bool    // Scale the inverse Hessian before every update
+
+	CurvaturePolicy CurvaturePolicy // What to do when the curvature condition fails
+	CurvatureTol    float64         // Threshold of the curvature condition
+
+	fun          ObjGrader
+	nDim         int
+	invHess      *mat64.Dense
+	linesearcher *linesearch.Linesearcher
+
+	currLoc  []float64
+	currObj  float64
+	prevObj  float64
+	currGrad []float64
+	p        []float64 // Step direction
+	s        []float64
+	y        []float64
+	hy       []float64 // H*y
+	bs       []float64 // B*s
+
+	nSafeguarded int  // Number of updates where the curvature condition failed
+	scaleNext    bool // Scale the approximation before the next update
+}
+
+// NewQuasiNewton returns a new QuasiNewton with the BFGS update
+func NewQuasiNewton() *QuasiNewton {
+	return &QuasiNewton{
+		LinesearchSettings: linesearch.DefaultSettings(),
+		Phi:                1,
+		ScaleInitial:       true,
+		CurvaturePolicy:    SkipUpdate,
+		CurvatureTol:       1e-8,
+	}
+}
+
+// NewDFP returns a new QuasiNewton with the DFP update
+func NewDFP() *QuasiNewton {
+	q := NewQuasiNewton()
+	q.Phi = 0
+	return q
+}
+
+func (q *QuasiNewton) Init(f ObjGrader, initLoc []float64, initObj float64, initGrad []float64) error {
+	if initGrad == nil {
+		return errors.New("quasinewton: initGrad is nil")
+	}
+	if q.Phi < 0 || q.Phi > 1 {
+		return errors.New("quasinewton: Phi must be between zero and one")
+	}
+	if q.CurvatureTol < 0 || q.CurvatureTol >= 1 || (q.CurvaturePolicy == DampUpdate && q.CurvatureTol == 0) {
+		return errors.New("quasinewton: bad curvature tolerance")
+	}
+	nDim := len(initLoc)
+	if q.InitialInverseHessian != nil {
+		if q.InitialInverseDiagonal != nil {
+			return errors.New("quasinewton: both initial inverse Hessian and diagonal set")
+		}
+		r, c := q.InitialInverseHessian.Dims()
+		if r != nDim || c != nDim {
+			return errors.New("quasinewton: initial inverse Hessian size mismatch")
+		}
+	}
+	if q.InitialInverseDiagonal != nil && len(q.InitialInverseDiagonal) != nDim {
+		return errors.New("quasinewton: initial inverse diagonal length mismatch")
+	}
+	q.fun = f
+	q.nDim = len(initLoc)
+	if q.linesearcher == nil {
+		q.linesearcher = linesearch.NewLinesearcher(q.LinesearchSettings)
+	}
+	q.linesearcher.Settings = q.LinesearchSettings
+
+	q.currLoc = make([]float64, q.nDim)
+	copy(q.currLoc, initLoc)
+	q.currGrad = make([]float64, q.nDim)
+	copy(q.currGrad, initGrad)
+	q.currObj = initObj
+	q.prevObj = initObj + 5000 // trick taken from scipy
+
+	q.y = make([]float64, q.nDim)
+	q.s = make([]float64, q.nDim)
+	q.hy = make([]float64, q.nDim)
+	q.bs = make([]float64, q.nDim)
+	q.nSafeguarded = 0
+
+	q.invHess = mat64.NewDense(q.nDim, q.nDim, nil)
+	q.resetInvHess()
+
+	q.p = make([]float64, q.nDim)
+	symMulVec(q.p, q.invHess, initGrad)
+	floats.Scale(-1, q.p)
+
+	return nil
+}
+
+func (q *QuasiNewton) Status() common.Status {
+	return common.Continue
+}
+
+func (q *QuasiNewton) Next(loc []float64) {
+	copy(loc, q.currLoc)
+	floats.Add(loc, q.p)
+}
+
+func (q *QuasiNewton) Iterate(loc, grad []float64) (obj float64, nFunEvals int, err error) {
+	if len(loc) != q.nDim {
+		panic("dimension mismatch")
+	}
+	if len(grad) != q.nDim {
+		panic("dimension mismatch")
+	}
+	result, err := q.linesearcher.Linesearch(q.fun,
+		q.p, q.currLoc, q.currObj, q.currGrad, q.prevObj)
+
+	// TODO: Improve this error checking
+	if err != nil {
+		return 0, 0, err
+	}
+
+	newLoc := result.Loc
+	newGrad := result.Grad
+
+	// y_k = g_{k+1} - g_k
+	copy(q.y, newGrad)
+	floats.Sub(q.y, q.currGrad)
+
+	// Compute the recent step
+	// s_k = x_{k+1} - x_k
+	copy(q.s, newLoc)
+	floats.Sub(q.s, q.currLoc)
+
+	update := true
+	stepHess(q.bs, q.s, q.p, q.currGrad)
+	if !curvatureHolds(q.CurvatureTol, q.s, q.y, q.bs) {
+		q.nSafeguarded++
+		switch q.CurvaturePolicy {
+		case DampUpdate:
+			dampCurvature(q.CurvatureTol, q.s, q.y, q.bs)
+		case ResetUpdate:
+			q.resetInvHess()
+			update = false
+		default:
+			update = false
+		}
+	}
+	if update {
+		q.update()
+	}
+
+	// Update the current location and gradient
+	copy(q.currGrad, newGrad)
+	copy(q.currLoc, newLoc)
+	q.prevObj = q.currObj
+	q.currObj = result.Obj
+
+	// Find a new search direction
+	symMulVec(q.p, q.invHess, q.currGrad)
+	floats.Scale(-1, q.p)
+
+	// Copy information to output
+	copy(loc, newLoc)
+	copy(grad, newGrad)
+	return result.Obj, result.NFunEvals, nil
+}
+
+// resetInvHess sets the inverse Hessian to the initial approximation
+func (q *QuasiNewton) resetInvHess() {
+	for i := 0; i < q.nDim; i++ {
+		for j := 0; j < q.nDim; j++ {
+			var v float64
+			switch {
+			case q.InitialInverseHessian != nil:
+				v = q.InitialInverseHessian.At(i, j)
+			case i != j:
+			case q.InitialInverseDiagonal != nil:
+				v = q.InitialInverseDiagonal[i]
+			default:
+				v = 1
+			}
+			q.invHess.Set(i, j, v)
+		}
+	}
+	q.scaleNext = q.ScaleInitial && q.InitialInverseHessian == nil && q.InitialInverseDiagonal == nil
+}
+
+// update updates the inverse Hessian with the step in s and the change in
+// gradient in y
+func (q *QuasiNewton) update() {
+	sy := floats.Dot(q.s, q.y)
+
+	symMulVec(q.hy, q.invHess, q.y)
+	yhy := floats.Dot(q.y, q.hy)
+
+	if q.SelfScaling || q.scaleNext {
+		q.scaleNext = false
+		gamma := sy / yhy
+		scaleDense(q.invHess, gamma)
+		floats.Scale(gamma, q.hy)
+		yhy *= gamma
+	}
+
+	// Expanding w w^T, the update is the symmetric rank-2 update
+	//  H += a s s^T + b (s (Hy)^T + Hy s^T) + c Hy (Hy)^T
+	a := 1/sy + q.Phi*yhy/(sy*sy)
+	b := -q.Phi / sy
+	c := (q.Phi - 1) / yhy
+	symRankTwo(q.invHess, a, b, c, q.s, q.hy)
+}
+
+// symMulVec puts m*x in dst for the symmetric matrix m without allocating
+func symMulVec(dst []float64, m *mat64.Dense, x []float64) {
+	raw := m.RawMatrix()
+	for i := range dst {
+		dst[i] = floats.Dot(raw.Data[i*raw.Stride:i*raw.Stride+raw.Cols], x)
+	}
+}
+
+// scaleDense multiplies m by alpha in place
+func scaleDense(m *mat64.Dense, alpha float64) {
+	raw := m.RawMatrix()
+	for i := 0; i < raw.Rows; i++ {
+		floats.Scale(alpha, raw.Data[i*raw.Stride:i*raw.Stride+raw.Cols])
+	}
+}
+
+// symRankTwo performs the symmetric rank-2 update
+//
+//	m += a x x^T + b (x y^T + y x^T) + c y y^T
+//
+// in place in O(n^2) operations
+func symRankTwo(m *mat64.Dense, a, b, c float64, x, y []float64) {
+	raw := m.RawMatrix()
+	for i := 0; i < raw.Rows; i++ {
+		row := dbw.NewVector(raw.Data[i*raw.Stride : i*raw.Stride+raw.Cols])
+		// Row i gets (a x_i + b y_i) x^T + (b x_i + c y_i) y^T
+		dbw.Axpy(a*x[i]+b*y[i], dbw.NewVector(x), row)
+		dbw.Axpy(b*x[i]+c*y[i], dbw.NewVector(y), row)
+	}
+}
+
+func (q *QuasiNewton) AppendWriteData(v []*write.Value) []*write.Value {
+	v = append(v, &write.Value{Heading: q.CurvaturePolicy.heading(), Value: q.nSafeguarded})
+	return v
+}
+
+func (q *QuasiNewton) Result() {
+	// TODO: Expose final hessian estimate
+	return
+}
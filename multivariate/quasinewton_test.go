@@ -0,0 +1,285 @@
+package multivariate
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/btracey/opt/common"
+
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+// scaledQuadratic is sum_i i^2 x_i^2 / 2, which is badly scaled
+type scaledQuadratic struct{}
+
+func (scaledQuadratic) ObjGrad(x, grad []float64) float64 {
+	var obj float64
+	for i, v := range x {
+		a := float64((i + 1) * (i + 1))
+		obj += 0.5 * a * v * v
+		grad[i] = a * v
+	}
+	return obj
+}
+
+func TestQuasiNewton(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		phi         float64
+		selfScaling bool
+		rosenbrock  bool // DFP is too inefficient with inexact linesearches
+	}{
+		{"bfgs", 1, false, true},
+		{"dfp", 0, false, false},
+		{"broyden", 0.5, false, true},
+		{"bfgs self-scaling", 1, true, true},
+		{"dfp self-scaling", 0, true, false},
+	} {
+		for _, f := range []struct {
+			ObjGrader
+			initLoc []float64
+			optLoc  []float64
+		}{
+			{scaledQuadratic{}, []float64{1, 1, 1, 1, 1, 1}, make([]float64, 6)},
+			{&Rosenbrock{5}, []float64{1.3, 0.7, 0.8, 1.9, 1.2}, []float64{1, 1, 1, 1, 1}},
+		} {
+			if _, ok := f.ObjGrader.(*Rosenbrock); ok && !test.rosenbrock {
+				continue
+			}
+			q := NewQuasiNewton()
+			q.Phi = test.phi
+			q.SelfScaling = test.selfScaling
+
+			settings := DefaultSettings()
+			settings.DisplayWriters = nil
+			settings.GradAbsTol = 1e-8
+			settings.MaximumFunctionEvaluations = 1000
+			result, err := OptimizeGrad(f, f.initLoc, settings, q)
+			if err != nil {
+				t.Errorf("%v: error optimizing: %v", test.name, err)
+				continue
+			}
+			if result.Status != common.GradAbsTol {
+				t.Errorf("%v: status mismatch. Want %v, found %v", test.name, common.GradAbsTol, result.Status)
+			}
+			if !floats.EqualApprox(result.Loc, f.optLoc, 1e-6) {
+				t.Errorf("%v: location mismatch. Want %v, found %v", test.name, f.optLoc, result.Loc)
+			}
+		}
+	}
+}
+
+func TestQuasiNewtonInitialInverseHessian(t *testing.T) {
+	f := scaledQuadratic{}
+	initLoc := []float64{1, 1, 1, 1}
+	n := len(initLoc)
+	diag := make([]float64, n)
+	for i := range diag {
+		diag[i] = 1 / float64((i+1)*(i+1))
+	}
+	dense := mat64.NewDense(n, n, nil)
+	for i, v := range diag {
+		dense.Set(i, i, v)
+	}
+	for _, test := range []struct {
+		name  string
+		dense *mat64.Dense
+		diag  []float64
+	}{
+		{"dense", dense, nil},
+		{"diagonal", nil, diag},
+	} {
+		// With the exact inverse Hessian the first step is the Newton step
+		q := NewQuasiNewton()
+		q.InitialInverseHessian = test.dense
+		q.InitialInverseDiagonal = test.diag
+		settings := DefaultSettings()
+		settings.DisplayWriters = nil
+		settings.GradAbsTol = 1e-10
+		result, err := OptimizeGrad(f, initLoc, settings, q)
+		if err != nil {
+			t.Errorf("%v: error optimizing: %v", test.name, err)
+			continue
+		}
+		if result.Iterations != 1 {
+			t.Errorf("%v: Newton step not taken. %v iterations", test.name, result.Iterations)
+		}
+		if !floats.EqualApprox(result.Loc, make([]float64, n), 1e-10) {
+			t.Errorf("%v: location mismatch. Want %v, found %v", test.name, make([]float64, n), result.Loc)
+		}
+	}
+
+	q := NewQuasiNewton()
+	q.InitialInverseDiagonal = diag[:2]
+	_, err := OptimizeGrad(f, initLoc, nil, q)
+	if err == nil {
+		t.Errorf("No error for a mismatched initial inverse diagonal")
+	}
+}
+
+func TestQuasiNewtonScaleInitial(t *testing.T) {
+	// The identity is badly scaled for the objective, so scaling the
+	// initial approximation should save function evaluations
+	f := badlyScaled{}
+	var evals [2]int
+	for i, scale := range []bool{false, true} {
+		q := NewQuasiNewton()
+		q.ScaleInitial = scale
+		settings := DefaultSettings()
+		settings.DisplayWriters = nil
+		settings.GradAbsTol = 1e-8
+		settings.MaximumFunctionEvaluations = 1000
+		result, err := OptimizeGrad(f, []float64{1, 1, 1, 1, 1}, settings, q)
+		if err != nil {
+			t.Fatalf("Scale %v: error optimizing: %v", scale, err)
+		}
+		if result.Status != common.GradAbsTol {
+			t.Errorf("Scale %v: status mismatch. Want %v, found %v", scale, common.GradAbsTol, result.Status)
+		}
+		evals[i] = result.FunctionEvaluations
+	}
+	if evals[1] >= evals[0] {
+		t.Errorf("Scaling did not reduce the function evaluations. Without %v, with %v", evals[0], evals[1])
+	}
+}
+
+// badlyScaled is sum_i 10^-4 (i+1) x_i^2, whose Hessian is much smaller
+// than the identity
+type badlyScaled struct{}
+
+func (badlyScaled) ObjGrad(x, grad []float64) float64 {
+	var obj float64
+	for i, v := range x {
+		a := 1e-4 * float64(i+1)
+		obj += a * v * v
+		grad[i] = 2 * a * v
+	}
+	return obj
+}
+
+// denseBroydenUpdate is the Broyden class update of the inverse Hessian h
+// formed with outer products of dense temporaries. It is the reference for
+// the in-place update.
+func denseBroydenUpdate(h *mat64.Dense, s, y []float64, phi float64) {
+	n := len(s)
+	sy := floats.Dot(s, y)
+	hyMat := mat64.NewDense(n, 1, nil)
+	hyMat.Mul(h, mat64.NewDense(n, 1, y))
+	hy := hyMat.RawMatrix().Data
+	yhy := floats.Dot(y, hy)
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = s[i]/sy - hy[i]/yhy
+	}
+	for _, term := range []struct {
+		v     []float64
+		scale float64
+	}{
+		{s, 1 / sy},
+		{hy, -1 / yhy},
+		{w, phi * yhy},
+	} {
+		tmp := mat64.NewDense(0, 0, nil)
+		tmp.Mul(mat64.NewDense(n, 1, term.v), mat64.NewDense(1, n, term.v))
+		tmp.Scale(term.scale, tmp)
+		h.Add(h, tmp)
+	}
+}
+
+// newUpdateProblem returns a QuasiNewton initialized with a random positive
+// definite inverse Hessian and a random pair with positive curvature
+func newUpdateProblem(n int, phi float64, rnd *rand.Rand) *QuasiNewton {
+	q := NewQuasiNewton()
+	q.Phi = phi
+	q.ScaleInitial = false
+	a := mat64.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			v := rnd.NormFloat64() / float64(n)
+			a.Set(i, j, v)
+			a.Set(j, i, v)
+		}
+		a.Set(i, i, a.At(i, i)+2)
+	}
+	q.InitialInverseHessian = a
+	q.Init(nil, make([]float64, n), 0, make([]float64, n))
+	for i := 0; i < n; i++ {
+		q.s[i] = rnd.NormFloat64()
+		q.y[i] = q.s[i] + 0.1*rnd.NormFloat64()
+	}
+	return q
+}
+
+func TestQuasiNewtonUpdate(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, phi := range []float64{0, 0.3, 1} {
+		q := newUpdateProblem(7, phi, rnd)
+		want := mat64.NewDense(7, 7, nil)
+		want.Add(q.invHess, want)
+		denseBroydenUpdate(want, q.s, q.y, phi)
+		q.update()
+		for i := 0; i < 7; i++ {
+			for j := 0; j < 7; j++ {
+				if math.Abs(q.invHess.At(i, j)-want.At(i, j)) > 1e-12 {
+					t.Errorf("Phi %v: element %v, %v mismatch. Want %v, found %v", phi, i, j, want.At(i, j), q.invHess.At(i, j))
+				}
+			}
+		}
+		// The secant equation H y = s holds after the update
+		hy := make([]float64, 7)
+		symMulVec(hy, q.invHess, q.y)
+		if !floats.EqualApprox(hy, q.s, 1e-12) {
+			t.Errorf("Phi %v: secant equation does not hold", phi)
+		}
+	}
+
+	// A given initial approximation is not scaled
+	q := newUpdateProblem(7, 1, rnd)
+	q.ScaleInitial = true
+	q.resetInvHess()
+	want := mat64.NewDense(7, 7, nil)
+	want.Add(q.invHess, want)
+	denseBroydenUpdate(want, q.s, q.y, 1)
+	q.update()
+	for i := 0; i < 7; i++ {
+		for j := 0; j < 7; j++ {
+			if math.Abs(q.invHess.At(i, j)-want.At(i, j)) > 1e-12 {
+				t.Errorf("ScaleInitial: element %v, %v mismatch. Want %v, found %v", i, j, want.At(i, j), q.invHess.At(i, j))
+			}
+		}
+	}
+
+	if allocs := testing.AllocsPerRun(10, newUpdateProblem(50, 1, rnd).update); allocs != 0 {
+		t.Errorf("Update allocates %v times", allocs)
+	}
+}
+
+func benchmarkQuasiNewtonUpdate(b *testing.B, n int) {
+	q := newUpdateProblem(n, 1, rand.New(rand.NewSource(1)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.update()
+	}
+}
+
+func BenchmarkQuasiNewtonUpdate10(b *testing.B)   { benchmarkQuasiNewtonUpdate(b, 10) }
+func BenchmarkQuasiNewtonUpdate100(b *testing.B)  { benchmarkQuasiNewtonUpdate(b, 100) }
+func BenchmarkQuasiNewtonUpdate1000(b *testing.B) { benchmarkQuasiNewtonUpdate(b, 1000) }
+func BenchmarkQuasiNewtonUpdate2000(b *testing.B) { benchmarkQuasiNewtonUpdate(b, 2000) }
+
+func benchmarkDenseBroydenUpdate(b *testing.B, n int) {
+	q := newUpdateProblem(n, 1, rand.New(rand.NewSource(1)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		denseBroydenUpdate(q.invHess, q.s, q.y, 1)
+	}
+}
+
+func BenchmarkDenseBroydenUpdate10(b *testing.B)   { benchmarkDenseBroydenUpdate(b, 10) }
+func BenchmarkDenseBroydenUpdate100(b *testing.B)  { benchmarkDenseBroydenUpdate(b, 100) }
+func BenchmarkDenseBroydenUpdate1000(b *testing.B) { benchmarkDenseBroydenUpdate(b, 1000) }
+func BenchmarkDenseBroydenUpdate2000(b *testing.B) { benchmarkDenseBroydenUpdate(b, 2000) }
@@ -0,0 +1,152 @@
+package multivariate
+
+import (
+	"math/rand"
+)
+
+// Sampler generates locations within a box, for example the starting
+// locations of a Multistart
+type Sampler interface {
+	// Sample sets each of the slices in dst to a location within the box
+	// [lower, upper]
+	Sample(dst [][]float64, lower, upper []float64)
+}
+
+// UniformSampler generates locations uniformly at random
+type UniformSampler struct {
+	Rand *rand.Rand // Source of randomness. If nil, the global source in math/rand is used
+}
+
+func (u UniformSampler) Sample(dst [][]float64, lower, upper []float64) {
+	for _, x := range dst {
+		randInBounds(x, lower, upper, u.Rand)
+	}
+}
+
+// LatinHypercube generates locations using Latin hypercube sampling. Each
+// dimension is split into len(dst) equal intervals, and every interval
+// contains exactly one of the locations.
+type LatinHypercube struct {
+	Rand *rand.Rand // Source of randomness. If nil, the global source in math/rand is used
+}
+
+func (l LatinHypercube) Sample(dst [][]float64, lower, upper []float64) {
+	n := len(dst)
+	var perm []int
+	for j := range lower {
+		if l.Rand == nil {
+			perm = rand.Perm(n)
+		} else {
+			perm = l.Rand.Perm(n)
+		}
+		width := (upper[j] - lower[j]) / float64(n)
+		for i, x := range dst {
+			x[j] = lower[j] + width*(float64(perm[i])+randFloat64(l.Rand))
+		}
+	}
+}
+
+// Sobol generates locations from the Sobol low-discrepancy sequence, which
+// covers the box more evenly than random sampling. The sequence is
+// deterministic, and every call to Sample starts at the beginning of the
+// sequence after skipping the first Skip points. Sobol supports up to
+// MaxSobolDim dimensions.
+type Sobol struct {
+	Skip int
+}
+
+// MaxSobolDim is the largest dimension supported by Sobol
+const MaxSobolDim = len(sobolParams) + 1
+
+// sobolBits is the number of bits in the Sobol direction numbers
+const sobolBits = 52
+
+// sobolParams are the primitive polynomial degrees (s), the polynomial
+// coefficients (a) and the initial direction numbers (m) for dimensions two
+// and higher, from the tables of Joe and Kuo
+var sobolParams = [...]struct {
+	s int
+	a uint
+	m []uint64
+}{
+	{1, 0, []uint64{1}},
+	{2, 1, []uint64{1, 3}},
+	{3, 1, []uint64{1, 3, 1}},
+	{3, 2, []uint64{1, 1, 1}},
+	{4, 1, []uint64{1, 1, 3, 3}},
+	{4, 4, []uint64{1, 3, 5, 13}},
+	{5, 2, []uint64{1, 1, 5, 5, 17}},
+	{5, 4, []uint64{1, 1, 5, 5, 5}},
+	{5, 7, []uint64{1, 1, 7, 11, 19}},
+	{5, 11, []uint64{1, 1, 5, 1, 1}},
+	{5, 13, []uint64{1, 1, 1, 3, 11}},
+	{5, 14, []uint64{1, 3, 5, 5, 31}},
+	{6, 1, []uint64{1, 3, 3, 9, 7, 49}},
+	{6, 13, []uint64{1, 1, 1, 15, 21, 21}},
+	{6, 16, []uint64{1, 3, 1, 13, 27, 49}},
+	{6, 19, []uint64{1, 1, 1, 15, 7, 5}},
+	{6, 22, []uint64{1, 3, 1, 15, 13, 25}},
+	{6, 25, []uint64{1, 1, 5, 5, 19, 61}},
+	{7, 1, []uint64{1, 3, 7, 11, 23, 15, 103}},
+	{7, 4, []uint64{1, 3, 7, 13, 13, 15, 69}},
+}
+
+// sobolDirections returns the direction numbers for dimension dim
+// (zero-indexed) scaled to sobolBits bits
+func sobolDirections(dim int) []uint64 {
+	v := make([]uint64, sobolBits)
+	if dim == 0 {
+		for k := range v {
+			v[k] = 1 << uint(sobolBits-1-k)
+		}
+		return v
+	}
+	p := sobolParams[dim-1]
+	for k := 0; k < sobolBits; k++ {
+		if k < p.s {
+			v[k] = p.m[k] << uint(sobolBits-1-k)
+			continue
+		}
+		v[k] = v[k-p.s] ^ (v[k-p.s] >> uint(p.s))
+		for i := 1; i < p.s; i++ {
+			if (p.a>>uint(p.s-1-i))&1 == 1 {
+				v[k] ^= v[k-i]
+			}
+		}
+	}
+	return v
+}
+
+func (s Sobol) Sample(dst [][]float64, lower, upper []float64) {
+	nDim := len(lower)
+	if nDim > MaxSobolDim {
+		panic("sobol: dimension too large")
+	}
+	dirs := make([][]uint64, nDim)
+	for j := range dirs {
+		dirs[j] = sobolDirections(j)
+	}
+
+	// Generate the points with the Gray code ordering of Antonov and Saleev
+	x := make([]uint64, nDim)
+	scale := 1 / float64(uint64(1)<<sobolBits)
+	for i := 0; i < s.Skip+len(dst); i++ {
+		if i > 0 {
+			// Index of the lowest zero bit of i-1
+			c := 0
+			for n := i - 1; n&1 == 1; n >>= 1 {
+				c++
+			}
+			for j := range x {
+				x[j] ^= dirs[j][c]
+			}
+		}
+		if i < s.Skip {
+			continue
+		}
+		loc := dst[i-s.Skip]
+		for j := range loc {
+			loc[j] = lower[j] + (upper[j]-lower[j])*float64(x[j])*scale
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package multivariate
+
+import (
+	"errors"
+	"math"
+
+	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/write"
+
+	"github.com/gonum/floats"
+)
+
+// BBStep is the Barzilai-Borwein formula for the spectral step length
+type BBStep int
+
+const (
+	// BB1 is the long step s^T s / s^T y
+	BB1 BBStep = iota
+
+	// BB2 is the short step s^T y / y^T y
+	BB2
+)
+
+// SpectralGradient is the spectral projected gradient method of Birgin,
+// Martínez and Raydan. The search direction is
+//
+//	d = P(x - λ g) - x
+//
+// where P is the projection onto the box [Lower, Upper] and λ is the
+// Barzilai-Borwein step length, which approximates the inverse of the
+// curvature along the previous step. The step x + d is accepted if it
+// satisfies the nonmonotone Armijo condition
+//
+//	f(x + d) <= max(f over the last Memory iterations) + Gamma g^T d
+//
+// which is usually the case, so that most iterations need a single
+// evaluation of the function. Otherwise the step is backtracked with
+// safeguarded quadratic interpolation. Only O(n) memory is used.
+//
+// Lower and Upper may be nil, in which case that side is unbounded, and their
+// elements may be infinite. As the gradient at a solution on the bounds is
+// not zero, the gradient returned by Iterate is the projected gradient
+// x - P(x - g), which is equal to the gradient for unbounded problems.
+type SpectralGradient struct {
+	Step    BBStep    // Formula for the step length
+	Lower   []float64 // Lower bounds
+	Upper   []float64 // Upper bounds
+	Memory  int       // Number of previous objective values in the nonmonotone condition. 1 is a monotone method
+	Gamma   float64   // Sufficient decrease parameter
+	MinStep float64   // Smallest step length
+	MaxStep float64   // Largest step length
+
+	fun    ObjGrader
+	nDim   int
+	lower  []float64 // Lower bounds with nil replaced by -Inf
+	upper  []float64 // Upper bounds with nil replaced by +Inf
+	lambda float64   // Current step length
+
+	objHist  []float64 // Most recent objective values
+	nHist    int       // Number of values in objHist
+	histNext int       // Index of objHist to overwrite next
+
+	currLoc   []float64
+	currObj   float64
+	currGrad  []float64
+	trialLoc  []float64
+	trialGrad []float64
+	d         []float64 // Search direction
+}
+
+// NewSpectralGradient returns a new unbounded SpectralGradient with the
+// default settings
+func NewSpectralGradient() *SpectralGradient {
+	return &SpectralGradient{
+		Step:    BB1,
+		Memory:  10,
+		Gamma:   1e-4,
+		MinStep: 1e-30,
+		MaxStep: 1e30,
+	}
+}
+
+func (sg *SpectralGradient) Init(f ObjGrader, initLoc []float64, initObj float64, initGrad []float64) error {
+	if initGrad == nil {
+		return errors.New("spectral: initGrad is nil")
+	}
+	if sg.Memory < 1 {
+		return errors.New("spectral: memory must be positive")
+	}
+	if sg.Gamma <= 0 || sg.Gamma >= 1 {
+		return errors.New("spectral: gamma must be between zero and one")
+	}
+	if sg.MinStep <= 0 || sg.MaxStep < sg.MinStep {
+		return errors.New("spectral: bad step length bounds")
+	}
+	sg.nDim = len(initLoc)
+	n := sg.nDim
+	if (sg.Lower != nil && len(sg.Lower) != n) || (sg.Upper != nil && len(sg.Upper) != n) {
+		return errors.New("spectral: bounds length does not match the dimension")
+	}
+	sg.lower = make([]float64, n)
+	sg.upper = make([]float64, n)
+	for i := range initLoc {
+		sg.lower[i], sg.upper[i] = boundsAt(sg.Lower, sg.Upper, i)
+		if !(sg.lower[i] <= sg.upper[i]) {
+			return errors.New("spectral: lower bound greater than upper bound")
+		}
+	}
+	if !InBounds(initLoc, sg.lower, sg.upper) {
+		return errors.New("spectral: initial location outside of the bounds")
+	}
+	sg.fun = f
+
+	sg.currLoc = append(sg.currLoc[:0], initLoc...)
+	sg.currGrad = append(sg.currGrad[:0], initGrad...)
+	sg.currObj = initObj
+	sg.trialLoc = make([]float64, n)
+	sg.trialGrad = make([]float64, n)
+	sg.d = make([]float64, n)
+
+	sg.objHist = make([]float64, sg.Memory)
+	sg.objHist[0] = initObj
+	sg.nHist = 1
+	sg.histNext = 1 % sg.Memory
+
+	// The first step length is the inverse of the size of the projected
+	// gradient, so that the first trial step has unit size
+	sg.projectedGrad(sg.d)
+	sg.lambda = sg.clampStep(1 / floats.Norm(sg.d, math.Inf(1)))
+	return nil
+}
+
+func (sg *SpectralGradient) Status() common.Status {
+	return common.Continue
+}
+
+func (sg *SpectralGradient) Iterate(loc, grad []float64) (obj float64, nFunEvals int, err error) {
+	if len(loc) != sg.nDim {
+		panic("dimension mismatch")
+	}
+	if len(grad) != sg.nDim {
+		panic("dimension mismatch")
+	}
+	copy(sg.d, sg.currLoc)
+	floats.AddScaled(sg.d, -sg.lambda, sg.currGrad)
+	ClampToBounds(sg.d, sg.lower, sg.upper)
+	floats.Sub(sg.d, sg.currLoc)
+	gd := floats.Dot(sg.currGrad, sg.d)
+	maxObj := floats.Max(sg.objHist[:sg.nHist])
+
+	// The box is convex, so all of the trial points are feasible
+	alpha := 1.0
+	for {
+		for i, x := range sg.currLoc {
+			sg.trialLoc[i] = x + alpha*sg.d[i]
+		}
+		obj = sg.fun.ObjGrad(sg.trialLoc, sg.trialGrad)
+		nFunEvals++
+		if obj <= maxObj+sg.Gamma*alpha*gd {
+			break
+		}
+		if alpha*floats.Norm(sg.d, math.Inf(1)) < 1e-16*(1+floats.Norm(sg.currLoc, math.Inf(1))) {
+			return 0, nFunEvals, errors.New("spectral: backtracking failed")
+		}
+		// Minimizer of the quadratic interpolating f(x), g^T d and f(x + alpha d)
+		next := -0.5 * alpha * alpha * gd / (obj - sg.currObj - alpha*gd)
+		if next >= 0.1*alpha && next <= 0.9*alpha {
+			alpha = next
+		} else {
+			alpha /= 2
+		}
+	}
+
+	// Compute the next step length with s in d and y in currGrad
+	for i, x := range sg.trialLoc {
+		sg.d[i] = x - sg.currLoc[i]
+		sg.currGrad[i] = sg.trialGrad[i] - sg.currGrad[i]
+	}
+	sy := floats.Dot(sg.d, sg.currGrad)
+	switch {
+	case sy <= 0:
+		sg.lambda = sg.MaxStep
+	case sg.Step == BB2:
+		sg.lambda = sg.clampStep(sy / floats.Dot(sg.currGrad, sg.currGrad))
+	default:
+		sg.lambda = sg.clampStep(floats.Dot(sg.d, sg.d) / sy)
+	}
+
+	copy(sg.currLoc, sg.trialLoc)
+	copy(sg.currGrad, sg.trialGrad)
+	sg.currObj = obj
+	sg.objHist[sg.histNext] = obj
+	sg.histNext = (sg.histNext + 1) % sg.Memory
+	if sg.nHist < sg.Memory {
+		sg.nHist++
+	}
+
+	copy(loc, sg.currLoc)
+	sg.projectedGrad(grad)
+	return obj, nFunEvals, nil
+}
+
+// projectedGrad puts x - P(x - g) at the current location in dst
+func (sg *SpectralGradient) projectedGrad(dst []float64) {
+	copy(dst, sg.currLoc)
+	floats.Sub(dst, sg.currGrad)
+	ClampToBounds(dst, sg.lower, sg.upper)
+	for i, x := range sg.currLoc {
+		dst[i] = x - dst[i]
+	}
+}
+
+// clampStep returns the step length moved into [MinStep, MaxStep]
+func (sg *SpectralGradient) clampStep(lambda float64) float64 {
+	return math.Min(math.Max(lambda, sg.MinStep), sg.MaxStep)
+}
+
+func (sg *SpectralGradient) AppendWriteData(v []*write.Value) []*write.Value {
+	v = append(v, &write.Value{Heading: "Lambda", Value: sg.lambda})
+	return v
+}
+
+func (sg *SpectralGradient) Result() {}
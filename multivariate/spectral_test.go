@@ -0,0 +1,144 @@
+package multivariate
+
+import (
+	"math"
+	"testing"
+
+	"github.com/btracey/opt/common"
+
+	"github.com/gonum/floats"
+)
+
+func TestSpectralGradient(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		step   BBStep
+		memory int
+	}{
+		{"BB1", BB1, 10},
+		{"BB2", BB2, 10},
+		{"monotone", BB1, 1},
+	} {
+		for _, f := range []struct {
+			ObjGrader
+			initLoc []float64
+			optLoc  []float64
+		}{
+			{scaledQuadratic{}, []float64{1, 1, 1, 1, 1, 1}, make([]float64, 6)},
+			{&Rosenbrock{5}, []float64{1.3, 0.7, 0.8, 1.9, 1.2}, []float64{1, 1, 1, 1, 1}},
+		} {
+			sg := NewSpectralGradient()
+			sg.Step = test.step
+			sg.Memory = test.memory
+
+			settings := DefaultSettings()
+			settings.DisplayWriters = nil
+			settings.GradAbsTol = 1e-8
+			settings.MaximumFunctionEvaluations = 100000
+			result, err := OptimizeGrad(f, f.initLoc, settings, sg)
+			if err != nil {
+				t.Errorf("%v: error optimizing: %v", test.name, err)
+				continue
+			}
+			if result.Status != common.GradAbsTol {
+				t.Errorf("%v: status mismatch. Want %v, found %v", test.name, common.GradAbsTol, result.Status)
+			}
+			if !floats.EqualApprox(result.Loc, f.optLoc, 1e-6) {
+				t.Errorf("%v: location mismatch. Want %v, found %v", test.name, f.optLoc, result.Loc)
+			}
+		}
+	}
+}
+
+func TestSpectralGradientBounds(t *testing.T) {
+	inf := math.Inf(1)
+	for _, test := range []struct {
+		name         string
+		lower, upper []float64
+		c            []float64
+		initLoc      []float64
+		want         []float64
+	}{
+		{"corner", []float64{-1, -1}, []float64{1, 1}, []float64{2, -3}, []float64{0, 0}, []float64{1, -1}},
+		{"nil lower", nil, []float64{1, inf, -2}, []float64{3, 4, -5}, []float64{0, 0, -3}, []float64{1, 4, -5}},
+		{"nil upper", []float64{-inf, 2}, nil, []float64{-7, 1}, []float64{5, 5}, []float64{-7, 2}},
+		{"unbounded", nil, nil, []float64{1, -2}, []float64{0, 0}, []float64{1, -2}},
+	} {
+		// The objective needs both bounds to check that it is evaluated
+		// inside of them
+		n := len(test.initLoc)
+		lower := make([]float64, n)
+		upper := make([]float64, n)
+		for i := range lower {
+			lower[i], upper[i] = boundsAt(test.lower, test.upper, i)
+		}
+		for _, step := range []BBStep{BB1, BB2} {
+			f := &boxQuadratic{c: test.c, lower: lower, upper: upper}
+			sg := NewSpectralGradient()
+			sg.Step = step
+			sg.Lower = test.lower
+			sg.Upper = test.upper
+			settings := DefaultSettings()
+			settings.DisplayWriters = nil
+			settings.GradAbsTol = 1e-10
+			result, err := OptimizeGrad(f, test.initLoc, settings, sg)
+			if err != nil {
+				t.Errorf("%v, step %v: error optimizing: %v", test.name, step, err)
+				continue
+			}
+			if result.Status != common.GradAbsTol {
+				t.Errorf("%v, step %v: status mismatch. Want %v, found %v", test.name, step, common.GradAbsTol, result.Status)
+			}
+			if f.outside {
+				t.Errorf("%v, step %v: objective evaluated outside of the bounds", test.name, step)
+			}
+			if !floats.EqualApprox(result.Loc, test.want, 1e-8) {
+				t.Errorf("%v, step %v: location mismatch. Want %v, found %v", test.name, step, test.want, result.Loc)
+			}
+		}
+	}
+
+	sg := NewSpectralGradient()
+	sg.Upper = []float64{1, 1}
+	_, err := OptimizeGrad(&boxQuadratic{c: []float64{0, 0}, lower: []float64{-inf, -inf}, upper: sg.Upper}, []float64{2, 0}, nil, sg)
+	if err == nil {
+		t.Errorf("No error for an initial location outside of the bounds")
+	}
+}
+
+// BenchmarkSpectralGradient minimizes a large quadratic, where most
+// iterations need a single evaluation of the function
+func BenchmarkSpectralGradient(b *testing.B) {
+	initLoc := make([]float64, 100000)
+	for i := range initLoc {
+		initLoc[i] = 1
+	}
+	f := spreadQuadratic{}
+	for i := 0; i < b.N; i++ {
+		settings := DefaultSettings()
+		settings.DisplayWriters = nil
+		settings.GradAbsTol = 1e-6
+		result, err := OptimizeGrad(f, initLoc, settings, NewSpectralGradient())
+		if err != nil {
+			b.Fatal(err)
+		}
+		if result.Status != common.GradAbsTol {
+			b.Fatalf("status mismatch. Want %v, found %v", common.GradAbsTol, result.Status)
+		}
+	}
+}
+
+// spreadQuadratic is sum_i (1 + i/n) x_i^2 / 2, whose curvatures are between
+// one and two
+type spreadQuadratic struct{}
+
+func (spreadQuadratic) ObjGrad(x, grad []float64) float64 {
+	var obj float64
+	n := float64(len(x))
+	for i, v := range x {
+		a := 1 + float64(i)/n
+		obj += 0.5 * a * v * v
+		grad[i] = a * v
+	}
+	return obj
+}
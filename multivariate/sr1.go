@@ -0,0 +1,277 @@
+package multivariate
+
+import (
+	"errors"
+	"math"
+
+	"github.com/btracey/opt/common"
+
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+// SR1 is a quasi-Newton optimizer that uses the symmetric rank-one update of
+// the Hessian approximation B,
+//
+//	B += (y - Bs)(y - Bs)^T / (y - Bs)^T s
+//
+// Unlike the BFGS update, the SR1 update does not keep B positive definite,
+// so it can capture negative curvature. The steps are therefore found with a
+// trust region rather than a linesearch, where the subproblem
+//
+//	min g^T p + 1/2 p^T B p  subject to  ||p|| <= radius
+//
+// is solved approximately with the Steihaug conjugate gradient method. The
+// update is skipped when |(y - Bs)^T s| < SkipTol ||s|| ||y - Bs||, which
+// would otherwise make it arbitrarily large.
+//
+// The initial approximation is InitialHessian or the diagonal matrix
+// InitialDiagonal if either is set. Otherwise it is the identity, which is
+// multiplied by y^T y / y^T s before the first update if ScaleInitial is true
+// so that its scale matches the curvature of the function along the first
+// step.
+type SR1 struct {
+	InitialHessian  *mat64.Dense // Initial Hessian approximation
+	InitialDiagonal []float64    // Diagonal of the initial Hessian approximation
+	ScaleInitial    bool         // Scale the identity initial approximation before the first update
+
+	InitialRadius float64 // Initial trust region radius
+	MaxRadius     float64 // Largest trust region radius
+	MinRadius     float64 // Optimization fails if the radius becomes smaller
+	Accept        float64 // Minimum ratio of actual to predicted decrease to accept a step. Must be in [0, 0.1)
+	SkipTol       float64 // Relative tolerance on the denominator for skipping the update
+
+	fun       ObjGrader
+	nDim      int
+	hess      *mat64.Dense
+	radius    float64
+	scaleNext bool // Scale the approximation before the next update
+
+	currLoc   []float64
+	currObj   float64
+	currGrad  []float64
+	trialLoc  []float64
+	trialGrad []float64
+	p         []float64 // Trial step
+	y         []float64
+	v         []float64 // y - Bs
+
+	// Conjugate gradient workspace
+	r  []float64
+	d  []float64
+	bd []float64
+}
+
+// NewSR1 returns a new SR1 with the default settings
+func NewSR1() *SR1 {
+	return &SR1{
+		InitialRadius: 1,
+		MaxRadius:     1e10,
+		MinRadius:     1e-14,
+		Accept:        1e-3,
+		SkipTol:       1e-8,
+		ScaleInitial:  true,
+	}
+}
+
+func (sr *SR1) Init(f ObjGrader, initLoc []float64, initObj float64, initGrad []float64) error {
+	if initGrad == nil {
+		return errors.New("sr1: initGrad is nil")
+	}
+	if sr.InitialRadius <= 0 || sr.MaxRadius < sr.InitialRadius {
+		return errors.New("sr1: bad trust region radius")
+	}
+	// A step that is rejected without shrinking the radius would be tried
+	// again forever
+	if sr.Accept < 0 || sr.Accept >= 0.1 {
+		return errors.New("sr1: accept must be in [0, 0.1)")
+	}
+	n := len(initLoc)
+	if sr.InitialHessian != nil {
+		if sr.InitialDiagonal != nil {
+			return errors.New("sr1: both initial Hessian and diagonal set")
+		}
+		r, c := sr.InitialHessian.Dims()
+		if r != n || c != n {
+			return errors.New("sr1: initial Hessian size mismatch")
+		}
+	}
+	if sr.InitialDiagonal != nil && len(sr.InitialDiagonal) != n {
+		return errors.New("sr1: initial diagonal length mismatch")
+	}
+	sr.fun = f
+	sr.nDim = n
+
+	sr.hess = mat64.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			var v float64
+			switch {
+			case sr.InitialHessian != nil:
+				v = sr.InitialHessian.At(i, j)
+			case i != j:
+			case sr.InitialDiagonal != nil:
+				v = sr.InitialDiagonal[i]
+			default:
+				v = 1
+			}
+			sr.hess.Set(i, j, v)
+		}
+	}
+	sr.scaleNext = sr.ScaleInitial && sr.InitialHessian == nil && sr.InitialDiagonal == nil
+	sr.radius = sr.InitialRadius
+
+	sr.currLoc = append(sr.currLoc[:0], initLoc...)
+	sr.currGrad = append(sr.currGrad[:0], initGrad...)
+	sr.currObj = initObj
+	sr.trialLoc = make([]float64, n)
+	sr.trialGrad = make([]float64, n)
+	sr.p = make([]float64, n)
+	sr.y = make([]float64, n)
+	sr.v = make([]float64, n)
+	sr.r = make([]float64, n)
+	sr.d = make([]float64, n)
+	sr.bd = make([]float64, n)
+	return nil
+}
+
+func (sr *SR1) Status() common.Status {
+	return common.Continue
+}
+
+// Iterate takes trial steps, shrinking the trust region after each rejected
+// one, until a step is accepted
+func (sr *SR1) Iterate(loc, grad []float64) (obj float64, nFunEvals int, err error) {
+	if len(loc) != sr.nDim {
+		panic("dimension mismatch")
+	}
+	if len(grad) != sr.nDim {
+		panic("dimension mismatch")
+	}
+	for {
+		if sr.radius < sr.MinRadius {
+			return 0, nFunEvals, errors.New("sr1: trust region radius too small")
+		}
+		sr.steihaug()
+
+		// Predicted decrease of the quadratic model
+		sr.mulHess(sr.bd, sr.p)
+		pred := -(floats.Dot(sr.currGrad, sr.p) + 0.5*floats.Dot(sr.p, sr.bd))
+
+		copy(sr.trialLoc, sr.currLoc)
+		floats.Add(sr.trialLoc, sr.p)
+		trialObj := sr.fun.ObjGrad(sr.trialLoc, sr.trialGrad)
+		nFunEvals++
+
+		// The update uses the trial point whether or not it is accepted
+		copy(sr.y, sr.trialGrad)
+		floats.Sub(sr.y, sr.currGrad)
+		sr.update()
+
+		rho := (sr.currObj - trialObj) / pred
+		pNorm := floats.Norm(sr.p, 2)
+		switch {
+		case math.IsNaN(rho) || rho < 0.1:
+			sr.radius = 0.5 * pNorm
+		case rho > 0.75 && pNorm >= 0.8*sr.radius:
+			sr.radius = math.Min(2*sr.radius, sr.MaxRadius)
+		}
+		if rho > sr.Accept && !math.IsNaN(trialObj) {
+			copy(sr.currLoc, sr.trialLoc)
+			copy(sr.currGrad, sr.trialGrad)
+			sr.currObj = trialObj
+			copy(loc, sr.currLoc)
+			copy(grad, sr.currGrad)
+			return trialObj, nFunEvals, nil
+		}
+	}
+}
+
+// update performs the SR1 update with the step in p and the change in
+// gradient in y
+func (sr *SR1) update() {
+	if sr.scaleNext {
+		// The scaling needs positive curvature along the step, so it waits
+		// for the first step that has it
+		if sy := floats.Dot(sr.y, sr.p); sy > 0 {
+			sr.scaleNext = false
+			scaleDense(sr.hess, floats.Dot(sr.y, sr.y)/sy)
+		}
+	}
+	sr.mulHess(sr.v, sr.p)
+	floats.Scale(-1, sr.v)
+	floats.Add(sr.v, sr.y)
+	denom := floats.Dot(sr.v, sr.p)
+	if math.Abs(denom) < sr.SkipTol*floats.Norm(sr.p, 2)*floats.Norm(sr.v, 2) || denom == 0 {
+		return
+	}
+	symRankTwo(sr.hess, 1/denom, 0, 0, sr.v, sr.v)
+}
+
+// steihaug puts in p the approximate solution of the trust region
+// subproblem found with the Steihaug conjugate gradient method
+func (sr *SR1) steihaug() {
+	for i := range sr.p {
+		sr.p[i] = 0
+	}
+	copy(sr.r, sr.currGrad)
+	gNorm := floats.Norm(sr.r, 2)
+	if gNorm == 0 {
+		return
+	}
+	tol := math.Min(0.5, math.Sqrt(gNorm)) * gNorm
+	for i, v := range sr.r {
+		sr.d[i] = -v
+	}
+	rr := gNorm * gNorm
+	for k := 0; k < 2*sr.nDim; k++ {
+		sr.mulHess(sr.bd, sr.d)
+		dbd := floats.Dot(sr.d, sr.bd)
+		if dbd <= 0 {
+			// Negative curvature, so go to the boundary
+			sr.toBoundary()
+			return
+		}
+		alpha := rr / dbd
+		floats.AddScaled(sr.p, alpha, sr.d)
+		if floats.Norm(sr.p, 2) >= sr.radius {
+			floats.AddScaled(sr.p, -alpha, sr.d)
+			sr.toBoundary()
+			return
+		}
+		floats.AddScaled(sr.r, alpha, sr.bd)
+		rrNew := floats.Dot(sr.r, sr.r)
+		if math.Sqrt(rrNew) < tol {
+			return
+		}
+		beta := rrNew / rr
+		rr = rrNew
+		for i, v := range sr.r {
+			sr.d[i] = -v + beta*sr.d[i]
+		}
+	}
+}
+
+// toBoundary moves p along d to the trust region boundary
+func (sr *SR1) toBoundary() {
+	// Positive root of ||p + tau d||^2 = radius^2
+	dd := floats.Dot(sr.d, sr.d)
+	pd := floats.Dot(sr.p, sr.d)
+	pp := floats.Dot(sr.p, sr.p)
+	disc := math.Sqrt(math.Max(0, pd*pd-dd*(pp-sr.radius*sr.radius)))
+	var tau float64
+	if pd >= 0 {
+		// Avoid cancellation in -pd + disc
+		tau = (sr.radius*sr.radius - pp) / (pd + disc)
+	} else {
+		tau = (-pd + disc) / dd
+	}
+	floats.AddScaled(sr.p, tau, sr.d)
+}
+
+// mulHess puts B*x in dst
+func (sr *SR1) mulHess(dst, x []float64) {
+	symMulVec(dst, sr.hess, x)
+}
+
+func (sr *SR1) Result() {}
@@ -0,0 +1,127 @@
+package multivariate
+
+import (
+	"math"
+	"testing"
+
+	"github.com/btracey/opt/common"
+
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+// doubleWell is x_0^4 - x_0^2 + x_1^2, which has a saddle point at the origin
+// and minima at x_0 = ±1/sqrt(2)
+type doubleWell struct{}
+
+func (doubleWell) ObjGrad(x, grad []float64) float64 {
+	grad[0] = 4*x[0]*x[0]*x[0] - 2*x[0]
+	grad[1] = 2 * x[1]
+	return x[0]*x[0]*x[0]*x[0] - x[0]*x[0] + x[1]*x[1]
+}
+
+func TestSR1(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		f       ObjGrader
+		initLoc []float64
+		optLoc  []float64
+	}{
+		{"rosen", &Rosenbrock{5}, []float64{1.3, 0.7, 0.8, 1.9, 1.2}, []float64{1, 1, 1, 1, 1}},
+		// Starting near the saddle point, where the Hessian is indefinite
+		{"doubleWell", doubleWell{}, []float64{1e-3, 1}, []float64{1 / math.Sqrt2, 0}},
+	} {
+		settings := DefaultSettings()
+		settings.DisplayWriters = nil
+		settings.GradAbsTol = 1e-10
+		settings.MaximumFunctionEvaluations = 1000
+		sr := NewSR1()
+		result, err := OptimizeGrad(test.f, test.initLoc, settings, sr)
+		if err != nil {
+			t.Errorf("%v: error optimizing: %v", test.name, err)
+			continue
+		}
+		if result.Status != common.GradAbsTol {
+			t.Errorf("%v: status mismatch. Want %v, found %v", test.name, common.GradAbsTol, result.Status)
+		}
+		if !floats.EqualApprox(result.Loc, test.optLoc, 1e-6) {
+			t.Errorf("%v: location mismatch. Want %v, found %v", test.name, test.optLoc, result.Loc)
+		}
+
+		// Running again must give the same result
+		result2, err := OptimizeGrad(test.f, test.initLoc, settings, sr)
+		if err != nil {
+			t.Errorf("%v: error re-using optimizer: %v", test.name, err)
+			continue
+		}
+		if result2.FunctionEvaluations != result.FunctionEvaluations {
+			t.Errorf("%v: different number of function evaluations second time", test.name)
+		}
+	}
+}
+
+func TestSR1Initial(t *testing.T) {
+	f := badlyScaled{}
+	initLoc := []float64{1, 1, 1, 1}
+	n := len(initLoc)
+	diag := make([]float64, n)
+	for i := range diag {
+		diag[i] = 2e-4 * float64(i+1)
+	}
+	dense := mat64.NewDense(n, n, nil)
+	for i, v := range diag {
+		dense.Set(i, i, v)
+	}
+	evals := make(map[string]int)
+	for _, test := range []struct {
+		name  string
+		dense *mat64.Dense
+		diag  []float64
+		scale bool
+	}{
+		{"identity", nil, nil, false},
+		{"scaled", nil, nil, true},
+		// The exact Hessian must not be scaled
+		{"dense", dense, nil, true},
+		{"diagonal", nil, diag, true},
+	} {
+		sr := NewSR1()
+		sr.InitialHessian = test.dense
+		sr.InitialDiagonal = test.diag
+		sr.ScaleInitial = test.scale
+		settings := DefaultSettings()
+		settings.DisplayWriters = nil
+		settings.GradAbsTol = 1e-10
+		result, err := OptimizeGrad(f, initLoc, settings, sr)
+		if err != nil {
+			t.Errorf("%v: error optimizing: %v", test.name, err)
+			continue
+		}
+		if !floats.EqualApprox(result.Loc, make([]float64, n), 1e-6) {
+			t.Errorf("%v: location mismatch. Want %v, found %v", test.name, make([]float64, n), result.Loc)
+		}
+		evals[test.name] = result.FunctionEvaluations
+	}
+	for _, name := range []string{"scaled", "dense", "diagonal"} {
+		if evals[name] >= evals["identity"] {
+			t.Errorf("%v: no fewer function evaluations than the identity. Want less than %v, found %v", name, evals["identity"], evals[name])
+		}
+	}
+
+	for _, test := range []struct {
+		name string
+		set  func(sr *SR1)
+	}{
+		{"both initial", func(sr *SR1) { sr.InitialHessian, sr.InitialDiagonal = dense, diag }},
+		{"diagonal length", func(sr *SR1) { sr.InitialDiagonal = diag[:2] }},
+		{"accept too large", func(sr *SR1) { sr.Accept = 0.5 }},
+		{"negative accept", func(sr *SR1) { sr.Accept = -1 }},
+	} {
+		sr := NewSR1()
+		test.set(sr)
+		_, err := OptimizeGrad(f, initLoc, nil, sr)
+		if err == nil {
+			t.Errorf("%v: no error for bad settings", test.name)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package multivariate
+
+import (
+	"errors"
+	"math"
+)
+
+// BoundTransform is a smooth map from the real line onto an interval. It is
+// used by BoundedObjective for the variables that have both a lower and an
+// upper bound. Variables with only one bound use the softplus function
+//
+//	x = l + log(1 + exp(z))  or  x = u - log(1 + exp(z))
+//
+// and unbounded variables are not transformed.
+type BoundTransform int
+
+const (
+	// Logit maps z to l + (u-l)/(1+exp(-z)). The bounds are approached only
+	// as z goes to infinity, so a minimum on a bound is found approximately.
+	Logit BoundTransform = iota
+
+	// Sine maps z to l + (u-l)(1+sin(z))/2. The bounds are reached at finite
+	// z, but the map is periodic and its derivative is zero at the bounds.
+	Sine
+)
+
+// BoundedObjective is the objective function of a problem with bounds on the
+// variables, written in transformed variables z in which the problem is
+// unconstrained. The objective at z is Fun at x(z), where x(z) is always
+// within [Lower, Upper], and the gradient is found with the chain rule. Lower
+// and Upper may contain infinite values, and either may be nil if no
+// variable is bounded from that side.
+type BoundedObjective struct {
+	Fun       ObjGrader
+	Lower     []float64
+	Upper     []float64
+	Transform BoundTransform // Transform for variables bounded from both sides
+
+	x     []float64
+	gradX []float64
+}
+
+// NewBoundedObjective returns a BoundedObjective with the Logit transform
+func NewBoundedObjective(f ObjGrader, lower, upper []float64) *BoundedObjective {
+	return &BoundedObjective{
+		Fun:       f,
+		Lower:     lower,
+		Upper:     upper,
+		Transform: Logit,
+	}
+}
+
+// bounds returns the bounds of the ith variable
+func (b *BoundedObjective) bounds(i int) (l, u float64) {
+	return boundsAt(b.Lower, b.Upper, i)
+}
+
+// check verifies that the bounds have dimension nDim and that the lower
+// bounds are less than the upper bounds
+func (b *BoundedObjective) check(nDim int) error {
+	if b.Fun == nil {
+		return errors.New("bounded: objective function is nil")
+	}
+	if (b.Lower != nil && len(b.Lower) != nDim) || (b.Upper != nil && len(b.Upper) != nDim) {
+		return errors.New("bounded: bounds length does not match the dimension")
+	}
+	for i := 0; i < nDim; i++ {
+		l, u := b.bounds(i)
+		if !(l < u) || math.IsInf(l, 1) || math.IsInf(u, -1) {
+			return errors.New("bounded: lower bound not less than upper bound")
+		}
+	}
+	if b.Transform != Logit && b.Transform != Sine {
+		return errors.New("bounded: unknown transform")
+	}
+	return nil
+}
+
+// transform returns x(z) and its derivative for the bounds l and u
+func (b *BoundedObjective) transform(z, l, u float64) (x, deriv float64) {
+	lowInf := math.IsInf(l, -1)
+	upInf := math.IsInf(u, 1)
+	switch {
+	case lowInf && upInf:
+		return z, 1
+	case upInf:
+		return l + softplus(z), sigmoid(z)
+	case lowInf:
+		return u - softplus(z), -sigmoid(z)
+	}
+	w := u - l
+	if b.Transform == Sine {
+		return l + w*(1+math.Sin(z))/2, w * math.Cos(z) / 2
+	}
+	s := sigmoid(z)
+	return l + w*s, w * s * (1 - s)
+}
+
+// inverse returns z such that x(z) = x, or false if x is not strictly within
+// the bounds l and u
+func (b *BoundedObjective) inverse(x, l, u float64) (z float64, ok bool) {
+	if !(x > l && x < u) {
+		return math.NaN(), false
+	}
+	lowInf := math.IsInf(l, -1)
+	upInf := math.IsInf(u, 1)
+	switch {
+	case lowInf && upInf:
+		return x, true
+	case upInf:
+		return softplusInverse(x - l), true
+	case lowInf:
+		return softplusInverse(u - x), true
+	}
+	t := (x - l) / (u - l)
+	if b.Transform == Sine {
+		return math.Asin(2*t - 1), true
+	}
+	return math.Log(t) - math.Log1p(-t), true
+}
+
+// ToOriginal puts in x the original variables at the transformed variables z
+func (b *BoundedObjective) ToOriginal(x, z []float64) {
+	if len(x) != len(z) {
+		panic("dimension mismatch")
+	}
+	for i, v := range z {
+		l, u := b.bounds(i)
+		x[i], _ = b.transform(v, l, u)
+	}
+}
+
+// FromOriginal puts in z the transformed variables at the original variables
+// x. An error is returned if x is not strictly within the bounds, as the
+// bounds themselves are reached at infinite z or, for the Sine transform,
+// where the gradient is zero.
+func (b *BoundedObjective) FromOriginal(z, x []float64) error {
+	if len(x) != len(z) {
+		panic("dimension mismatch")
+	}
+	for i, v := range x {
+		l, u := b.bounds(i)
+		var ok bool
+		z[i], ok = b.inverse(v, l, u)
+		if !ok {
+			return errors.New("bounded: location not strictly within the bounds")
+		}
+	}
+	return nil
+}
+
+func (b *BoundedObjective) ObjGrad(z, grad []float64) float64 {
+	if len(b.x) != len(z) {
+		b.x = make([]float64, len(z))
+		b.gradX = make([]float64, len(z))
+	}
+	b.ToOriginal(b.x, z)
+	obj := b.Fun.ObjGrad(b.x, b.gradX)
+	for i, v := range z {
+		l, u := b.bounds(i)
+		_, deriv := b.transform(v, l, u)
+		grad[i] = b.gradX[i] * deriv
+	}
+	return obj
+}
+
+// OptimizeBounded minimizes f subject to lower <= x <= upper by minimizing
+// the BoundedObjective in the transformed variables with the unconstrained
+// optimizer, which is Bfgs if nil. initLoc must be strictly within the bounds.
+// The location and gradient of the result are in the original variables.
+// The gradient tolerances of the settings apply to the gradient in the
+// transformed variables, which goes to zero at a minimum on a bound.
+func OptimizeBounded(f ObjGrader, lower, upper, initLoc []float64, transform BoundTransform, settings *Settings, optimizer GradOptimizer) (*Result, error) {
+	if initLoc == nil {
+		return nil, errors.New("nil init loc")
+	}
+	b := &BoundedObjective{
+		Fun:       f,
+		Lower:     lower,
+		Upper:     upper,
+		Transform: transform,
+	}
+	nDim := len(initLoc)
+	err := b.check(nDim)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = DefaultSettings()
+	}
+	initZ := make([]float64, nDim)
+	err = b.FromOriginal(initZ, initLoc)
+	if err != nil {
+		return nil, err
+	}
+
+	// The initial gradient is in the original variables
+	s := *settings
+	if s.InitialGradient != nil {
+		s.InitialGradient = make([]float64, nDim)
+		for i, v := range initZ {
+			l, u := b.bounds(i)
+			_, deriv := b.transform(v, l, u)
+			s.InitialGradient[i] = settings.InitialGradient[i] * deriv
+		}
+	}
+
+	result, err := OptimizeGrad(b, initZ, &s, optimizer)
+	if err != nil {
+		return nil, err
+	}
+
+	// Map the result back to the original variables. The gradient cannot be
+	// recovered where the derivative of the transform is zero, in which case
+	// it is evaluated again.
+	z := result.Loc
+	result.Loc = make([]float64, nDim)
+	b.ToOriginal(result.Loc, z)
+	if result.Grad != nil {
+		for i, v := range z {
+			l, u := b.bounds(i)
+			_, deriv := b.transform(v, l, u)
+			if deriv == 0 {
+				result.Grad = make([]float64, nDim)
+				f.ObjGrad(result.Loc, result.Grad)
+				result.FunctionEvaluations++
+				break
+			}
+			result.Grad[i] /= deriv
+		}
+	}
+	return result, nil
+}
+
+// sigmoid returns 1/(1+exp(-z)) without overflow
+func sigmoid(z float64) float64 {
+	if z >= 0 {
+		return 1 / (1 + math.Exp(-z))
+	}
+	e := math.Exp(z)
+	return e / (1 + e)
+}
+
+// softplus returns log(1+exp(z)) without overflow
+func softplus(z float64) float64 {
+	return math.Max(z, 0) + math.Log1p(math.Exp(-math.Abs(z)))
+}
+
+// softplusInverse returns z such that softplus(z) = y for y > 0
+func softplusInverse(y float64) float64 {
+	return y + math.Log(-math.Expm1(-y))
+}
@@ -0,0 +1,109 @@
+package multivariate
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gonum/floats"
+)
+
+// boxQuadratic is sum_i (x_i - c_i)^2 and records whether it was evaluated
+// outside of the bounds
+type boxQuadratic struct {
+	c            []float64
+	lower, upper []float64
+	outside      bool
+}
+
+func (b *boxQuadratic) ObjGrad(x, grad []float64) float64 {
+	var obj float64
+	for i, v := range x {
+		if v < b.lower[i] || v > b.upper[i] {
+			b.outside = true
+		}
+		d := v - b.c[i]
+		obj += d * d
+		grad[i] = 2 * d
+	}
+	return obj
+}
+
+func TestOptimizeBounded(t *testing.T) {
+	inf := math.Inf(1)
+	// The minimum is inside the bounds in the first and last dimensions and
+	// on the bound in the others
+	lower := []float64{0, -1, -inf, -inf}
+	upper := []float64{1, inf, 5, inf}
+	c := []float64{0.5, -3, 10, 2}
+	want := []float64{0.5, -1, 5, 2}
+	initLoc := []float64{0.9, 4, -2, 0}
+	for _, transform := range []BoundTransform{Logit, Sine} {
+		for _, optimizer := range []GradOptimizer{NewBfgs(), NewLbfgs()} {
+			f := &boxQuadratic{c: c, lower: lower, upper: upper}
+			settings := DefaultSettings()
+			settings.DisplayWriters = nil
+			result, err := OptimizeBounded(f, lower, upper, initLoc, transform, settings, optimizer)
+			if err != nil {
+				t.Errorf("Transform %v: error optimizing: %v", transform, err)
+				continue
+			}
+			if f.outside {
+				t.Errorf("Transform %v: objective evaluated outside of the bounds", transform)
+			}
+			if !floats.EqualApprox(result.Loc, want, 1e-5) {
+				t.Errorf("Transform %v: location mismatch. Want %v, found %v", transform, want, result.Loc)
+			}
+			grad := make([]float64, len(c))
+			obj := f.ObjGrad(result.Loc, grad)
+			if math.Abs(obj-result.Obj) > 1e-14 {
+				t.Errorf("Transform %v: objective mismatch. Want %v, found %v", transform, obj, result.Obj)
+			}
+			if !floats.EqualApprox(result.Grad, grad, 1e-8) {
+				t.Errorf("Transform %v: gradient mismatch. Want %v, found %v", transform, grad, result.Grad)
+			}
+		}
+	}
+
+	_, err := OptimizeBounded(&boxQuadratic{c: c, lower: lower, upper: upper}, lower, upper, []float64{1, 0, 0, 0}, Logit, nil, nil)
+	if err == nil {
+		t.Errorf("No error for an initial location on the bounds")
+	}
+}
+
+func TestBoundedObjective(t *testing.T) {
+	inf := math.Inf(1)
+	lower := []float64{-2, 1, -inf, -inf}
+	upper := []float64{3, inf, -1, inf}
+	x := []float64{0.5, 4, -3, 7}
+	for _, transform := range []BoundTransform{Logit, Sine} {
+		b := NewBoundedObjective(&boxQuadratic{c: []float64{1, 2, 3, 4}, lower: lower, upper: upper}, lower, upper)
+		b.Transform = transform
+		z := make([]float64, len(x))
+		err := b.FromOriginal(z, x)
+		if err != nil {
+			t.Fatalf("Transform %v: error transforming: %v", transform, err)
+		}
+		x2 := make([]float64, len(x))
+		b.ToOriginal(x2, z)
+		if !floats.EqualApprox(x2, x, 1e-12) {
+			t.Errorf("Transform %v: round trip mismatch. Want %v, found %v", transform, x, x2)
+		}
+
+		// Compare the chain rule gradient with finite differences
+		grad := make([]float64, len(z))
+		b.ObjGrad(z, grad)
+		const h = 1e-6
+		tmp := make([]float64, len(z))
+		for i := range z {
+			z[i] += h
+			fp := b.ObjGrad(z, tmp)
+			z[i] -= 2 * h
+			fm := b.ObjGrad(z, tmp)
+			z[i] += h
+			fd := (fp - fm) / (2 * h)
+			if math.Abs(fd-grad[i]) > 1e-6*math.Max(1, math.Abs(fd)) {
+				t.Errorf("Transform %v: gradient %v mismatch. Finite difference %v, found %v", transform, i, fd, grad[i])
+			}
+		}
+	}
+}
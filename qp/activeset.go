@@ -0,0 +1,385 @@
+package qp
+
+import (
+	"errors"
+	"math"
+
+	"github.com/btracey/opt/lp"
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+// ActiveSet is a primal active-set method for small dense quadratic programs.
+// Starting from a feasible point, every iteration minimizes the objective with
+// the constraints in the working set held as equalities, adding the blocking
+// constraint when the step is shortened and removing the constraint with the
+// most negative multiplier at the minimizer. The subproblems are solved in the
+// null space of the working set, and directions of zero curvature are
+// followed until a constraint blocks them, so Q need only be positive
+// semidefinite.
+//
+// If no feasible starting location is given, one is found by solving a linear
+// program with LP.
+type ActiveSet struct {
+	Tol           float64     // Tolerance on the constraints, steps and multipliers
+	MaxIterations int         // Maximum number of iterations. Negative means no maximum
+	LP            *lp.Simplex // Solver for finding a feasible starting location
+
+	constraints
+}
+
+// NewActiveSet returns a new ActiveSet with the default settings
+func NewActiveSet() *ActiveSet {
+	return &ActiveSet{
+		Tol:           1e-10,
+		MaxIterations: -1,
+		LP:            lp.NewSimplex(),
+	}
+}
+
+// Solve solves the quadratic program p. initX is used as the starting
+// location if it is feasible, and may be nil.
+func (s *ActiveSet) Solve(p *Problem, initX []float64) (*Result, error) {
+	if s.Tol <= 0 {
+		return nil, errors.New("qp: tolerance must be positive")
+	}
+	s.setup(p)
+	n := s.n
+
+	x := make([]float64, n)
+	if initX != nil && len(initX) == n && s.feasible(initX) {
+		copy(x, initX)
+	} else if err := s.phaseOne(x); err != nil {
+		return nil, err
+	}
+
+	// Start with the equality constraints and the linearly independent
+	// inequality constraints active at x
+	var working []int
+	for i := 0; i < s.nEq+s.nIn; i++ {
+		if i >= s.nEq && s.residual(x, i) < -s.scale(i) {
+			continue
+		}
+		if s.independent(working, i) {
+			working = append(working, i)
+		}
+	}
+
+	g := make([]float64, n)
+	dir := make([]float64, n)
+	ai := make([]float64, n)
+	var iter int
+	for ; ; iter++ {
+		if s.MaxIterations >= 0 && iter >= s.MaxIterations {
+			return nil, errors.New("qp: maximum iterations reached")
+		}
+		gradient(g, p.Q, p.C, x)
+		ray := s.direction(dir, p.Q, g, working)
+
+		if !ray && floats.Norm(dir, math.Inf(1)) <= s.Tol*math.Max(1, floats.Norm(x, math.Inf(1))) {
+			// x minimizes the objective on the working set. It is optimal
+			// if the inequality multipliers are nonnegative, otherwise the
+			// constraint with the most negative multiplier is removed
+			mult, err := s.multipliers(g, working)
+			if err != nil {
+				return nil, err
+			}
+			drop := -1
+			minMult := -s.Tol * math.Max(1, floats.Norm(g, math.Inf(1)))
+			for k, i := range working {
+				if i >= s.nEq && mult[k] < minMult {
+					drop = k
+					minMult = mult[k]
+				}
+			}
+			if drop < 0 {
+				return s.result(p, x, working, mult, iter), nil
+			}
+			working = append(working[:drop], working[drop+1:]...)
+			continue
+		}
+
+		// Longest step along the direction that keeps x feasible
+		alpha := 1.0
+		if ray {
+			alpha = math.Inf(1)
+		}
+		block := -1
+		for i := s.nEq; i < s.nEq+s.nIn; i++ {
+			if contains(working, i) {
+				continue
+			}
+			s.row(ai, i)
+			ap := floats.Dot(ai, dir)
+			if ap <= s.Tol*floats.Norm(ai, 2)*floats.Norm(dir, 2) {
+				continue
+			}
+			step := math.Max(0, -s.residual(x, i)/ap)
+			if step < alpha {
+				alpha = step
+				block = i
+			}
+		}
+		if math.IsInf(alpha, 1) {
+			return nil, ErrUnbounded
+		}
+		floats.AddScaled(x, alpha, dir)
+		if block >= 0 {
+			working = append(working, block)
+		}
+	}
+}
+
+// scale returns the tolerance on the residual of constraint i
+func (s *ActiveSet) scale(i int) float64 {
+	return math.Sqrt(s.Tol) * math.Max(1, math.Abs(s.rhs[i]))
+}
+
+func (s *ActiveSet) feasible(x []float64) bool {
+	for i := 0; i < s.nEq+s.nIn; i++ {
+		r := s.residual(x, i)
+		if i < s.nEq {
+			r = math.Abs(r)
+		}
+		if r > s.scale(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// phaseOne puts a feasible location in x by solving a linear program with
+// zero objective, with every equality constraint written as two
+// inequalities
+func (s *ActiveSet) phaseOne(x []float64) error {
+	m := 2*s.nEq + s.nIn
+	if m == 0 {
+		for i := range x {
+			x[i] = 0
+		}
+		return nil
+	}
+	g := mat64.NewDense(m, s.n, nil)
+	h := make([]float64, m)
+	for i := 0; i < s.nEq+s.nIn; i++ {
+		for j := 0; j < s.n; j++ {
+			g.Set(i, j, s.cons.At(i, j))
+		}
+		h[i] = s.rhs[i]
+	}
+	for i := 0; i < s.nEq; i++ {
+		for j := 0; j < s.n; j++ {
+			g.Set(s.nEq+s.nIn+i, j, -s.cons.At(i, j))
+		}
+		h[s.nEq+s.nIn+i] = -s.rhs[i]
+	}
+	simplex := s.LP
+	if simplex == nil {
+		simplex = lp.NewSimplex()
+	}
+	result, err := simplex.Inequality(make([]float64, s.n), g, h)
+	if err == lp.ErrInfeasible {
+		return ErrInfeasible
+	}
+	if err != nil {
+		return errors.New("qp: finding feasible point: " + err.Error())
+	}
+	copy(x, result.X)
+	return nil
+}
+
+// independent returns whether the normal of constraint i is linearly
+// independent of the normals of the working set
+func (s *ActiveSet) independent(working []int, i int) bool {
+	basis := s.rowBasis(working)
+	v := make([]float64, s.n)
+	s.row(v, i)
+	nrm := floats.Norm(v, 2)
+	project(v, basis)
+	return floats.Norm(v, 2) > 1e-8*nrm
+}
+
+// rowBasis returns an orthonormal basis of the span of the normals of the
+// working set
+func (s *ActiveSet) rowBasis(working []int) [][]float64 {
+	var basis [][]float64
+	for _, i := range working {
+		v := make([]float64, s.n)
+		s.row(v, i)
+		nrm := floats.Norm(v, 2)
+		project(v, basis)
+		if vn := floats.Norm(v, 2); vn > 1e-8*nrm {
+			floats.Scale(1/vn, v)
+			basis = append(basis, v)
+		}
+	}
+	return basis
+}
+
+// direction puts in dir the step from x to the minimizer of the objective
+// with the working set held as equalities, and returns false. If the
+// objective is unbounded below on the working set, dir is instead a descent
+// direction of zero curvature and direction returns true.
+func (s *ActiveSet) direction(dir []float64, q *mat64.Dense, g []float64, working []int) bool {
+	n := s.n
+	for i := range dir {
+		dir[i] = 0
+	}
+
+	// Orthonormal basis z of the null space of the working set
+	basis := s.rowBasis(working)
+	var z [][]float64
+	for k := 0; k < n && len(basis)+len(z) < n; k++ {
+		v := make([]float64, n)
+		v[k] = 1
+		project(v, basis)
+		project(v, z)
+		if vn := floats.Norm(v, 2); vn > 1e-8 {
+			floats.Scale(1/vn, v)
+			z = append(z, v)
+		}
+	}
+	nz := len(z)
+	if nz == 0 {
+		return false
+	}
+
+	// Reduced Hessian and gradient
+	qz := make([][]float64, nz)
+	for k, zk := range z {
+		qz[k] = make([]float64, n)
+		for i := 0; i < n; i++ {
+			var v float64
+			for j, zj := range zk {
+				v += q.At(i, j) * zj
+			}
+			qz[k][i] = v
+		}
+	}
+	h := mat64.NewDense(nz, nz, nil)
+	var hMax float64
+	for k := 0; k < nz; k++ {
+		for l := 0; l < nz; l++ {
+			v := floats.Dot(z[k], qz[l])
+			h.Set(k, l, v)
+			hMax = math.Max(hMax, math.Abs(v))
+		}
+	}
+	r := make([]float64, nz)
+	for k, zk := range z {
+		r[k] = floats.Dot(zk, g)
+	}
+
+	// Orthonormal basis of the range of the reduced Hessian. The part of the
+	// reduced gradient outside the range is a direction of zero curvature
+	var rng [][]float64
+	for l := 0; l < nz; l++ {
+		v := make([]float64, nz)
+		for k := range v {
+			v[k] = h.At(k, l)
+		}
+		project(v, rng)
+		if vn := floats.Norm(v, 2); vn > 1e-8*hMax && vn > 0 {
+			floats.Scale(1/vn, v)
+			rng = append(rng, v)
+		}
+	}
+	rn := make([]float64, nz)
+	copy(rn, r)
+	project(rn, rng)
+	if floats.Norm(rn, 2) > s.Tol*math.Max(1, floats.Norm(g, 2)) {
+		for k, zk := range z {
+			floats.AddScaled(dir, -rn[k], zk)
+		}
+		return true
+	}
+
+	// Solve the reduced system on the range of the reduced Hessian
+	nr := len(rng)
+	if nr == 0 {
+		return false
+	}
+	hv := make([][]float64, nr)
+	for a, va := range rng {
+		hv[a] = make([]float64, nz)
+		for k := 0; k < nz; k++ {
+			var v float64
+			for l, vl := range va {
+				v += h.At(k, l) * vl
+			}
+			hv[a][k] = v
+		}
+	}
+	kmat := mat64.NewDense(nr, nr, nil)
+	w := make([]float64, nr)
+	for a := 0; a < nr; a++ {
+		for b := 0; b < nr; b++ {
+			kmat.Set(a, b, floats.Dot(rng[a], hv[b]))
+		}
+		w[a] = -floats.Dot(rng[a], r)
+	}
+	if err := solve(kmat, w); err != nil {
+		return false
+	}
+	u := make([]float64, nz)
+	for a, va := range rng {
+		floats.AddScaled(u, w[a], va)
+	}
+	for k, zk := range z {
+		floats.AddScaled(dir, u[k], zk)
+	}
+	return false
+}
+
+// multipliers returns the multipliers of the working set at a minimizer on
+// the working set, the least squares solution of A_W^T λ = -g
+func (s *ActiveSet) multipliers(g []float64, working []int) ([]float64, error) {
+	m := len(working)
+	if m == 0 {
+		return nil, nil
+	}
+	rows := make([][]float64, m)
+	for k, i := range working {
+		rows[k] = make([]float64, s.n)
+		s.row(rows[k], i)
+	}
+	a := mat64.NewDense(m, m, nil)
+	mult := make([]float64, m)
+	for k := 0; k < m; k++ {
+		for l := 0; l < m; l++ {
+			a.Set(k, l, floats.Dot(rows[k], rows[l]))
+		}
+		mult[k] = -floats.Dot(rows[k], g)
+	}
+	if err := solve(a, mult); err != nil {
+		return nil, errors.New("qp: linearly dependent working set")
+	}
+	return mult, nil
+}
+
+func (s *ActiveSet) result(p *Problem, x []float64, working []int, mult []float64, iter int) *Result {
+	all := make([]float64, s.nEq+s.nIn)
+	for k, i := range working {
+		all[i] = mult[k]
+	}
+	return s.constraints.result(p, x, all, iter)
+}
+
+// project removes the components of v along the orthonormal vectors in basis,
+// repeating the projection once for numerical stability
+func project(v []float64, basis [][]float64) {
+	for pass := 0; pass < 2; pass++ {
+		for _, b := range basis {
+			floats.AddScaled(v, -floats.Dot(v, b), b)
+		}
+	}
+}
+
+func contains(s []int, v int) bool {
+	for _, w := range s {
+		if w == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package qp
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+func TestActiveSet(t *testing.T) {
+	inf := math.Inf(1)
+	for _, test := range []struct {
+		name  string
+		p     *Problem
+		initX []float64
+		x     []float64
+		obj   float64
+		err   error
+	}{
+		{
+			// Example 16.4 of Nocedal and Wright
+			name: "nw16.4",
+			p: &Problem{
+				Q: mat64.NewDense(2, 2, []float64{2, 0, 0, 2}),
+				C: []float64{-2, -5},
+				G: mat64.NewDense(3, 2, []float64{
+					-1, 2,
+					1, 2,
+					1, -2,
+				}),
+				H:     []float64{2, 6, 2},
+				Lower: []float64{0, 0},
+			},
+			initX: []float64{2, 0},
+			x:     []float64{1.4, 1.7},
+			obj:   -6.45,
+		},
+		{
+			name: "nw16.4 phase one",
+			p: &Problem{
+				Q: mat64.NewDense(2, 2, []float64{2, 0, 0, 2}),
+				C: []float64{-2, -5},
+				G: mat64.NewDense(3, 2, []float64{
+					-1, 2,
+					1, 2,
+					1, -2,
+				}),
+				H:     []float64{2, 6, 2},
+				Lower: []float64{0, 0},
+			},
+			x:   []float64{1.4, 1.7},
+			obj: -6.45,
+		},
+		{
+			name: "equality",
+			p: &Problem{
+				Q: mat64.NewDense(3, 3, []float64{
+					6, 2, 1,
+					2, 5, 2,
+					1, 2, 4,
+				}),
+				C: []float64{-8, -3, -3},
+				A: mat64.NewDense(2, 3, []float64{
+					1, 0, 1,
+					0, 1, 1,
+				}),
+				B: []float64{3, 0},
+			},
+			x:   []float64{2, -1, 1},
+			obj: -3.5,
+		},
+		{
+			// Linear objective, solved at a vertex
+			name: "linear",
+			p: &Problem{
+				Q:     mat64.NewDense(2, 2, nil),
+				C:     []float64{-1, -1},
+				G:     mat64.NewDense(2, 2, []float64{1, 2, 3, 1}),
+				H:     []float64{4, 6},
+				Lower: []float64{0, 0},
+			},
+			x:   []float64{1.6, 1.2},
+			obj: -2.8,
+		},
+		{
+			// Semidefinite Q with the minimum on a bound
+			name: "semidefinite",
+			p: &Problem{
+				Q:     mat64.NewDense(2, 2, []float64{1, 0, 0, 0}),
+				C:     []float64{-1, 1},
+				Lower: []float64{-inf, -2},
+			},
+			x:   []float64{1, -2},
+			obj: -2.5,
+		},
+		{
+			name: "unbounded",
+			p: &Problem{
+				Q:     mat64.NewDense(2, 2, []float64{1, 0, 0, 0}),
+				C:     []float64{0, -1},
+				Lower: []float64{0, 0},
+			},
+			err: ErrUnbounded,
+		},
+		{
+			name: "infeasible",
+			p: &Problem{
+				Q:     mat64.NewDense(1, 1, []float64{1}),
+				C:     []float64{0},
+				Lower: []float64{2},
+				Upper: []float64{1},
+			},
+			err: ErrInfeasible,
+		},
+	} {
+		result, err := NewActiveSet().Solve(test.p, test.initX)
+		if err != test.err {
+			t.Errorf("%v: error mismatch. Want %v, found %v", test.name, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !floats.EqualApprox(result.X, test.x, 1e-10) {
+			t.Errorf("%v: location mismatch. Want %v, found %v", test.name, test.x, result.X)
+		}
+		if math.Abs(result.Obj-test.obj) > 1e-10 {
+			t.Errorf("%v: objective mismatch. Want %v, found %v", test.name, test.obj, result.Obj)
+		}
+		checkKKT(t, test.name, test.p, result)
+	}
+}
+
+func TestActiveSetPortfolio(t *testing.T) {
+	// Minimum variance portfolio with a target return, fully invested and
+	// without short sales
+	rnd := rand.New(rand.NewSource(1))
+	for k := 0; k < 20; k++ {
+		n := 2 + rnd.Intn(8)
+		// Covariance of random samples of the returns, which is singular
+		// when the number of samples is less than the dimension
+		samples := 1 + rnd.Intn(2*n)
+		f := mat64.NewDense(samples, n, nil)
+		for i := 0; i < samples; i++ {
+			for j := 0; j < n; j++ {
+				f.Set(i, j, rnd.NormFloat64())
+			}
+		}
+		cov := mat64.NewDense(n, n, nil)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				var v float64
+				for k := 0; k < samples; k++ {
+					v += f.At(k, i) * f.At(k, j)
+				}
+				cov.Set(i, j, v/float64(samples))
+			}
+		}
+		ret := make([]float64, n)
+		for i := range ret {
+			ret[i] = rnd.Float64()
+		}
+		ones := make([]float64, n)
+		for i := range ones {
+			ones[i] = 1
+		}
+		p := &Problem{
+			Q:     cov,
+			C:     make([]float64, n),
+			A:     mat64.NewDense(1, n, ones),
+			B:     []float64{1},
+			G:     mat64.NewDense(1, n, nil),
+			H:     []float64{-floats.Sum(ret) / float64(n)},
+			Lower: make([]float64, n),
+		}
+		for i, r := range ret {
+			p.G.Set(0, i, -r)
+		}
+		result, err := NewActiveSet().Solve(p, nil)
+		if err != nil {
+			t.Errorf("Case %v: error solving: %v", k, err)
+			continue
+		}
+		checkKKT(t, "portfolio", p, result)
+	}
+}
@@ -0,0 +1,191 @@
+package qp
+
+import (
+	"errors"
+	"math"
+
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+// Dual is the dual active-set method of Goldfarb and Idnani for strictly
+// convex quadratic programs. It starts from the unconstrained minimizer and
+// in every iteration adds the most violated constraint to the active set,
+// dropping active constraints whose multipliers would become negative, so
+// that the iterates stay optimal for the dual problem until they are feasible
+// for the primal problem. No feasible starting location is needed, but Q must
+// be positive definite.
+type Dual struct {
+	Tol           float64 // Tolerance on the constraint violations and steps
+	MaxIterations int     // Maximum number of iterations. Negative means no maximum
+
+	constraints
+}
+
+// NewDual returns a new Dual with the default settings
+func NewDual() *Dual {
+	return &Dual{
+		Tol:           1e-12,
+		MaxIterations: -1,
+	}
+}
+
+// Solve solves the quadratic program p
+func (d *Dual) Solve(p *Problem) (*Result, error) {
+	if d.Tol <= 0 {
+		return nil, errors.New("qp: tolerance must be positive")
+	}
+	d.setup(p)
+	n := d.n
+	m := d.nEq + d.nIn
+
+	// Start from the unconstrained minimizer
+	chol := mat64.Cholesky(p.Q)
+	if !chol.SPD {
+		return nil, errors.New("qp: Q is not positive definite")
+	}
+	x := make([]float64, n)
+	sol := chol.Solve(mat64.NewDense(n, 1, append([]float64(nil), p.C...)))
+	for i := range x {
+		x[i] = -sol.At(i, 0)
+	}
+
+	// The normals of the constraints in the active set are -sign_k a_k, so
+	// that the constraints are -sign_k a_k^T x >= -sign_k b_k with
+	// nonnegative multipliers u_k
+	var active []int
+	var sign []float64
+	var u []float64
+	isActive := make([]bool, m)
+
+	nq := make([]float64, n)
+	z := make([]float64, n)
+	var iter int
+	for ; ; iter++ {
+		if d.MaxIterations >= 0 && iter >= d.MaxIterations {
+			return nil, errors.New("qp: maximum iterations reached")
+		}
+
+		// Choose the constraint to add. Equality constraints are added
+		// first, with the sign for which they are violated, and then the
+		// most violated inequality constraint
+		q := -1
+		sgn := 1.0
+		for i := 0; i < d.nEq; i++ {
+			if !isActive[i] {
+				q = i
+				if d.residual(x, i) < 0 {
+					sgn = -1
+				}
+				break
+			}
+		}
+		if q < 0 {
+			var maxViol float64
+			for i := d.nEq; i < m; i++ {
+				if isActive[i] {
+					continue
+				}
+				r := d.residual(x, i)
+				if r > d.Tol*math.Max(1, math.Abs(d.rhs[i])) && (q < 0 || r > maxViol) {
+					q = i
+					maxViol = r
+				}
+			}
+			if q < 0 {
+				break
+			}
+		}
+		d.row(nq, q)
+		floats.Scale(-sgn, nq)
+		var uq float64
+
+		for {
+			// Primal step direction z and change in the active multipliers r
+			r, err := d.activeStep(z, p.Q, nq, active, sign)
+			if err != nil {
+				return nil, err
+			}
+
+			// Largest step that keeps the active inequality multipliers
+			// nonnegative
+			t1 := math.Inf(1)
+			drop := -1
+			for k, i := range active {
+				if i >= d.nEq && r[k] > 0 {
+					if t := u[k] / r[k]; t < t1 {
+						t1 = t
+						drop = k
+					}
+				}
+			}
+			// Step that makes constraint q active
+			t2 := math.Inf(1)
+			if zn := floats.Dot(z, nq); math.Abs(zn) > d.Tol*math.Max(1, floats.Norm(nq, 2)) {
+				t2 = sgn * d.residual(x, q) / zn
+			}
+			t := math.Min(t1, t2)
+			if math.IsInf(t, 1) {
+				return nil, ErrInfeasible
+			}
+
+			if !math.IsInf(t2, 1) {
+				floats.AddScaled(x, t, z)
+			}
+			for k := range u {
+				u[k] -= t * r[k]
+			}
+			uq += t
+			if t == t2 {
+				active = append(active, q)
+				sign = append(sign, sgn)
+				u = append(u, uq)
+				isActive[q] = true
+				break
+			}
+			isActive[active[drop]] = false
+			active = append(active[:drop], active[drop+1:]...)
+			sign = append(sign[:drop], sign[drop+1:]...)
+			u = append(u[:drop], u[drop+1:]...)
+		}
+	}
+
+	mult := make([]float64, m)
+	for k, i := range active {
+		mult[i] = sign[k] * u[k]
+	}
+	return d.result(p, x, mult, iter), nil
+}
+
+// activeStep computes the step direction z and the change in the multipliers
+// r when adding the constraint with normal nq to the active set, by solving
+//
+//	[Q   N] [z]   [nq]
+//	[N^T 0] [r] = [ 0]
+//
+// where the columns of N are the normals of the active constraints
+func (d *Dual) activeStep(z []float64, q *mat64.Dense, nq []float64, active []int, sign []float64) ([]float64, error) {
+	n := len(z)
+	m := len(active)
+	kkt := mat64.NewDense(n+m, n+m, nil)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			kkt.Set(i, j, q.At(i, j))
+		}
+	}
+	ai := make([]float64, n)
+	for k, i := range active {
+		d.row(ai, i)
+		for j, v := range ai {
+			kkt.Set(j, n+k, -sign[k]*v)
+			kkt.Set(n+k, j, -sign[k]*v)
+		}
+	}
+	x := make([]float64, n+m)
+	copy(x, nq)
+	if err := solve(kkt, x); err != nil {
+		return nil, err
+	}
+	copy(z, x[:n])
+	return x[n:], nil
+}
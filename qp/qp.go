@@ -0,0 +1,196 @@
+// Package qp solves convex quadratic programs.
+package qp
+
+import (
+	"errors"
+	"math"
+
+	"github.com/btracey/opt/common"
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+var (
+	ErrInfeasible = errors.New("qp: problem is infeasible")
+	ErrUnbounded  = errors.New("qp: problem is unbounded")
+)
+
+// Problem is the convex quadratic program
+//
+//	minimize    1/2 x^T Q x + c^T x
+//	subject to  A x = B
+//	            G x <= H
+//	            Lower <= x <= Upper
+//
+// where Q is symmetric positive semidefinite. A and G may be nil when there
+// are no constraints of that type, and Lower and Upper may be nil when there
+// are no bounds. Infinite bounds are ignored.
+type Problem struct {
+	Q *mat64.Dense
+	C []float64
+
+	A *mat64.Dense
+	B []float64
+	G *mat64.Dense
+	H []float64
+
+	Lower []float64
+	Upper []float64
+}
+
+// Result is the solution of a quadratic program. The multipliers are those
+// of the Lagrangian
+//
+//	L = 1/2 x^T Q x + c^T x + λ_E^T (A x - B) + λ_I^T (G x - H) + λ_L^T (Lower - x) + λ_U^T (x - Upper)
+//
+// so the inequality and bound multipliers are nonnegative. The multipliers of
+// infinite bounds are zero.
+type Result struct {
+	Obj float64   // Optimal objective value
+	X   []float64 // Optimal location
+
+	EqualityMultipliers   []float64
+	InequalityMultipliers []float64
+	LowerMultipliers      []float64
+	UpperMultipliers      []float64
+
+	Iterations int // Number of iterations of the active set method
+}
+
+// constraints stores the constraints of a problem as the rows of a single
+// matrix, with the equality constraints a_i^T x = b_i first and then the
+// inequality constraints a_i^T x <= b_i, including the finite bounds
+type constraints struct {
+	n    int
+	nEq  int
+	nIn  int
+	cons *mat64.Dense
+	rhs  []float64
+	// bound maps the rows of the bound constraints to the variables
+	bound []int
+}
+
+// setup stores the constraints of p
+func (c *constraints) setup(p *Problem) {
+	n := len(p.C)
+	if r, cols := p.Q.Dims(); r != n || cols != n {
+		panic("qp: dimension mismatch")
+	}
+	c.n = n
+	c.nEq = len(p.B)
+	var rows [][]float64
+	var rhs []float64
+	addRows := func(m *mat64.Dense, b []float64, sign float64) {
+		for i, v := range b {
+			row := make([]float64, n)
+			for j := range row {
+				row[j] = sign * m.At(i, j)
+			}
+			rows = append(rows, row)
+			rhs = append(rhs, sign*v)
+		}
+	}
+	if c.nEq > 0 {
+		addRows(p.A, p.B, 1)
+	}
+	if len(p.H) > 0 {
+		addRows(p.G, p.H, 1)
+	}
+	c.bound = c.bound[:0]
+	for _, bound := range []struct {
+		b    []float64
+		sign float64
+	}{{p.Lower, -1}, {p.Upper, 1}} {
+		for j, v := range bound.b {
+			if math.IsInf(v, 0) {
+				continue
+			}
+			row := make([]float64, n)
+			row[j] = bound.sign
+			rows = append(rows, row)
+			rhs = append(rhs, bound.sign*v)
+			c.bound = append(c.bound, j)
+		}
+	}
+	c.nIn = len(rows) - c.nEq
+	c.rhs = rhs
+	c.cons = nil
+	if len(rows) > 0 {
+		c.cons = mat64.NewDense(len(rows), n, nil)
+		for i, row := range rows {
+			for j, v := range row {
+				c.cons.Set(i, j, v)
+			}
+		}
+	}
+}
+
+// row puts the normal of constraint i in dst
+func (c *constraints) row(dst []float64, i int) {
+	for j := range dst {
+		dst[j] = c.cons.At(i, j)
+	}
+}
+
+// residual returns a_i^T x - b_i, which is nonpositive for a feasible x
+func (c *constraints) residual(x []float64, i int) float64 {
+	v := -c.rhs[i]
+	for j, xj := range x {
+		v += c.cons.At(i, j) * xj
+	}
+	return v
+}
+
+// result returns the result at x, where mult contains the multipliers of all
+// of the constraints
+func (c *constraints) result(p *Problem, x, mult []float64, iter int) *Result {
+	g := make([]float64, c.n)
+	gradient(g, p.Q, p.C, x)
+	// The objective is 1/2 x^T Q x + c^T x = 1/2 (g + c)^T x
+	obj := 0.5 * (floats.Dot(g, x) + floats.Dot(p.C, x))
+
+	nG := len(p.H)
+	res := &Result{
+		Obj:                   obj,
+		X:                     x,
+		EqualityMultipliers:   mult[:c.nEq],
+		InequalityMultipliers: mult[c.nEq : c.nEq+nG],
+		LowerMultipliers:      make([]float64, len(p.Lower)),
+		UpperMultipliers:      make([]float64, len(p.Upper)),
+		Iterations:            iter,
+	}
+	for k, j := range c.bound {
+		i := c.nEq + nG + k
+		if c.cons.At(i, j) < 0 {
+			res.LowerMultipliers[j] = mult[i]
+		} else {
+			res.UpperMultipliers[j] = mult[i]
+		}
+	}
+	return res
+}
+
+// gradient puts Q x + c in dst
+func gradient(dst []float64, q *mat64.Dense, c, x []float64) {
+	for i := range dst {
+		v := c[i]
+		for j, xj := range x {
+			v += q.At(i, j) * xj
+		}
+		dst[i] = v
+	}
+}
+
+// solve solves a x = b in place using the LU decomposition of a, which is
+// overwritten
+func solve(a *mat64.Dense, b []float64) error {
+	lu, singular := common.LU(a)
+	if singular {
+		return errors.New("qp: singular matrix")
+	}
+	x := lu.Solve(mat64.NewDense(len(b), 1, b))
+	for i := range b {
+		b[i] = x.At(i, 0)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package qp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gonum/floats"
+	"github.com/gonum/matrix/mat64"
+)
+
+func TestDual(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		p    *Problem
+		x    []float64
+		obj  float64
+		err  error
+	}{
+		{
+			// Example 16.4 of Nocedal and Wright, with only the first
+			// constraint active
+			name: "nw16.4",
+			p: &Problem{
+				Q: mat64.NewDense(2, 2, []float64{2, 0, 0, 2}),
+				C: []float64{-2, -5},
+				G: mat64.NewDense(5, 2, []float64{
+					-1, 2,
+					1, 2,
+					1, -2,
+					-1, 0,
+					0, -1,
+				}),
+				H: []float64{2, 6, 2, 0, 0},
+			},
+			x:   []float64{1.4, 1.7},
+			obj: -6.45,
+		},
+		{
+			// With the equality constraint x_0 = x_1 no inequality
+			// constraint is active
+			name: "nw16.4 equality",
+			p: &Problem{
+				Q: mat64.NewDense(2, 2, []float64{2, 0, 0, 2}),
+				C: []float64{-2, -5},
+				A: mat64.NewDense(1, 2, []float64{1, -1}),
+				B: []float64{0},
+				G: mat64.NewDense(5, 2, []float64{
+					-1, 2,
+					1, 2,
+					1, -2,
+					-1, 0,
+					0, -1,
+				}),
+				H: []float64{2, 6, 2, 0, 0},
+			},
+			x:   []float64{1.75, 1.75},
+			obj: -6.125,
+		},
+		{
+			name: "nw16.4 bounds",
+			p: &Problem{
+				Q: mat64.NewDense(2, 2, []float64{2, 0, 0, 2}),
+				C: []float64{-2, -5},
+				G: mat64.NewDense(3, 2, []float64{
+					-1, 2,
+					1, 2,
+					1, -2,
+				}),
+				H:     []float64{2, 6, 2},
+				Lower: []float64{0, 0},
+				Upper: []float64{math.Inf(1), 1},
+			},
+			x:   []float64{1, 1},
+			obj: -5,
+		},
+		{
+			name: "equality",
+			p: &Problem{
+				Q: mat64.NewDense(3, 3, []float64{
+					6, 2, 1,
+					2, 5, 2,
+					1, 2, 4,
+				}),
+				C: []float64{-8, -3, -3},
+				A: mat64.NewDense(2, 3, []float64{
+					1, 0, 1,
+					0, 1, 1,
+				}),
+				B: []float64{3, 0},
+			},
+			x:   []float64{2, -1, 1},
+			obj: -3.5,
+		},
+		{
+			name: "infeasible",
+			p: &Problem{
+				Q: mat64.NewDense(2, 2, []float64{2, 0, 0, 2}),
+				C: []float64{-2, -5},
+				G: mat64.NewDense(2, 2, []float64{-1, 0, 1, 0}),
+				H: []float64{-1, 0},
+			},
+			err: ErrInfeasible,
+		},
+	} {
+		result, err := NewDual().Solve(test.p)
+		if err != test.err {
+			t.Errorf("%v: error mismatch. Want %v, found %v", test.name, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !floats.EqualApprox(result.X, test.x, 1e-10) {
+			t.Errorf("%v: location mismatch. Want %v, found %v", test.name, test.x, result.X)
+		}
+		if math.Abs(result.Obj-test.obj) > 1e-10 {
+			t.Errorf("%v: objective mismatch. Want %v, found %v", test.name, test.obj, result.Obj)
+		}
+		checkKKT(t, test.name, test.p, result)
+	}
+
+	// Q must be positive definite
+	p := &Problem{
+		Q: mat64.NewDense(2, 2, []float64{1, 0, 0, 0}),
+		C: []float64{0, -1},
+	}
+	if _, err := NewDual().Solve(p); err == nil {
+		t.Errorf("No error for a semidefinite Q")
+	}
+}
+
+// checkKKT checks the optimality conditions at the result
+func checkKKT(t *testing.T, name string, p *Problem, result *Result) {
+	const tol = 1e-8
+	x := result.X
+	grad := make([]float64, len(x))
+	gradient(grad, p.Q, p.C, x)
+	for i, m := range result.EqualityMultipliers {
+		var v float64
+		for j, xj := range x {
+			v += p.A.At(i, j) * xj
+			grad[j] += m * p.A.At(i, j)
+		}
+		if math.Abs(v-p.B[i]) > tol {
+			t.Errorf("%v: equality %v violated by %v", name, i, v-p.B[i])
+		}
+	}
+	for i, m := range result.InequalityMultipliers {
+		var v float64
+		for j, xj := range x {
+			v += p.G.At(i, j) * xj
+			grad[j] += m * p.G.At(i, j)
+		}
+		if v-p.H[i] > tol || m < -tol || math.Abs(m*(v-p.H[i])) > tol {
+			t.Errorf("%v: inequality %v not optimal. Residual %v, multiplier %v", name, i, v-p.H[i], m)
+		}
+	}
+	for j, m := range result.LowerMultipliers {
+		grad[j] -= m
+		if p.Lower[j]-x[j] > tol || m < -tol || math.Abs(m*(x[j]-p.Lower[j])) > tol {
+			t.Errorf("%v: lower bound %v not optimal. Location %v, multiplier %v", name, j, x[j], m)
+		}
+	}
+	for j, m := range result.UpperMultipliers {
+		grad[j] += m
+		if x[j]-p.Upper[j] > tol || m < -tol || math.Abs(m*(x[j]-p.Upper[j])) > tol {
+			t.Errorf("%v: upper bound %v not optimal. Location %v, multiplier %v", name, j, x[j], m)
+		}
+	}
+	if floats.Norm(grad, math.Inf(1)) > tol {
+		t.Errorf("%v: gradient of the Lagrangian not zero: %v", name, grad)
+	}
+}
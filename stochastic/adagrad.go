@@ -0,0 +1,59 @@
+package stochastic
+
+import (
+	"errors"
+	"math"
+
+	"github.com/btracey/opt/write"
+)
+
+// AdaGrad scales the learning rate of each variable by the inverse square
+// root of the sum of its squared gradients,
+//
+//	G += g^2
+//	x -= η g / (sqrt(G) + Epsilon)
+//
+// so that rarely updated variables take larger steps. The effective learning
+// rate always decreases, so a constant schedule is usually used.
+type AdaGrad struct {
+	Rate    Schedule // Learning rate
+	Epsilon float64  // Added to the denominator for stability
+
+	iter  int
+	rate  float64
+	sumSq []float64
+}
+
+// NewAdaGrad returns a new AdaGrad with a learning rate of 0.01
+func NewAdaGrad() *AdaGrad {
+	return &AdaGrad{
+		Rate:    Constant(0.01),
+		Epsilon: 1e-8,
+	}
+}
+
+func (a *AdaGrad) Init(nDim int) error {
+	if err := checkSchedule(a.Rate); err != nil {
+		return errors.New("adagrad: " + err.Error())
+	}
+	if a.Epsilon <= 0 {
+		return errors.New("adagrad: epsilon must be positive")
+	}
+	a.iter = 0
+	a.sumSq = make([]float64, nDim)
+	return nil
+}
+
+func (a *AdaGrad) Iterate(loc, grad []float64) {
+	a.rate = a.Rate.Rate(a.iter)
+	a.iter++
+	for i, g := range grad {
+		a.sumSq[i] += g * g
+		loc[i] -= a.rate * g / (math.Sqrt(a.sumSq[i]) + a.Epsilon)
+	}
+}
+
+func (a *AdaGrad) AppendWriteData(v []*write.Value) []*write.Value {
+	v = append(v, &write.Value{Heading: "Rate", Value: a.rate})
+	return v
+}
@@ -0,0 +1,89 @@
+package stochastic
+
+import (
+	"errors"
+	"math"
+
+	"github.com/btracey/opt/write"
+)
+
+// Adam keeps exponential moving averages of the gradient and of its square,
+//
+//	m = Beta1 m + (1 - Beta1) g
+//	v = Beta2 v + (1 - Beta2) g^2
+//	x -= η m̂ / (sqrt(v̂) + Epsilon)
+//
+// where m̂ and v̂ are m and v corrected for their bias towards the zero
+// initial values. If WeightDecay is not zero the decay is decoupled from the
+// gradient, x -= η WeightDecay x, which is the AdamW variant. Unlike an L2
+// penalty added to the gradient, the decoupled decay is not scaled by the
+// moving averages.
+type Adam struct {
+	Rate        Schedule // Learning rate
+	Beta1       float64  // Decay of the moving average of the gradient in [0, 1)
+	Beta2       float64  // Decay of the moving average of the squared gradient in [0, 1)
+	Epsilon     float64  // Added to the denominator for stability
+	WeightDecay float64  // Coefficient of the decoupled weight decay
+
+	iter int
+	rate float64
+	m    []float64
+	v    []float64
+}
+
+// NewAdam returns a new Adam with a learning rate of 0.001
+func NewAdam() *Adam {
+	return &Adam{
+		Rate:    Constant(0.001),
+		Beta1:   0.9,
+		Beta2:   0.999,
+		Epsilon: 1e-8,
+	}
+}
+
+// NewAdamW returns a new Adam with a learning rate of 0.001 and a decoupled
+// weight decay of 0.01
+func NewAdamW() *Adam {
+	a := NewAdam()
+	a.WeightDecay = 0.01
+	return a
+}
+
+func (a *Adam) Init(nDim int) error {
+	if err := checkSchedule(a.Rate); err != nil {
+		return errors.New("adam: " + err.Error())
+	}
+	if a.Beta1 < 0 || a.Beta1 >= 1 || a.Beta2 < 0 || a.Beta2 >= 1 {
+		return errors.New("adam: betas must be in [0, 1)")
+	}
+	if a.Epsilon <= 0 {
+		return errors.New("adam: epsilon must be positive")
+	}
+	if a.WeightDecay < 0 {
+		return errors.New("adam: negative weight decay")
+	}
+	a.iter = 0
+	a.m = make([]float64, nDim)
+	a.v = make([]float64, nDim)
+	return nil
+}
+
+func (a *Adam) Iterate(loc, grad []float64) {
+	a.rate = a.Rate.Rate(a.iter)
+	a.iter++
+	t := float64(a.iter)
+	c1 := 1 - math.Pow(a.Beta1, t)
+	c2 := 1 - math.Pow(a.Beta2, t)
+	for i, g := range grad {
+		a.m[i] = a.Beta1*a.m[i] + (1-a.Beta1)*g
+		a.v[i] = a.Beta2*a.v[i] + (1-a.Beta2)*g*g
+		mHat := a.m[i] / c1
+		vHat := a.v[i] / c2
+		loc[i] -= a.rate * (mHat/(math.Sqrt(vHat)+a.Epsilon) + a.WeightDecay*loc[i])
+	}
+}
+
+func (a *Adam) AppendWriteData(v []*write.Value) []*write.Value {
+	v = append(v, &write.Value{Heading: "Rate", Value: a.rate})
+	return v
+}
@@ -0,0 +1,146 @@
+package stochastic
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/write"
+)
+
+// Optimizer represents a first-order stochastic optimizer. Iterate takes a
+// step from loc, in place, using the gradient grad on a minibatch, and must
+// not modify grad.
+type Optimizer interface {
+	Init(nDim int) error
+	Iterate(loc, grad []float64)
+}
+
+// Wrapper is a convenience wrapper around a stochastic optimizer that allows
+// more fine-grained control over optimization progress. Each iteration
+// evaluates the objective on the next minibatch and takes a step. See
+// Optimize for example usage
+type Wrapper struct {
+	optimizer Optimizer
+	helper    *Helper
+
+	fun     Objective
+	order   []int // Order of the minibatches in the current epoch
+	next    int   // Position of the next minibatch in order
+	grad    []float64
+	source  *rand.Rand
+	shuffle bool
+}
+
+// NewWrapper creates a new wrapper around the optimizer. If the optimizer
+// is a write.DataAdder, its data is added to the display
+func NewWrapper(optimizer Optimizer) *Wrapper {
+	w := &Wrapper{
+		optimizer: optimizer,
+		helper:    NewHelper(),
+	}
+	if dataAdder, ok := optimizer.(write.DataAdder); ok {
+		w.helper.AddDataAdder(dataAdder)
+	}
+	return w
+}
+
+func (w *Wrapper) Init(settings *Settings, fun Objective, initLoc []float64) error {
+	nBatches := fun.NumBatches()
+	if nBatches < 1 {
+		return errors.New("stochastic: no minibatches")
+	}
+	w.fun = fun
+	w.order = make([]int, nBatches)
+	for i := range w.order {
+		w.order[i] = i
+	}
+	w.next = 0
+	w.grad = make([]float64, len(initLoc))
+	w.source = settings.Source
+	w.shuffle = settings.Shuffle
+
+	w.helper.Init(settings, fun, initLoc)
+	return w.optimizer.Init(len(initLoc))
+}
+
+func (w *Wrapper) Status() common.Status {
+	return w.helper.Status()
+}
+
+// Iterate takes a step from loc, in place
+func (w *Wrapper) Iterate(loc []float64) error {
+	if w.next == 0 && w.shuffle {
+		w.permute()
+	}
+	obj := w.fun.ObjGradBatch(loc, w.order[w.next], w.grad)
+	if math.IsNaN(obj) || math.IsInf(obj, 0) {
+		return errors.New("stochastic: objective is not finite, the learning rate may be too large")
+	}
+	w.optimizer.Iterate(loc, w.grad)
+	w.helper.Iterate(loc, obj, w.grad, 1)
+
+	w.next++
+	if w.next == len(w.order) {
+		w.next = 0
+		w.helper.EndEpoch()
+	}
+	return nil
+}
+
+// permute shuffles the order of the minibatches
+func (w *Wrapper) permute() {
+	for i := len(w.order) - 1; i > 0; i-- {
+		var j int
+		if w.source == nil {
+			j = rand.Intn(i + 1)
+		} else {
+			j = w.source.Intn(i + 1)
+		}
+		w.order[i], w.order[j] = w.order[j], w.order[i]
+	}
+}
+
+func (w *Wrapper) Result(status common.Status) *Result {
+	return w.helper.Result(status)
+}
+
+// Optimize minimizes the objective function f on minibatches starting from
+// initLoc. If optimizer is nil, Adam with the default settings is used. If
+// settings is nil, the default settings are used.
+func Optimize(f Objective, initLoc []float64, settings *Settings, optimizer Optimizer) (*Result, error) {
+	if optimizer == nil {
+		optimizer = NewAdam()
+	}
+	if settings == nil {
+		settings = DefaultSettings()
+	}
+	if initLoc == nil {
+		return nil, errors.New("nil init loc")
+	}
+	if f == nil {
+		return nil, errors.New("objective function is nil")
+	}
+
+	wrapper := NewWrapper(optimizer)
+	err := wrapper.Init(settings, f, initLoc)
+	if err != nil {
+		return nil, errors.New("error initializing: " + err.Error())
+	}
+	loc := make([]float64, len(initLoc))
+	copy(loc, initLoc)
+
+	var status common.Status
+	for {
+		status = wrapper.Status()
+		if status != common.Continue {
+			break
+		}
+		err := wrapper.Iterate(loc)
+		if err != nil {
+			return nil, errors.New("error iterating optimizer: " + err.Error())
+		}
+	}
+	return wrapper.Result(status), nil
+}
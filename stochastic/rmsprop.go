@@ -0,0 +1,64 @@
+package stochastic
+
+import (
+	"errors"
+	"math"
+
+	"github.com/btracey/opt/write"
+)
+
+// RMSProp scales the learning rate of each variable by the inverse square
+// root of an exponential moving average of its squared gradients,
+//
+//	s = Decay s + (1 - Decay) g^2
+//	x -= η g / (sqrt(s) + Epsilon)
+//
+// Unlike AdaGrad, old gradients are forgotten, so the effective learning rate
+// does not decrease to zero.
+type RMSProp struct {
+	Rate    Schedule // Learning rate
+	Decay   float64  // Decay of the moving average in [0, 1)
+	Epsilon float64  // Added to the denominator for stability
+
+	iter   int
+	rate   float64
+	meanSq []float64
+}
+
+// NewRMSProp returns a new RMSProp with a learning rate of 0.001
+func NewRMSProp() *RMSProp {
+	return &RMSProp{
+		Rate:    Constant(0.001),
+		Decay:   0.9,
+		Epsilon: 1e-8,
+	}
+}
+
+func (r *RMSProp) Init(nDim int) error {
+	if err := checkSchedule(r.Rate); err != nil {
+		return errors.New("rmsprop: " + err.Error())
+	}
+	if r.Decay < 0 || r.Decay >= 1 {
+		return errors.New("rmsprop: decay must be in [0, 1)")
+	}
+	if r.Epsilon <= 0 {
+		return errors.New("rmsprop: epsilon must be positive")
+	}
+	r.iter = 0
+	r.meanSq = make([]float64, nDim)
+	return nil
+}
+
+func (r *RMSProp) Iterate(loc, grad []float64) {
+	r.rate = r.Rate.Rate(r.iter)
+	r.iter++
+	for i, g := range grad {
+		r.meanSq[i] = r.Decay*r.meanSq[i] + (1-r.Decay)*g*g
+		loc[i] -= r.rate * g / (math.Sqrt(r.meanSq[i]) + r.Epsilon)
+	}
+}
+
+func (r *RMSProp) AppendWriteData(v []*write.Value) []*write.Value {
+	v = append(v, &write.Value{Heading: "Rate", Value: r.rate})
+	return v
+}
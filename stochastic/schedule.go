@@ -0,0 +1,105 @@
+package stochastic
+
+import (
+	"errors"
+	"math"
+)
+
+// Schedule is a learning rate schedule. Rate returns the learning rate of the
+// step with index iter, starting from zero.
+type Schedule interface {
+	Rate(iter int) float64
+}
+
+// Constant is a constant learning rate
+type Constant float64
+
+func (c Constant) Rate(iter int) float64 {
+	return float64(c)
+}
+
+// StepDecay multiplies the learning rate by Factor every Interval steps. The
+// rate is constant if Interval is not positive.
+type StepDecay struct {
+	Initial  float64
+	Factor   float64
+	Interval int
+}
+
+func (s StepDecay) Rate(iter int) float64 {
+	if s.Interval <= 0 {
+		return s.Initial
+	}
+	return s.Initial * math.Pow(s.Factor, float64(iter/s.Interval))
+}
+
+// ExponentialDecay is the learning rate Initial * Decay^iter
+type ExponentialDecay struct {
+	Initial float64
+	Decay   float64
+}
+
+func (e ExponentialDecay) Rate(iter int) float64 {
+	return e.Initial * math.Pow(e.Decay, float64(iter))
+}
+
+// InverseTimeDecay is the learning rate Initial / (1 + Decay * iter). Its sum
+// diverges while the sum of its squares converges, which are the conditions
+// of Robbins and Monro for SGD to converge.
+type InverseTimeDecay struct {
+	Initial float64
+	Decay   float64
+}
+
+func (i InverseTimeDecay) Rate(iter int) float64 {
+	return i.Initial / (1 + i.Decay*float64(iter))
+}
+
+// CosineDecay decreases the learning rate from Initial to Final along half a
+// cosine over Length steps, and is Final afterwards
+type CosineDecay struct {
+	Initial float64
+	Final   float64
+	Length  int
+}
+
+func (c CosineDecay) Rate(iter int) float64 {
+	if iter >= c.Length {
+		return c.Final
+	}
+	return c.Final + 0.5*(c.Initial-c.Final)*(1+math.Cos(math.Pi*float64(iter)/float64(c.Length)))
+}
+
+// Warmup increases the learning rate linearly from zero over Length steps
+// before following Schedule, which starts from its step zero after the warmup.
+// Schedule must not be nil.
+type Warmup struct {
+	Schedule Schedule
+	Length   int
+}
+
+func (w Warmup) Rate(iter int) float64 {
+	if iter < w.Length {
+		return w.Schedule.Rate(0) * float64(iter+1) / float64(w.Length+1)
+	}
+	return w.Schedule.Rate(iter - w.Length)
+}
+
+// checkSchedule returns an error if there is no schedule to follow
+func checkSchedule(s Schedule) error {
+	switch s := s.(type) {
+	case nil:
+		return errors.New("no learning rate schedule")
+	case Warmup:
+		if s.Schedule == nil {
+			return errors.New("no learning rate schedule after the warmup")
+		}
+		return checkSchedule(s.Schedule)
+	case *Warmup:
+		if s == nil || s.Schedule == nil {
+			return errors.New("no learning rate schedule after the warmup")
+		}
+		return checkSchedule(s.Schedule)
+	}
+	return nil
+}
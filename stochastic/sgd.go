@@ -0,0 +1,79 @@
+package stochastic
+
+import (
+	"errors"
+
+	"github.com/btracey/opt/write"
+)
+
+// SGD is stochastic gradient descent with optional momentum. The velocity v
+// and location x are updated as
+//
+//	v = Momentum v - η g
+//	x += v
+//
+// where η is the learning rate and g is the minibatch gradient. With Nesterov
+// momentum the gradient is in effect taken at the look-ahead point x +
+// Momentum v, which is implemented as x += Momentum v - η g after the update
+// of v so that only gradients at the iterates are needed.
+type SGD struct {
+	Rate        Schedule // Learning rate
+	Momentum    float64  // Momentum coefficient in [0, 1). Zero is plain SGD
+	Nesterov    bool     // Use Nesterov momentum
+	WeightDecay float64  // Coefficient of the L2 penalty added to the gradient
+
+	iter     int
+	rate     float64
+	velocity []float64
+}
+
+// NewSGD returns a new SGD without momentum and with a learning rate of 0.01
+func NewSGD() *SGD {
+	return &SGD{
+		Rate: Constant(0.01),
+	}
+}
+
+// NewNesterov returns a new SGD with Nesterov momentum of 0.9 and a learning
+// rate of 0.01
+func NewNesterov() *SGD {
+	return &SGD{
+		Rate:     Constant(0.01),
+		Momentum: 0.9,
+		Nesterov: true,
+	}
+}
+
+func (s *SGD) Init(nDim int) error {
+	if err := checkSchedule(s.Rate); err != nil {
+		return errors.New("sgd: " + err.Error())
+	}
+	if s.Momentum < 0 || s.Momentum >= 1 {
+		return errors.New("sgd: momentum must be in [0, 1)")
+	}
+	if s.WeightDecay < 0 {
+		return errors.New("sgd: negative weight decay")
+	}
+	s.iter = 0
+	s.velocity = make([]float64, nDim)
+	return nil
+}
+
+func (s *SGD) Iterate(loc, grad []float64) {
+	s.rate = s.Rate.Rate(s.iter)
+	s.iter++
+	for i, g := range grad {
+		g += s.WeightDecay * loc[i]
+		s.velocity[i] = s.Momentum*s.velocity[i] - s.rate*g
+		if s.Nesterov {
+			loc[i] += s.Momentum*s.velocity[i] - s.rate*g
+		} else {
+			loc[i] += s.velocity[i]
+		}
+	}
+}
+
+func (s *SGD) AppendWriteData(v []*write.Value) []*write.Value {
+	v = append(v, &write.Value{Heading: "Rate", Value: s.rate})
+	return v
+}
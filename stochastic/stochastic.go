@@ -0,0 +1,197 @@
+// Package stochastic implements first-order optimizers for objective functions
+// that are sums over a data set and are evaluated on minibatches, so that the
+// gradients are noisy estimates of the gradient of the full objective.
+package stochastic
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/btracey/opt/common"
+	"github.com/btracey/opt/write"
+
+	"github.com/gonum/floats"
+)
+
+// Objective is a function that is the average over a data set split into
+// NumBatches minibatches. ObjGradBatch returns the objective function on the
+// minibatch with index batch and puts its gradient in place.
+type Objective interface {
+	NumBatches() int
+	ObjGradBatch(x []float64, batch int, grad []float64) (f float64)
+}
+
+// Settings is a structure containing settings for stochastic optimizers.
+//
+// The objective function and gradient on a single minibatch are too noisy to
+// test for convergence, so the tests use their averages over each epoch, that
+// is a pass through all of the minibatches. The average objective is an
+// estimate of the full objective along the path of the optimizer and the
+// average gradient approaches the full gradient as the steps become small.
+// The convergence is only checked at the end of an epoch.
+type Settings struct {
+	*common.CommonSettings
+
+	MaximumEpochs int        // Maximum number of epochs. The status is MaximumIterations if it is reached. Ignored if negative
+	Shuffle       bool       // Visit the minibatches in a random order in each epoch
+	Source        *rand.Rand // Source of the shuffles. The global source in math/rand is used if nil
+
+	GradAbsTol float64 // Absolute tolerance of the norm of the average gradient. Ignored if NaN
+	ObjRelTol  float64 // Relative decrease of the average objective that counts as an improvement
+	Patience   int     // Number of epochs without improvement before the status is ObjChangeTol. Ignored if not positive
+}
+
+// DefaultSettings returns the default settings for stochastic optimizers. The
+// optimization ends once the average objective has not improved for five
+// epochs.
+func DefaultSettings() *Settings {
+	return &Settings{
+		CommonSettings: common.DefaultCommonSettings(),
+		MaximumEpochs:  -1,
+		Shuffle:        true,
+		GradAbsTol:     1e-6,
+		ObjRelTol:      1e-4,
+		Patience:       5,
+	}
+}
+
+// Helper is a helper struct for stochastic optimizers. Not intended for use
+// by callers of optimization functions, but exported to aid others who are
+// building optimization algorithms
+//
+// Optimization implementers should call Init() at the beginning of an
+// optimization run, Iterate() after every minibatch step and EndEpoch() after
+// the last minibatch of each epoch, and should call Status() to check
+// tolerances.
+type Helper struct {
+	*common.Common
+
+	settings *Settings
+	status   common.Status
+
+	loc []float64
+	obj float64 // Objective on the most recent minibatch
+
+	epoch        int
+	nEpochBatch  int
+	epochObjSum  float64
+	epochGradSum []float64
+
+	avgObj     float64 // Average objective over the last epoch
+	avgGrad    []float64
+	avgGradNrm float64
+	bestAvgObj float64
+	nStall     int // Number of epochs since the average objective improved
+}
+
+// NewHelper creates a new stochastic helper and adds itself to the data adders
+func NewHelper() *Helper {
+	h := &Helper{
+		Common: common.NewCommon(),
+	}
+	h.AddDataAdder(h)
+	return h
+}
+
+func (h *Helper) AppendWriteData(v []*write.Value) []*write.Value {
+	v = append(v, &write.Value{Heading: "Epoch", Value: h.epoch})
+	v = append(v, &write.Value{Heading: "Obj", Value: h.obj})
+	v = append(v, &write.Value{Heading: "AvgObj", Value: h.avgObj})
+	v = append(v, &write.Value{Heading: "AvgGrad", Value: h.avgGradNrm})
+	return v
+}
+
+func (h *Helper) Init(s *Settings, objectiveFunction interface{}, initLoc []float64) {
+	h.Common.Init(s.CommonSettings, objectiveFunction)
+	h.settings = s
+	h.status = common.Continue
+
+	n := len(initLoc)
+	h.loc = append(h.loc[:0], initLoc...)
+	h.obj = math.NaN()
+
+	h.epoch = 0
+	h.nEpochBatch = 0
+	h.epochObjSum = 0
+	h.epochGradSum = make([]float64, n)
+
+	h.avgObj = math.NaN()
+	h.avgGrad = nil
+	h.avgGradNrm = math.Inf(1)
+	h.bestAvgObj = math.NaN()
+	h.nStall = 0
+}
+
+// Iterate records a step of the optimizer to loc, where obj and grad are the
+// objective and gradient on the minibatch before the step
+func (h *Helper) Iterate(loc []float64, obj float64, grad []float64, nFunEvals int) {
+	h.Common.Iterate(nFunEvals)
+	copy(h.loc, loc)
+	h.obj = obj
+	h.nEpochBatch++
+	h.epochObjSum += obj
+	floats.Add(h.epochGradSum, grad)
+}
+
+// EndEpoch computes the averages over the epoch and checks them for
+// convergence
+func (h *Helper) EndEpoch() {
+	if h.nEpochBatch == 0 {
+		return
+	}
+	h.epoch++
+	nb := float64(h.nEpochBatch)
+	h.avgObj = h.epochObjSum / nb
+	h.avgGrad = append(h.avgGrad[:0], h.epochGradSum...)
+	floats.Scale(1/nb, h.avgGrad)
+	h.avgGradNrm = floats.Norm(h.avgGrad, 2)
+
+	h.nEpochBatch = 0
+	h.epochObjSum = 0
+	for i := range h.epochGradSum {
+		h.epochGradSum[i] = 0
+	}
+
+	if h.epoch == 1 || h.avgObj < h.bestAvgObj-h.settings.ObjRelTol*math.Abs(h.bestAvgObj) {
+		h.bestAvgObj = h.avgObj
+		h.nStall = 0
+	} else {
+		h.nStall++
+	}
+
+	switch {
+	case h.avgGradNrm < h.settings.GradAbsTol:
+		h.status = common.GradAbsTol
+	case h.settings.Patience > 0 && h.nStall >= h.settings.Patience:
+		h.status = common.ObjChangeTol
+	case h.settings.MaximumEpochs > -1 && h.epoch >= h.settings.MaximumEpochs:
+		h.status = common.MaximumIterations
+	}
+}
+
+func (h *Helper) Status() common.Status {
+	if h.status != common.Continue {
+		return h.status
+	}
+	return h.Common.Status()
+}
+
+func (h *Helper) Result(status common.Status) *Result {
+	return &Result{
+		CommonResult: h.Common.Result(status),
+		Epochs:       h.epoch,
+		Obj:          h.avgObj,
+		Loc:          h.loc,
+		Grad:         h.avgGrad,
+	}
+}
+
+// Result is the result of a stochastic optimization. As the minibatch values
+// are noisy, Loc is the final location rather than the best one.
+type Result struct {
+	*common.CommonResult
+	Epochs int       // Number of complete epochs
+	Obj    float64   // Average objective over the last epoch
+	Loc    []float64 // Final location
+	Grad   []float64 // Average gradient over the last epoch
+}
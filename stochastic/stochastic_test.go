@@ -0,0 +1,210 @@
+package stochastic
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/btracey/opt/common"
+
+	"github.com/gonum/floats"
+)
+
+// linearRegression is the mean squared error 1/2 (x_i^T w - y_i)^2 over a
+// data set split into minibatches of batchSize points
+type linearRegression struct {
+	x         [][]float64
+	y         []float64
+	batchSize int
+}
+
+// newLinearRegression returns a regression problem with nData points in
+// dimension nDim whose responses are x_i^T w plus normal noise with standard
+// deviation noise
+func newLinearRegression(nData, nDim, batchSize int, w []float64, noise float64, source *rand.Rand) *linearRegression {
+	l := &linearRegression{
+		x:         make([][]float64, nData),
+		y:         make([]float64, nData),
+		batchSize: batchSize,
+	}
+	for i := range l.x {
+		l.x[i] = make([]float64, nDim)
+		for j := range l.x[i] {
+			l.x[i][j] = source.NormFloat64()
+		}
+		l.y[i] = floats.Dot(l.x[i], w) + noise*source.NormFloat64()
+	}
+	return l
+}
+
+func (l *linearRegression) NumBatches() int {
+	return (len(l.y) + l.batchSize - 1) / l.batchSize
+}
+
+func (l *linearRegression) ObjGradBatch(w []float64, batch int, grad []float64) float64 {
+	start := batch * l.batchSize
+	end := start + l.batchSize
+	if end > len(l.y) {
+		end = len(l.y)
+	}
+	for i := range grad {
+		grad[i] = 0
+	}
+	var obj float64
+	for i := start; i < end; i++ {
+		r := floats.Dot(l.x[i], w) - l.y[i]
+		obj += 0.5 * r * r
+		floats.AddScaled(grad, r, l.x[i])
+	}
+	n := float64(end - start)
+	floats.Scale(1/n, grad)
+	return obj / n
+}
+
+func TestOptimize(t *testing.T) {
+	w := []float64{1, -2, 0.5, 3, -1}
+	for _, test := range []struct {
+		name      string
+		optimizer Optimizer
+	}{
+		{"SGD", NewSGD()},
+		{"Momentum", &SGD{Rate: Constant(0.01), Momentum: 0.9}},
+		{"Nesterov", NewNesterov()},
+		{"InverseTime", &SGD{Rate: InverseTimeDecay{Initial: 0.05, Decay: 1e-3}}},
+		{"AdaGrad", &AdaGrad{Rate: Constant(0.5), Epsilon: 1e-8}},
+		{"RMSProp", &RMSProp{Rate: ExponentialDecay{Initial: 0.01, Decay: 0.999}, Decay: 0.9, Epsilon: 1e-8}},
+		{"Adam", &Adam{Rate: CosineDecay{Initial: 0.05, Final: 1e-5, Length: 5000}, Beta1: 0.9, Beta2: 0.999, Epsilon: 1e-8}},
+	} {
+		// The data has no noise, so all of the minibatch gradients are zero
+		// at the solution
+		f := newLinearRegression(200, len(w), 10, w, 0, rand.New(rand.NewSource(1)))
+		settings := DefaultSettings()
+		settings.DisplayWriters = nil
+		settings.Source = rand.New(rand.NewSource(2))
+		settings.GradAbsTol = 1e-6
+		settings.Patience = 0
+		settings.MaximumEpochs = 1000
+		result, err := Optimize(f, make([]float64, len(w)), settings, test.optimizer)
+		if err != nil {
+			t.Errorf("%v: error optimizing: %v", test.name, err)
+			continue
+		}
+		if result.Status != common.GradAbsTol {
+			t.Errorf("%v: status mismatch. Want %v, found %v", test.name, common.GradAbsTol, result.Status)
+		}
+		if !floats.EqualApprox(result.Loc, w, 1e-4) {
+			t.Errorf("%v: location mismatch. Want %v, found %v", test.name, w, result.Loc)
+		}
+	}
+}
+
+func TestOptimizeNoise(t *testing.T) {
+	// With noisy data the minibatch gradients do not vanish, and with a
+	// constant learning rate the optimizer ends when the average objective
+	// stops improving
+	w := []float64{1, -2, 0.5, 3, -1}
+	noise := 0.5
+	f := newLinearRegression(1000, len(w), 20, w, noise, rand.New(rand.NewSource(1)))
+	settings := DefaultSettings()
+	settings.DisplayWriters = nil
+	settings.Source = rand.New(rand.NewSource(2))
+	settings.MaximumEpochs = 1000
+	result, err := Optimize(f, make([]float64, len(w)), settings, NewSGD())
+	if err != nil {
+		t.Fatalf("Error optimizing: %v", err)
+	}
+	if result.Status != common.ObjChangeTol {
+		t.Errorf("Status mismatch. Want %v, found %v", common.ObjChangeTol, result.Status)
+	}
+	if result.Epochs >= settings.MaximumEpochs {
+		t.Errorf("Too many epochs: %v", result.Epochs)
+	}
+	// The mean squared error at the solution is about half of the variance
+	// of the noise
+	if math.Abs(result.Obj-0.5*noise*noise) > 0.1*noise*noise {
+		t.Errorf("Objective mismatch. Want about %v, found %v", 0.5*noise*noise, result.Obj)
+	}
+	if !floats.EqualApprox(result.Loc, w, 0.1) {
+		t.Errorf("Location mismatch. Want %v, found %v", w, result.Loc)
+	}
+
+	// The same source gives the same order of the minibatches
+	settings.Source = rand.New(rand.NewSource(2))
+	result2, err := Optimize(f, make([]float64, len(w)), settings, NewSGD())
+	if err != nil {
+		t.Fatalf("Error optimizing: %v", err)
+	}
+	if !floats.Equal(result.Loc, result2.Loc) {
+		t.Errorf("Optimization with the same source not repeatable")
+	}
+
+	settings.MaximumEpochs = 3
+	settings.Patience = 0
+	result, err = Optimize(f, make([]float64, len(w)), settings, NewSGD())
+	if err != nil {
+		t.Fatalf("Error optimizing: %v", err)
+	}
+	if result.Status != common.MaximumIterations || result.Epochs != 3 {
+		t.Errorf("Maximum epochs mismatch. Want %v after 3 epochs, found %v after %v", common.MaximumIterations, result.Status, result.Epochs)
+	}
+	if result.FunctionEvaluations != 3*f.NumBatches() {
+		t.Errorf("Function evaluation mismatch. Want %v, found %v", 3*f.NumBatches(), result.FunctionEvaluations)
+	}
+
+	// A learning rate that is much too large makes SGD diverge
+	_, err = Optimize(f, make([]float64, len(w)), settings, &SGD{Rate: Constant(1e3)})
+	if err == nil {
+		t.Errorf("No error for a divergent optimization")
+	}
+}
+
+func TestAdamWeightDecay(t *testing.T) {
+	// With a zero gradient the decoupled weight decay shrinks the location
+	// geometrically
+	a := NewAdamW()
+	a.Rate = Constant(0.1)
+	if err := a.Init(2); err != nil {
+		t.Fatal(err)
+	}
+	loc := []float64{1, -2}
+	want := []float64{1, -2}
+	grad := make([]float64, 2)
+	for i := 0; i < 10; i++ {
+		a.Iterate(loc, grad)
+		floats.Scale(1-0.1*a.WeightDecay, want)
+	}
+	if !floats.EqualApprox(loc, want, 1e-14) {
+		t.Errorf("Location mismatch. Want %v, found %v", want, loc)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		schedule Schedule
+		iter     []int
+		rate     []float64
+	}{
+		{"Constant", Constant(0.1), []int{0, 100}, []float64{0.1, 0.1}},
+		{"StepDecay", StepDecay{Initial: 1, Factor: 0.5, Interval: 10}, []int{0, 9, 10, 25}, []float64{1, 1, 0.5, 0.25}},
+		{"StepDecay no interval", StepDecay{Initial: 1, Factor: 0.5}, []int{0, 10}, []float64{1, 1}},
+		{"ExponentialDecay", ExponentialDecay{Initial: 2, Decay: 0.5}, []int{0, 1, 3}, []float64{2, 1, 0.25}},
+		{"InverseTimeDecay", InverseTimeDecay{Initial: 1, Decay: 0.5}, []int{0, 2, 6}, []float64{1, 0.5, 0.25}},
+		{"CosineDecay", CosineDecay{Initial: 1, Final: 0.1, Length: 10}, []int{0, 5, 10, 20}, []float64{1, 0.55, 0.1, 0.1}},
+		{"Warmup", Warmup{Schedule: InverseTimeDecay{Initial: 1, Decay: 1}, Length: 3}, []int{0, 2, 3, 4}, []float64{0.25, 0.75, 1, 0.5}},
+	} {
+		for i, iter := range test.iter {
+			rate := test.schedule.Rate(iter)
+			if math.Abs(rate-test.rate[i]) > 1e-14 {
+				t.Errorf("%v: rate mismatch at step %v. Want %v, found %v", test.name, iter, test.rate[i], rate)
+			}
+		}
+	}
+
+	// A warmup needs a schedule to follow
+	sgd := NewSGD()
+	sgd.Rate = Warmup{Length: 10}
+	if err := sgd.Init(2); err == nil {
+		t.Errorf("No error for a warmup without a schedule")
+	}
+}